@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pborman/getopt/v2"
+
+	"sigsum.org/sigsum-go/internal/ui"
+	"sigsum.org/sigsum-go/internal/version"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/evidence"
+	"sigsum.org/sigsum-go/pkg/policy"
+)
+
+type Settings struct {
+	policyFile string
+	policyName string
+	files      []string
+}
+
+func main() {
+	log.SetFlags(0)
+	var settings Settings
+	settings.parse(os.Args)
+
+	policy, err := ui.SelectPolicy(ui.PolicyParams{
+		File: settings.policyFile,
+		Name: settings.policyName,
+	})
+	if err != nil {
+		log.Fatalf("Failed to select policy: %v", err)
+	}
+	failed := false
+	for _, file := range settings.files {
+		conclusive, err := verifyFile(file, policy)
+		if err != nil {
+			fmt.Printf("%s: invalid: %v\n", file, err)
+			failed = true
+			continue
+		}
+		if conclusive {
+			fmt.Printf("%s: valid, conclusive\n", file)
+		} else {
+			fmt.Printf("%s: valid, inconclusive\n", file)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func verifyFile(file string, policy *policy.Policy) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	e, err := evidence.FromASCII(f)
+	if err != nil {
+		return false, err
+	}
+	if policy != nil {
+		if err := checkKey(e, policy); err != nil {
+			return false, err
+		}
+	}
+	conclusive, err := e.Verify()
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", e.Type(), err)
+	}
+	return conclusive, nil
+}
+
+// Checks that the accused party is a log or witness in the policy.
+func checkKey(e evidence.Evidence, p *policy.Policy) error {
+	entities, role := p.GetLogs(), "log"
+	if e.Type() == evidence.TypeWitnessSplitView {
+		entities, role = p.GetWitnesses(), "witness"
+	}
+	key := e.Key()
+	for _, entity := range entities {
+		if entity.PublicKey == key {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s key %x not in policy", role, crypto.HashBytes(key[:]))
+}
+
+func (s *Settings) parse(args []string) {
+	const usage = `
+Verify evidence of log or witness misbehavior, as produced by
+sigsum-monitor.  Evidence is conclusive if it proves misbehavior on
+its own; inconclusive evidence depends on a proof returned by the
+log, and shows that the log misbehaved or served an invalid proof.
+
+If a policy is given, the misbehaving log or witness must be listed
+in the policy.  Exit status is non-zero if any evidence file is
+invalid.
+`
+	set := getopt.New()
+	set.SetParameters("evidence-files")
+
+	help := false
+	versionFlag := false
+	set.FlagLong(&s.policyFile, "policy", 'p', "Trust policy file defining logs, witnesses, and a quorum rule", "policy-file")
+	set.FlagLong(&s.policyName, "named-policy", 'P', "Use a named trust policy defining logs, witnesses, and a quorum rule", "policy-name")
+	set.FlagLong(&help, "help", 0, "Show usage message and exit")
+	set.FlagLong(&versionFlag, "version", 'v', "Show software version and exit")
+	err := set.Getopt(args, nil)
+	// Check --help and --version first; if seen, ignore errors
+	// about missing mandatory arguments.
+	if help {
+		fmt.Print(usage[1:] + "\n")
+		set.PrintUsage(os.Stdout)
+		os.Exit(0)
+	}
+	if versionFlag {
+		version.DisplayVersion("sigsum-evidence")
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	if len(s.policyName) > 0 && len(s.policyFile) > 0 {
+		log.Fatal("The -P (--named-policy) and -p (--policy) options are mutually exclusive.")
+	}
+	if set.NArgs() == 0 {
+		log.Fatalf("No evidence files given on command line")
+	}
+	s.files = set.Args()
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -13,9 +18,11 @@ import (
 	"sigsum.org/sigsum-go/internal/ui"
 	"sigsum.org/sigsum-go/internal/version"
 	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/evidence"
 	"sigsum.org/sigsum-go/pkg/key"
 	"sigsum.org/sigsum-go/pkg/log"
 	"sigsum.org/sigsum-go/pkg/monitor"
+	"sigsum.org/sigsum-go/pkg/server"
 	"sigsum.org/sigsum-go/pkg/types"
 )
 
@@ -25,27 +32,137 @@ type Settings struct {
 	keys        []string
 	diagnostics string
 	interval    time.Duration
+	stateDir    string
+	maxAge      time.Duration
+	format      string
+	exitOnAlert bool
+	evidenceDir string
+	// Files or directories with expected checksums.
+	expected []string
+	// Alert sinks.
+	webhooks       []string
+	command        string
+	spoolFile      string
+	repeatInterval time.Duration
+	// Gossip with other monitors.
+	gossipListen string
+	gossipPeers  []string
 }
 
-type callbacks struct{}
+type callbacks struct {
+	format      string
+	exitOnAlert bool
+	evidenceDir string
 
-func (_ callbacks) NewTreeHead(logKeyHash crypto.Hash, signedTreeHead types.SignedTreeHead) {
-	fmt.Printf("New %x tree, size %d\n", logKeyHash, signedTreeHead.Size)
+	// Serializes output from the per-log goroutines.
+	m sync.Mutex
 }
 
-func (_ callbacks) NewLeaves(logKeyHash crypto.Hash, numberOfProcessedLeaves uint64, indices []uint64, leaves []types.Leaf) {
+// Event types for json output, one json object per line.
+type treeHeadEvent struct {
+	Event        string `json:"event"`
+	LogKeyHash   string `json:"log_key_hash"`
+	Size         uint64 `json:"size"`
+	RootHash     string `json:"root_hash"`
+	Cosignatures int    `json:"cosignatures"`
+}
+
+type leafEvent struct {
+	Event      string `json:"event"`
+	LogKeyHash string `json:"log_key_hash"`
+	Index      uint64 `json:"index"`
+	KeyHash    string `json:"key_hash"`
+	Checksum   string `json:"checksum"`
+}
+
+// Must be called with lock held.
+func (c *callbacks) writeJSON(event interface{}) {
+	// Encode adds a newline after the object.
+	if err := json.NewEncoder(os.Stdout).Encode(event); err != nil {
+		log.Fatal("Writing json output failed: %v", err)
+	}
+}
+
+func (c *callbacks) NewTreeHead(logKeyHash crypto.Hash, cosignedTreeHead types.CosignedTreeHead) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.format == "json" {
+		c.writeJSON(&treeHeadEvent{
+			Event:        "tree-head",
+			LogKeyHash:   hex.EncodeToString(logKeyHash[:]),
+			Size:         cosignedTreeHead.Size,
+			RootHash:     hex.EncodeToString(cosignedTreeHead.RootHash[:]),
+			Cosignatures: len(cosignedTreeHead.Cosignatures),
+		})
+		return
+	}
+	fmt.Printf("New %x tree, size %d, cosignatures %d\n", logKeyHash, cosignedTreeHead.Size, len(cosignedTreeHead.Cosignatures))
+}
+
+func (c *callbacks) NewLeaves(logKeyHash crypto.Hash, numberOfProcessedLeaves uint64, indices []uint64, leaves []types.Leaf) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.format == "json" {
+		for i, l := range leaves {
+			c.writeJSON(&leafEvent{
+				Event:      "leaf",
+				LogKeyHash: hex.EncodeToString(logKeyHash[:]),
+				Index:      indices[i],
+				KeyHash:    hex.EncodeToString(l.KeyHash[:]),
+				Checksum:   hex.EncodeToString(l.Checksum[:]),
+			})
+		}
+		return
+	}
 	fmt.Printf("New %x leaves, count %d, total processed %d\n", logKeyHash, len(leaves), numberOfProcessedLeaves)
 	for i, l := range leaves {
 		fmt.Printf("  index %d keyhash %x checksum %x\n", indices[i], l.KeyHash, l.Checksum)
 	}
 }
 
-func (_ callbacks) Alert(logKeyHash crypto.Hash, e error) {
-	if monitor.ErrorAlertType(e) == monitor.AlertWarning {
+func (c *callbacks) Alert(logKeyHash crypto.Hash, e error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	alertType := monitor.ErrorAlertType(e)
+	if c.format == "json" {
+		c.writeJSON(&monitor.AlertEvent{
+			LogKeyHash: logKeyHash,
+			Type:       alertType,
+			Message:    e.Error(),
+			Time:       time.Now(),
+			Evidence:   monitor.ErrorEvidence(e),
+		})
+	}
+	if ev := monitor.ErrorEvidence(e); ev != nil && len(c.evidenceDir) > 0 {
+		if file, err := c.writeEvidence(logKeyHash, ev); err != nil {
+			log.Error("Failed to write evidence for log %x: %v", logKeyHash, err)
+		} else {
+			log.Info("Evidence for log %x written to %q", logKeyHash, file)
+		}
+	}
+	switch {
+	case alertType == monitor.AlertWarning:
 		log.Warning("Alert log %x: %v\n", logKeyHash, e)
-	} else {
+	case c.exitOnAlert:
 		log.Fatal("Alert log %x: %v\n", logKeyHash, e)
+	default:
+		log.Error("Alert log %x: %v\n", logKeyHash, e)
+	}
+}
+
+// Writes evidence to a new file in the evidence directory, and returns
+// the file name.
+func (c *callbacks) writeEvidence(logKeyHash crypto.Hash, ev evidence.Evidence) (string, error) {
+	f, err := os.CreateTemp(c.evidenceDir,
+		fmt.Sprintf("%x-%s-%d-*.evidence", logKeyHash[:8], ev.Type(), time.Now().Unix()))
+	if err != nil {
+		return "", err
 	}
+	if err := ev.ToASCII(f); err != nil {
+		f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
 }
 
 func readPublicKeyFiles(fileNames []string, getPolicy bool) (map[crypto.Hash]crypto.PublicKey, string, error) {
@@ -83,8 +200,13 @@ func main() {
 		log.Fatal("%v", err)
 	}
 	config := monitor.Config{
-		QueryInterval: settings.interval,
-		Callbacks:     callbacks{},
+		QueryInterval:     settings.interval,
+		MaxCosignatureAge: settings.maxAge,
+		Callbacks: &callbacks{
+			format:      settings.format,
+			exitOnAlert: settings.exitOnAlert,
+			evidenceDir: settings.evidenceDir,
+		},
 	}
 	// Care about policy from pubkeys only if no policy option was specified
 	getPolicy := settings.policyFile == "" && settings.policyName == ""
@@ -93,6 +215,17 @@ func main() {
 	if config.SubmitKeys, policyNameFromPubKeys, err = readPublicKeyFiles(settings.keys, getPolicy); err != nil {
 		log.Fatal("Failed reading public key files: %v", err)
 	}
+	if len(settings.expected) > 0 {
+		if config.SubmitKeys == nil {
+			log.Fatal("Expected checksums requires submitter public keys")
+		}
+		config.ExpectedChecksums = make(map[crypto.Hash]struct{})
+		for _, name := range settings.expected {
+			if err := monitor.ReadExpectedChecksums(name, config.ExpectedChecksums); err != nil {
+				log.Fatal("Failed reading expected checksums: %v", err)
+			}
+		}
+	}
 	policy, err := ui.SelectPolicy(ui.PolicyParams{
 		File:           settings.policyFile,
 		Name:           settings.policyName,
@@ -104,23 +237,83 @@ func main() {
 	if policy == nil {
 		log.Fatal("A policy must be specified, either in pubkey file or using -p or -P")
 	}
-	// TODO: Read state from disk. Also store the list of submit
-	// keys, and discard state if keys are added, since whenever
-	// new keys are added, the log must be rescanned from the
-	// start.
+	if sinks := settings.alertSinks(); len(sinks) > 0 {
+		sinkConfig := monitor.AlertSinkConfig{
+			Sinks:          sinks,
+			RepeatInterval: settings.repeatInterval,
+		}
+		config.Callbacks = sinkConfig.Callbacks(config.Callbacks)
+	}
+	var gossip *monitor.Gossip
+	var gossipServer *http.Server
+	if len(settings.gossipListen) > 0 {
+		gossip = monitor.NewGossip()
+		config.Callbacks = gossip.Callbacks(config.Callbacks)
+		gossipServer = &http.Server{
+			Addr:    settings.gossipListen,
+			Handler: server.NewGossip(&server.Config{}, gossip),
+		}
+	}
+	for _, url := range settings.gossipPeers {
+		config.GossipPeers = append(config.GossipPeers, monitor.NewGossipPeer(url))
+	}
+	var state map[crypto.Hash]monitor.MonitorState
+	if len(settings.stateDir) > 0 {
+		// TODO: Also store the list of submit keys, and
+		// discard state if keys are added, since whenever new
+		// keys are added, the log must be rescanned from the
+		// start.
+		stateDir := monitor.NewStateDirectory(settings.stateDir)
+		if state, err = stateDir.Load(policy); err != nil {
+			log.Fatal("Failed to load monitor state: %v", err)
+		}
+		if gossip != nil {
+			gossip.Restore(state)
+		}
+		config.Callbacks = stateDir.Callbacks(config.Callbacks)
+	}
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	done := monitor.StartMonitoring(ctx, policy, &config, nil)
+	if gossipServer != nil {
+		go func() {
+			err := gossipServer.ListenAndServe()
+			if err != http.ErrServerClosed {
+				log.Fatal("Gossip server failed: %v", err)
+			}
+		}()
+	}
+
+	done := monitor.StartMonitoring(ctx, policy, &config, state)
 	<-done
+
+	if gossipServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		gossipServer.Shutdown(shutdownCtx)
+	}
+}
+
+func (s *Settings) alertSinks() []monitor.AlertSink {
+	var sinks []monitor.AlertSink
+	for _, url := range s.webhooks {
+		sinks = append(sinks, &monitor.WebhookSink{URL: url})
+	}
+	if len(s.command) > 0 {
+		sinks = append(sinks, &monitor.CommandSink{Command: strings.Fields(s.command)})
+	}
+	if len(s.spoolFile) > 0 {
+		sinks = append(sinks, &monitor.MailSpoolSink{File: s.spoolFile})
+	}
+	return sinks
 }
 
 func (s *Settings) parse(args []string) {
 	const usage = `
 Discover signed checksums for public keys in OpenSSH format.
 
-Be warned: this is a work-in-progress implementation.  Witness
-cosignatures are not verified and no state is kept between runs.
+Be warned: this is a work-in-progress implementation.  Unless a state
+directory is specified, no state is kept between runs.
 `
 
 	set := getopt.New()
@@ -130,10 +323,24 @@ cosignatures are not verified and no state is kept between runs.
 	versionFlag := false
 	s.diagnostics = "info"
 	s.interval = 10 * time.Minute
+	s.format = "text"
 
 	set.FlagLong(&s.policyFile, "policy", 'p', "Trust policy file defining logs, witnesses, and the end-user's quorum rule", "policy-file")
 	set.FlagLong(&s.policyName, "named-policy", 'P', "Use a named trust policy defining logs, witnesses, and the end-user's quorum rule", "policy-name")
 	set.FlagLong(&s.interval, "interval", 'i', "How often to fetch the latest entries", "interval")
+	set.FlagLong(&s.maxAge, "max-cosignature-age", 0, "Alert if the most recent witness cosignature is older than this (default: no check)", "duration")
+	set.FlagLong(&s.stateDir, "state-dir", 0, "Directory where monitor state is stored, and read on startup", "directory")
+	set.FlagLong(&s.stateDir, "state-directory", 0, "Same as --state-dir", "directory")
+	set.FlagLong(&s.format, "format", 0, "Output format, one of: text, json", "format")
+	set.FlagLong(&s.exitOnAlert, "exit-on-alert", 0, "Exit on any alert that is not a warning")
+	set.FlagLong(&s.expected, "expected-checksums", 0, "Alert on leaves with checksums not listed in this file, or computed from the files in this directory (can be repeated, or comma separated)", "file")
+	set.FlagLong(&s.evidenceDir, "evidence-directory", 0, "Write evidence of log misbehavior to files in this directory", "directory")
+	set.FlagLong(&s.webhooks, "alert-webhook", 0, "Post alerts in json format to this URL (can be repeated, or comma separated)", "url")
+	set.FlagLong(&s.command, "alert-command", 0, "Run this command for each alert, with the alert in json format on stdin", "command")
+	set.FlagLong(&s.spoolFile, "alert-spool", 0, "Append alerts as email messages to this mbox spool file", "file")
+	set.FlagLong(&s.repeatInterval, "alert-repeat-interval", 0, "Deliver identical alerts to alert sinks at most this often (default 1h)", "interval")
+	set.FlagLong(&s.gossipListen, "gossip-listen", 0, "Serve latest tree heads to other monitors, on this host and port", "host:port")
+	set.FlagLong(&s.gossipPeers, "gossip-peer", 0, "Compare tree heads with the monitor at this URL (can be repeated, or comma separated)", "url")
 	set.FlagLong(&s.diagnostics, "diagnostics", 0, "Available levels: fatal, error, warning, info, debug", "log-level")
 	set.FlagLong(&help, "help", 0, "Show usage message and exit")
 	set.FlagLong(&versionFlag, "version", 'v', "Show program version and exit")
@@ -153,6 +360,10 @@ cosignatures are not verified and no state is kept between runs.
 		log.Fatal("The -P (--named-policy) and -p (--policy) options are mutually exclusive.")
 	}
 
+	if s.format != "text" && s.format != "json" {
+		log.Fatal("Invalid output format %q, must be one of: text, json", s.format)
+	}
+
 	if err != nil {
 		fmt.Printf("err: %v\n", err)
 		set.PrintUsage(os.Stderr)
@@ -1,23 +1,57 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/pborman/getopt/v2"
 
 	"sigsum.org/sigsum-go/internal/version"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/key"
 	"sigsum.org/sigsum-go/pkg/policy"
 )
 
 type listSettings struct {
 }
 
+type diffSettings struct {
+	oldPolicy string
+	newPolicy string
+}
+
+type signSettings struct {
+	keyFile       string
+	name          string
+	version       uint64
+	signatureFile string
+	policyFile    string
+}
+
+type verifySettings struct {
+	keyFile       string
+	name          string
+	signatureFile string
+	policyFile    string
+}
+
 type showSettings struct {
 	policyName string
 }
 
+// Settings for commands operating on a single policy, specified
+// either as a file or by name.
+type policySettings struct {
+	policyFile string
+	policyName string
+}
+
 func main() {
 	const usage = `
 Manage builtin sigsum policies.
@@ -25,6 +59,11 @@ Manage builtin sigsum policies.
 Usage: sigsum-policy [--help|help] [--version|version]
    or: sigsum-policy list
    or: sigsum-policy show name
+   or: sigsum-policy check [-P name | file]
+   or: sigsum-policy analyze [-P name | file]
+   or: sigsum-policy diff old new
+   or: sigsum-policy sign -k key --policy-version version file
+   or: sigsum-policy verify [-k trusted-keys] file
 `
 	log.SetFlags(0)
 	if len(os.Args) < 2 {
@@ -58,7 +97,155 @@ Usage: sigsum-policy [--help|help] [--version|version]
 		if _, err := os.Stdout.Write(policy); err != nil {
 			log.Fatal(err)
 		}
+	case "check":
+		var settings policySettings
+		settings.parse(os.Args, `Check a policy for likely mistakes, e.g., witnesses that are
+not part of the quorum, logs without url, or a quorum that can be
+satisfied by a single witness. Exits with non-zero status if any
+problem is found.`)
+		problems := settings.read().Check()
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+	case "analyze":
+		var settings policySettings
+		settings.parse(os.Args, `Analyze a policy's quorum, listing the minimal sets of witnesses
+whose cosignatures satisfy the quorum, and the number of witnesses
+that can fail with the quorum still being satisfiable.`)
+		p := settings.read()
+		analyze(os.Stdout, p, p.Analyze())
+	case "diff":
+		var settings diffSettings
+		settings.parse(os.Args)
+		d := policy.Diff(readFileOrNamed(settings.oldPolicy), readFileOrNamed(settings.newPolicy))
+		if d.Empty() {
+			fmt.Println("No differences.")
+			return
+		}
+		fmt.Print(d)
+		if !d.Compatible() {
+			os.Exit(1)
+		}
+	case "sign":
+		var settings signSettings
+		settings.parse(os.Args)
+		signer, err := key.ReadPrivateKeyFile(settings.keyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		contents := readPolicyFile(settings.policyFile)
+		// Add to an existing signature file for the same
+		// name, version and policy file, otherwise replace it.
+		s, err := policy.ReadPolicySignatureFile(settings.signatureFile)
+		if err != nil || s.Name != settings.name || s.Version != settings.version ||
+			s.Checksum != crypto.HashBytes(contents) {
+			s = policy.PolicySignature{Name: settings.name, Version: settings.version}
+		}
+		if err := s.Sign(signer, contents); err != nil {
+			log.Fatalf("Signing failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := s.ToASCII(&buf); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(settings.signatureFile, buf.Bytes(), 0644); err != nil {
+			log.Fatal(err)
+		}
+	case "verify":
+		var settings verifySettings
+		settings.parse(os.Args)
+		var trustedKeys map[crypto.Hash]crypto.PublicKey
+		var err error
+		if len(settings.keyFile) > 0 {
+			trustedKeys, err = key.ReadPublicKeysFile(settings.keyFile)
+		} else {
+			trustedKeys, err = policy.TrustedSigningKeys()
+			if err == nil && trustedKeys == nil {
+				err = fmt.Errorf("no trusted policy signing keys configured, use -k option")
+			}
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		contents := readPolicyFile(settings.policyFile)
+		s, err := policy.ReadPolicySignatureFile(settings.signatureFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := s.Verify(settings.name, contents, trustedKeys); err != nil {
+			log.Fatal(err)
+		}
+		minVersions, err := policy.MinVersions()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := s.CheckVersion(minVersions); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Valid signature on policy %q, version %d\n", s.Name, s.Version)
+	}
+}
+
+// Reads a policy file, and checks that it's a valid policy.
+func readPolicyFile(fileName string) []byte {
+	contents, err := os.ReadFile(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := policy.ParseConfig(bytes.NewReader(contents)); err != nil {
+		log.Fatalf("Invalid policy file %q: %v", fileName, err)
+	}
+	return contents
+}
+
+// Returns the policy name corresponding to an installed policy file.
+func nameFromFile(fileName string) string {
+	name, found := strings.CutSuffix(filepath.Base(fileName), policy.InstalledPolicyFilenameSuffix)
+	if !found {
+		log.Fatalf("Policy file name %q doesn't end with %q, use the --name option",
+			fileName, policy.InstalledPolicyFilenameSuffix)
+	}
+	return name
+}
+
+// Reads a policy file, if it exists, otherwise a named policy.
+func readFileOrNamed(name string) *policy.Policy {
+	var p *policy.Policy
+	var err error
+	if _, statErr := os.Stat(name); statErr == nil {
+		p, err = policy.ReadPolicyFile(name)
+	} else {
+		p, err = policy.ByName(name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return p
+}
 
+func analyze(w io.Writer, p *policy.Policy, a *policy.Analysis) {
+	fmt.Fprintf(w, "Witnesses in quorum: %d\n", len(a.Witnesses))
+	fmt.Fprintf(w, "Cosignatures needed: %d\n", a.MinCosignatures)
+	fmt.Fprintf(w, "Fault tolerance: %d\n", a.FaultTolerance)
+	if a.TooManySets {
+		fmt.Fprintf(w, "Minimal witness sets: too many to list\n")
+		return
+	}
+	fmt.Fprintf(w, "Minimal witness sets: %d\n", len(a.MinimalSets))
+	for _, set := range a.MinimalSets {
+		names := make([]string, len(set))
+		for i, kh := range set {
+			names[i], _ = p.WitnessName(&kh)
+		}
+		if len(names) == 0 {
+			fmt.Fprintf(w, "  (empty set, no cosignatures needed)\n")
+			continue
+		}
+		sort.Strings(names)
+		fmt.Fprintf(w, "  %s\n", strings.Join(names, " "))
 	}
 }
 
@@ -107,3 +294,99 @@ func (s *showSettings) parse(args []string) {
 	}
 	s.policyName = finalArgs[0]
 }
+
+func (s *policySettings) parse(args []string, usage string) {
+	set := newOptionSet(args, "[file]")
+	set.FlagLong(&s.policyName, "named-policy", 'P', "Use a named policy", "name")
+	finalArgs := parse(set, args, usage)
+	if len(finalArgs) > 1 {
+		log.Fatal("Too many arguments.")
+	}
+	if len(finalArgs) > 0 {
+		s.policyFile = finalArgs[0]
+	}
+	if len(s.policyFile) > 0 && len(s.policyName) > 0 {
+		log.Fatal("The -P (--named-policy) option and a file argument are mutually exclusive.")
+	}
+	if len(s.policyFile) == 0 && len(s.policyName) == 0 {
+		log.Fatal("Missing argument: file, or -P (--named-policy) option")
+	}
+}
+
+func (s *policySettings) read() *policy.Policy {
+	var p *policy.Policy
+	var err error
+	if len(s.policyName) > 0 {
+		p, err = policy.ByName(s.policyName)
+	} else {
+		p, err = policy.ReadPolicyFile(s.policyFile)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return p
+}
+
+func (s *diffSettings) parse(args []string) {
+	set := newOptionSet(args, "old new")
+	finalArgs := parse(set, args, `Compare two policies, each given as a file name or, if there's no
+such file, a policy name. Lists logs and witnesses added, removed,
+or changed, and changes to the quorum, and states whether every
+proof valid under the old policy is also valid under the new policy.
+Exits with non-zero status if that's not the case.`)
+	if len(finalArgs) < 2 {
+		log.Fatal("Missing arguments: old and new policy")
+	}
+	if len(finalArgs) > 2 {
+		log.Fatal("Too many arguments.")
+	}
+	s.oldPolicy, s.newPolicy = finalArgs[0], finalArgs[1]
+}
+
+func (s *signSettings) parse(args []string) {
+	set := newOptionSet(args, "file")
+	set.FlagLong(&s.keyFile, "key", 'k', "Private key for signing, or public key for signing using ssh-agent", "file").Mandatory()
+	set.FlagLong(&s.version, "policy-version", 0, "Version number of the policy", "version").Mandatory()
+	set.FlagLong(&s.name, "name", 0, "Policy name, by default derived from the file name", "name")
+	set.FlagLong(&s.signatureFile, "output", 'o', "Signature file, by default the policy file name plus "+policy.SignatureFilenameSuffix, "file")
+	finalArgs := parse(set, args, `Sign a policy file, to be installed as a named policy. The signature
+covers the policy name, version, and the contents of the file. If the
+signature file already exists, for the same name, version, and file
+contents, the new signature is added, otherwise the signature file is
+replaced.`)
+	if len(finalArgs) < 1 {
+		log.Fatal("Missing argument: file")
+	}
+	if len(finalArgs) > 1 {
+		log.Fatal("Too many arguments.")
+	}
+	s.policyFile = finalArgs[0]
+	if len(s.name) == 0 {
+		s.name = nameFromFile(s.policyFile)
+	}
+	if len(s.signatureFile) == 0 {
+		s.signatureFile = s.policyFile + policy.SignatureFilenameSuffix
+	}
+}
+
+func (s *verifySettings) parse(args []string) {
+	set := newOptionSet(args, "file")
+	set.FlagLong(&s.keyFile, "trusted-keys", 'k', "Trusted policy signing keys, by default the configured keys", "file")
+	set.FlagLong(&s.name, "name", 0, "Policy name, by default derived from the file name", "name")
+	set.FlagLong(&s.signatureFile, "signature", 's', "Signature file, by default the policy file name plus "+policy.SignatureFilenameSuffix, "file")
+	finalArgs := parse(set, args, `Verify the signature on a policy file, and that the version is not
+older than the configured minimum version for the policy name.`)
+	if len(finalArgs) < 1 {
+		log.Fatal("Missing argument: file")
+	}
+	if len(finalArgs) > 1 {
+		log.Fatal("Too many arguments.")
+	}
+	s.policyFile = finalArgs[0]
+	if len(s.name) == 0 {
+		s.name = nameFromFile(s.policyFile)
+	}
+	if len(s.signatureFile) == 0 {
+		s.signatureFile = s.policyFile + policy.SignatureFilenameSuffix
+	}
+}
@@ -40,6 +40,9 @@ type Settings struct {
 	tokenDomain  string
 	tokenKeyFile string
 	timeout      time.Duration
+	refresh      bool
+	journal      string
+	resume       bool
 }
 
 // A LeafSink represents the action to take for input leaf requests,
@@ -132,6 +135,15 @@ func main() {
 		log.Fatal("%v", err)
 	}
 
+	if settings.resume {
+		resumeProofs(&settings)
+		return
+	}
+	if settings.refresh {
+		refreshProofs(&settings)
+		return
+	}
+
 	var source LeafSource
 	var policyNameFromPubKey string
 	var err error
@@ -156,6 +168,7 @@ func main() {
 		config := submit.Config{Policy: policy,
 			Domain:  settings.tokenDomain,
 			Timeout: settings.timeout,
+			Journal: settings.journal,
 		}
 		ctx := context.Background()
 
@@ -210,6 +223,12 @@ func main() {
 				log.Fatal("Writing proof failed: %v", err)
 			}
 		}
+		// Journal entries are removed only when all proofs are stored.
+		if len(config.Journal) > 0 {
+			if err := submit.RemoveFromJournal(config.Journal, reqs); err != nil {
+				log.Fatal("Updating journal failed: %v", err)
+			}
+		}
 	} else { // TODO: better to return above so that the "else" here is not needed?
 		// No policy specified. In this case the output should be an add-leaf request.
 		sink := func(_ string, _ *requests.Leaf) {}
@@ -238,6 +257,154 @@ func main() {
 	}
 }
 
+// Collects proofs for the leaf requests in the journal, without
+// signing or submitting anything. The inputs are used only to name
+// the output files: each proof is written to the output file for the
+// input with the same message. Journal entries are removed when the
+// proof files are written.
+func resumeProofs(settings *Settings) {
+	policyNameFromPubKey := ""
+	if len(settings.keyFile) > 0 {
+		// Only a public key file can name a policy.
+		if _, name, err := key.ReadPublicKeyFileWithPolicyName(settings.keyFile); err == nil {
+			policyNameFromPubKey = name
+		}
+	}
+	policy, err := ui.SelectPolicy(ui.PolicyParams{
+		File:           settings.policyFile,
+		Name:           settings.policyName,
+		NameFromPubKey: policyNameFromPubKey,
+	})
+	if err != nil {
+		log.Fatal("Failed to select policy: %v", err)
+	}
+	if policy == nil {
+		log.Fatal("The --resume option requires a policy.")
+	}
+	// Input names, by message, and messages of inputs that already
+	// have a proof file.
+	names := make(map[crypto.Hash]string)
+	hasProof := make(map[crypto.Hash]bool)
+	if len(settings.inputFiles) == 0 {
+		var msg crypto.Hash
+		if len(settings.keyFile) > 0 {
+			msg, err = readMessage(os.Stdin, settings.rawHash)
+		} else {
+			var leaf requests.Leaf
+			leaf, err = readLeafRequest(os.Stdin)
+			msg = leaf.Message
+		}
+		if err != nil {
+			log.Fatal("Reading stdin failed: %v", err)
+		}
+		names[msg] = ""
+	}
+	for _, inputFile := range settings.inputFiles {
+		var msg crypto.Hash
+		if len(settings.keyFile) > 0 {
+			msg = readMessageFile(inputFile, settings.rawHash)
+		} else {
+			leaf, err := readLeafRequestFile(inputFile)
+			if err != nil {
+				log.Fatal("Leaf request %q not valid: %v", inputFile, err)
+			}
+			msg = leaf.Message
+			inputFile = strings.TrimSuffix(inputFile, ".req")
+		}
+		names[msg] = inputFile
+		if _, err := os.Stat(settings.getOutputFile(inputFile, ".proof")); err == nil {
+			hasProof[msg] = true
+		}
+	}
+
+	journaled, err := submit.ReadJournal(settings.journal)
+	if err != nil {
+		log.Fatal("Reading journal failed: %v", err)
+	}
+	// Requests to collect proofs for, with corresponding input
+	// names, and requests that already have proof files, e.g., if
+	// an earlier run was interrupted before updating the journal.
+	var reqs, stored []requests.Leaf
+	var inputNames []string
+	inJournal := make(map[crypto.Hash]bool)
+	for _, req := range journaled {
+		name, ok := names[req.Message]
+		if !ok {
+			log.Warning("No input for message %x in journal, leaving it in the journal", req.Message)
+			continue
+		}
+		inJournal[req.Message] = true
+		if hasProof[req.Message] {
+			stored = append(stored, req)
+		} else {
+			reqs = append(reqs, req)
+			inputNames = append(inputNames, name)
+		}
+	}
+	for msg, name := range names {
+		if !hasProof[msg] && !inJournal[msg] {
+			log.Fatal("Input %q has no proof, and was not found in the journal", name)
+		}
+	}
+	if len(stored) > 0 {
+		if err := submit.RemoveFromJournal(settings.journal, stored); err != nil {
+			log.Fatal("Updating journal failed: %v", err)
+		}
+	}
+	if len(reqs) == 0 {
+		return
+	}
+
+	config := submit.Config{Policy: policy, Timeout: settings.timeout, Journal: settings.journal}
+	proofs, err := submit.ResumeLeafRequests(context.Background(), &config, reqs)
+	if err != nil {
+		log.Fatal("Resuming submission failed: %v", err)
+	}
+	for i := range proofs {
+		if err := settings.withOutputFile(inputNames[i], ".proof", proofs[i].ToASCII); err != nil {
+			log.Fatal("Writing proof failed: %v", err)
+		}
+	}
+	if err := submit.RemoveFromJournal(settings.journal, reqs); err != nil {
+		log.Fatal("Updating journal failed: %v", err)
+	}
+}
+
+// Replaces the proof for each input file with a proof using the
+// log's latest tree head.
+func refreshProofs(settings *Settings) {
+	policy, err := ui.SelectPolicy(ui.PolicyParams{
+		File: settings.policyFile,
+		Name: settings.policyName,
+	})
+	if err != nil {
+		log.Fatal("Failed to select policy: %v", err)
+	}
+	config := submit.Config{Policy: policy, Timeout: settings.timeout}
+	for _, inputFile := range settings.inputFiles {
+		msg := readMessageFile(inputFile, settings.rawHash)
+		proofName := settings.getOutputFile(inputFile, ".proof")
+		f, err := os.Open(proofName)
+		if err != nil {
+			log.Fatal("Opening proof file %q failed: %v", proofName, err)
+		}
+		var old proof.SigsumProof
+		err = old.FromASCII(f)
+		f.Close()
+		if err != nil {
+			log.Fatal("Parsing proof file %q failed: %v", proofName, err)
+		}
+		pr, err := submit.RefreshProof(context.Background(), &config, &msg, &old)
+		if err != nil {
+			log.Fatal("Refreshing proof %q failed: %v", proofName, err)
+		}
+		log.Info("Refreshed proof %q, tree size %d -> %d", proofName, old.TreeHead.Size, pr.TreeHead.Size)
+		if err := settings.withOutputFile(inputFile, ".proof", pr.ToASCII); err != nil {
+			log.Fatal("Writing proof failed: %v", err)
+		}
+	}
+}
+
 func countTrue(b ...bool) int {
 	n := 0
 	for _, v := range b {
@@ -292,6 +459,22 @@ proof is valid without performing a new add-leaf request.  An invalid
 proof will cause sigsum-submit to exit with an error.
 
 If a ".req" file already exists, then it is simply overwritten.
+
+With the --journal option, each leaf request is recorded in the
+journal file as soon as a log accepts it.  If sigsum-submit is
+interrupted, run it again with the same inputs and the --resume
+option, to only collect the proofs for the requests in the journal.
+Nothing is signed or submitted, so no private key or agent is needed;
+the inputs are used only to name the proof files, matched by message.
+Entries are removed from the journal when their proof files are
+written.
+
+With the --refresh option, each input file must have an existing
+".proof" file, which is replaced by a proof using the log's latest
+cosigned tree head, e.g., if the old proof no longer satisfies the
+policy since a witness was retired.  The leaf is not signed again, and
+the new tree head must be consistent with the old one.  A trust
+policy is required, and no signing key is used.
 `
 	s.diagnostics = "info"
 	s.timeout = submit.DefaultTimeout
@@ -312,6 +495,9 @@ If a ".req" file already exists, then it is simply overwritten.
 	set.FlagLong(&s.tokenDomain, "token-domain", 'd', "Domain name to use for rate-limiting; \"_sigsum_v1.\" will be prepended", "domain-name")
 	set.FlagLong(&s.tokenKeyFile, "token-signing-key", 'a', "Private key in OpenSSH format to sign DNS rate-limit tokens; or a corresponding public key where the private part is accessed using the SSH agent protocol", "key-file")
 	set.FlagLong(&s.timeout, "timeout", 't', "Timeout for submitting all signed checksums and collecting the proofs", "timeout")
+	set.FlagLong(&s.journal, "journal", 0, "Record accepted leaf requests in this file, until proofs are collected", "journal-file")
+	set.FlagLong(&s.resume, "resume", 0, "Only collect proofs for leaf requests recorded in the journal, without signing or submitting")
+	set.FlagLong(&s.refresh, "refresh", 0, "Refresh existing proofs for the input files, using the log's latest tree head")
 	set.FlagLong(&help, "help", 0, "Show usage message and exit")
 	set.FlagLong(&versionFlag, "version", 'v', "Show software version and exit")
 	set.Parse(args)
@@ -338,6 +524,25 @@ If a ".req" file already exists, then it is simply overwritten.
 	if countTrue(len(s.policyName) > 0, len(s.policyFile) > 0, s.leafHash) > 1 {
 		log.Fatal("The -P, -p, and --leaf-hash options are mutually exclusive.")
 	}
+	if s.resume {
+		if len(s.journal) == 0 {
+			log.Fatal("The --resume option requires a journal file (--journal).")
+		}
+		if s.refresh {
+			log.Fatal("The --resume and --refresh options are mutually exclusive.")
+		}
+	}
+	if s.refresh {
+		if len(s.inputFiles) == 0 {
+			log.Fatal("The --refresh option requires input files.")
+		}
+		if len(s.policyName) == 0 && len(s.policyFile) == 0 {
+			log.Fatal("The --refresh option requires a policy (-p or -P).")
+		}
+		if len(s.keyFile) > 0 || s.leafHash {
+			log.Fatal("The --refresh option can't be combined with -k or --leaf-hash.")
+		}
+	}
 	for _, f := range s.inputFiles {
 		if len(f) == 0 {
 			log.Fatal("Empty string is not a valid input file name.")
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pborman/getopt/v2"
 
@@ -13,15 +14,19 @@ import (
 	"sigsum.org/sigsum-go/internal/version"
 	"sigsum.org/sigsum-go/pkg/crypto"
 	"sigsum.org/sigsum-go/pkg/key"
+	"sigsum.org/sigsum-go/pkg/policy"
 	"sigsum.org/sigsum-go/pkg/proof"
 )
 
 type Settings struct {
 	rawHash    bool
 	proofFile  string
+	batch      string
 	submitKey  string
 	policyFile string
 	policyName string
+	// Reference time for checking cosignature timestamps, or nil.
+	now *time.Time
 }
 
 func main() {
@@ -40,6 +45,22 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	policy, err := ui.SelectPolicy(ui.PolicyParams{
+		File:           settings.policyFile,
+		Name:           settings.policyName,
+		NameFromPubKey: policyNameFromPubKeys,
+	})
+	if err != nil {
+		log.Fatalf("Failed to select policy: %v", err)
+	}
+	if policy == nil {
+		log.Fatalf("A policy must be specified, either in pubkey file or using -p or -P")
+	}
+	if len(settings.batch) > 0 {
+		verifyBatch(settings.batch, submitKeys, policy, settings.now)
+		return
+	}
+
 	msg, err := readMessage(os.Stdin, settings.rawHash)
 	if err != nil {
 		log.Fatal(err)
@@ -53,19 +74,60 @@ func main() {
 	if err := pr.FromASCII(f); err != nil {
 		log.Fatalf("Invalid proof: %v", err)
 	}
-	policy, err := ui.SelectPolicy(ui.PolicyParams{
-		File:           settings.policyFile,
-		Name:           settings.policyName,
-		NameFromPubKey: policyNameFromPubKeys,
-	})
+	if settings.now != nil {
+		err = pr.VerifyAt(&msg, submitKeys, policy, *settings.now)
+	} else {
+		err = pr.Verify(&msg, submitKeys, policy)
+	}
 	if err != nil {
-		log.Fatalf("Failed to select policy: %v", err)
+		if settings.now != nil {
+			explainQuorum(policy.ExplainCosignedTreeHeadAt(&pr.LogKeyHash, &pr.TreeHead, *settings.now))
+		} else {
+			explainQuorum(policy.ExplainCosignedTreeHead(&pr.LogKeyHash, &pr.TreeHead))
+		}
+		log.Fatalf("Sigsum proof failed to verify: %v", err)
 	}
-	if policy == nil {
-		log.Fatalf("A policy must be specified, either in pubkey file or using -p or -P")
+}
+
+// Explains which witnesses are missing, if it's the quorum that isn't
+// satisfied.
+func explainQuorum(report *policy.CosignatureReport, err error) {
+	if err == nil && !report.Quorum.Satisfied {
+		log.Printf("Cosignatures on proof's tree head: %s", report)
 	}
-	if err := pr.Verify(&msg, submitKeys, policy); err != nil {
-		log.Fatalf("Sigsum proof failed to verify: %v", err)
+}
+
+// Verifies all files listed in a manifest file, or found in a
+// directory, and exits with non-zero status if any fails.
+func verifyBatch(name string, submitKeys map[crypto.Hash]crypto.PublicKey, policy *policy.Policy, now *time.Time) {
+	info, err := os.Stat(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var items []proof.BatchItem
+	if info.IsDir() {
+		items, err = proof.BatchItemsFromDirectory(name)
+	} else {
+		items, err = proof.BatchItemsFromManifest(name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	verifier := proof.NewVerifier(submitKeys, policy)
+	if now != nil {
+		verifier = proof.NewVerifierAt(submitKeys, policy, *now)
+	}
+	failed := 0
+	for _, r := range verifier.VerifyBatch(items) {
+		if r.Err != nil {
+			fmt.Printf("%s: FAILED: %v\n", r.File, r.Err)
+			failed++
+		} else {
+			fmt.Printf("%s: OK\n", r.File)
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("%d of %d proofs failed to verify", failed, len(items))
 	}
 }
 
@@ -73,9 +135,20 @@ func (s *Settings) parse(args []string) {
 	const usage = `
 Verify that a message's signed checksum is logged for a given trust
 policy.  The message to be verified is read on stdin.
+
+With --batch, verify many files at once, each with its own proof.  The
+argument is either a directory, where each file with a corresponding
+.proof file is verified, or a manifest file, listing one file per
+line, optionally followed by the name of its proof file.  A line is
+printed for each file, and exit status is non-zero if any fails.
+
+With --time, cosignatures are also checked against a reference time,
+either "now" or an RFC 3339 time: cosignatures timestamped in the
+future, or older than the policy's max-cosignature-age, are rejected.
+By default, the result doesn't depend on the current time.
 `
 	set := getopt.New()
-	set.SetParameters("proof-file < input")
+	set.SetParameters("proof-file < input | --batch directory-or-manifest")
 
 	help := false
 	versionFlag := false
@@ -83,6 +156,9 @@ policy.  The message to be verified is read on stdin.
 	set.FlagLong(&s.submitKey, "key", 'k', "Submitter public keys, one per line in OpenSSH format", "key-file").Mandatory()
 	set.FlagLong(&s.policyFile, "policy", 'p', "Trust policy file defining logs, witnesses, and a quorum rule", "policy-file")
 	set.FlagLong(&s.policyName, "named-policy", 'P', "Use a named trust policy defining logs, witnesses, and a quorum rule", "policy-name")
+	set.FlagLong(&s.batch, "batch", 0, "Verify all files with proofs in a directory or listed in a manifest file", "directory-or-manifest")
+	timeArg := ""
+	set.FlagLong(&timeArg, "time", 0, "Reference time for checking cosignature timestamps, \"now\" or RFC 3339", "time")
 	set.FlagLong(&help, "help", 0, "Show usage message and exit")
 	set.FlagLong(&versionFlag, "version", 'v', "Show software version and exit")
 	err := set.Getopt(args, nil)
@@ -105,6 +181,24 @@ policy.  The message to be verified is read on stdin.
 	if len(s.policyName) > 0 && len(s.policyFile) > 0 {
 		log.Fatal("The -P (--named-policy) and -p (--policy) options are mutually exclusive.")
 	}
+	if len(timeArg) > 0 {
+		now := time.Now()
+		if timeArg != "now" {
+			if now, err = time.Parse(time.RFC3339, timeArg); err != nil {
+				log.Fatalf("Invalid --time argument: %v", err)
+			}
+		}
+		s.now = &now
+	}
+	if len(s.batch) > 0 {
+		if set.NArgs() > 0 {
+			log.Fatalf("No proof file argument allowed with --batch")
+		}
+		if s.rawHash {
+			log.Fatalf("The --raw-hash and --batch options are mutually exclusive.")
+		}
+		return
+	}
 	if set.NArgs() != 1 {
 		log.Fatalf("No proof given on command line")
 	}
@@ -0,0 +1,149 @@
+// A tool to retrieve a historic cosigned checkpoint from a witness'
+// history, e.g., for investigating a split view.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pborman/getopt/v2"
+
+	"sigsum.org/sigsum-go/internal/version"
+	"sigsum.org/sigsum-go/pkg/ascii"
+	"sigsum.org/sigsum-go/pkg/checkpoint"
+	"sigsum.org/sigsum-go/pkg/client"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/key"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+type Settings struct {
+	logKey     string
+	origin     string
+	witnessKey string
+	timeout    time.Duration
+	url        string
+	size       uint64
+}
+
+func main() {
+	log.SetFlags(0)
+	var settings Settings
+	settings.parse(os.Args)
+
+	var logPub *crypto.PublicKey
+	origin := settings.origin
+	if len(settings.logKey) > 0 {
+		pub, err := key.ReadPublicKeyFile(settings.logKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logPub = &pub
+		origin = types.SigsumCheckpointOrigin(logPub)
+	}
+	var witnessPub *crypto.PublicKey
+	if len(settings.witnessKey) > 0 {
+		pub, err := key.ReadPublicKeyFile(settings.witnessKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		witnessPub = &pub
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.timeout)
+	defer cancel()
+	cli := client.New(client.Config{URL: settings.url, UserAgent: "sigsum-witness-history"})
+	cp, err := cli.GetCosignedCheckpoint(ctx, requests.CosignedCheckpoint{
+		Size:       settings.size,
+		OriginHash: crypto.HashBytes([]byte(origin)),
+	})
+	if err != nil {
+		log.Fatalf("Getting cosigned checkpoint failed: %v", err)
+	}
+	if err := check(&cp, origin, settings.size, logPub, witnessPub); err != nil {
+		log.Fatalf("Invalid cosigned checkpoint: %v", err)
+	}
+	if err := cp.ToASCII(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Checks that the response matches the request, and, if keys are
+// given, verifies the log's signature and the witness' cosignature.
+func check(cp *checkpoint.GenericCheckpoint, origin string, size uint64, logPub, witnessPub *crypto.PublicKey) error {
+	if cp.Origin != origin {
+		return fmt.Errorf("unexpected origin %q", cp.Origin)
+	}
+	if cp.TreeHead.Size != size {
+		return fmt.Errorf("unexpected size %d", cp.TreeHead.Size)
+	}
+	if logPub != nil {
+		if _, err := cp.Verify([]checkpoint.NoteVerifier{
+			checkpoint.NewNoteVerifier(origin, checkpoint.SigTypeEd25519, logPub)}); err != nil {
+			return err
+		}
+	}
+	if witnessPub != nil {
+		if _, err := cp.VerifyCosignatureByKey(witnessPub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Settings) parse(args []string) {
+	const usage = `
+Retrieves the checkpoint of the given size cosigned by a witness,
+from the witness' history, and writes it to standard output, in
+signed note format, including the log's signature lines and the
+witness' cosignature line.
+
+The log is identified either by its public key, using --log-key, or
+by its checkpoint origin, using --origin. With --log-key, the log's
+signature is verified. With --witness-key, the witness' cosignature
+is verified.
+`
+	set := getopt.New()
+	set.SetParameters("witness-url size")
+
+	help := false
+	versionFlag := false
+	s.timeout = 10 * time.Second
+	set.FlagLong(&s.logKey, "log-key", 0, "Public key of a Sigsum log", "file")
+	set.FlagLong(&s.origin, "origin", 0, "Checkpoint origin of the log", "origin")
+	set.FlagLong(&s.witnessKey, "witness-key", 0, "Witness public key, for verifying the cosignature", "file")
+	set.FlagLong(&s.timeout, "timeout", 0, "Timeout for the request", "duration")
+	set.FlagLong(&help, "help", 0, "Show usage message and exit")
+	set.FlagLong(&versionFlag, "version", 'v', "Show software version and exit")
+	err := set.Getopt(args, nil)
+	// Check --help and --version first; if seen, ignore errors
+	// about missing mandatory arguments.
+	if help {
+		fmt.Print(usage[1:] + "\n")
+		set.PrintUsage(os.Stdout)
+		os.Exit(0)
+	}
+	if versionFlag {
+		version.DisplayVersion("sigsum-witness-history")
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	if (len(s.logKey) > 0) == (len(s.origin) > 0) {
+		log.Fatal("Exactly one of the --log-key and --origin options is required.")
+	}
+	if set.NArgs() != 2 {
+		log.Fatal("Mandatory witness-url and size arguments missing.")
+	}
+	s.url = set.Arg(0)
+	if s.size, err = ascii.IntFromDecimal(set.Arg(1)); err != nil {
+		log.Fatalf("Invalid size %q: %v", set.Arg(1), err)
+	}
+}
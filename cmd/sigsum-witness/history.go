@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"sigsum.org/sigsum-go/pkg/api"
+	"sigsum.org/sigsum-go/pkg/checkpoint"
+)
+
+// An append-only history of all checkpoints cosigned for a single
+// log. The file is a sequence of records, each a checkpoint in signed
+// note format, including both the log's signature lines and the
+// witness' cosignature line, followed by an empty line. Since the
+// note body is separated from the signature lines by an empty line,
+// a record ends at the second empty line.
+type history struct {
+	fileName string
+	// Synchronizes appends with reads of the file and the index.
+	m sync.Mutex
+	f *os.File
+	// Offset of the end of the last complete record.
+	end int64
+	// Location of the most recent record for each tree size.
+	index map[uint64]historyRecord
+}
+
+type historyRecord struct {
+	offset int64
+	// Length, excluding the terminating empty line.
+	length int64
+}
+
+// Opens the history file, creating it if it doesn't exist, and
+// indexes all records. A trailing incomplete record, e.g., left by a
+// crash during an append, is truncated.
+func openHistory(fileName string) (*history, error) {
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	h := history{fileName: fileName, f: f, index: make(map[uint64]historyRecord)}
+	if err := h.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading history file %q failed: %v", fileName, err)
+	}
+	return &h, nil
+}
+
+func (h *history) load() error {
+	r := bufio.NewReader(h.f)
+	var offset, start int64
+	emptyLines := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		offset += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(line) > 1 {
+			continue
+		}
+		if emptyLines++; emptyLines < 2 {
+			continue
+		}
+		record := historyRecord{offset: start, length: offset - 1 - start}
+		cp, err := h.read(record)
+		if err != nil {
+			return fmt.Errorf("invalid record at offset %d: %v", start, err)
+		}
+		h.index[cp.TreeHead.Size] = record
+		start, h.end, emptyLines = offset, offset, 0
+	}
+	if offset > h.end {
+		log.Printf("Truncating incomplete record at the end of history file %q", h.fileName)
+		return h.f.Truncate(h.end)
+	}
+	return nil
+}
+
+func (h *history) read(record historyRecord) (checkpoint.GenericCheckpoint, error) {
+	var cp checkpoint.GenericCheckpoint
+	err := cp.FromASCII(io.NewSectionReader(h.f, record.offset, record.length))
+	return cp, err
+}
+
+// Appends a cosigned checkpoint, and syncs the file to stable
+// storage. On failure, the file is truncated to the previous end.
+func (h *history) Append(cp *checkpoint.GenericCheckpoint) error {
+	var buf bytes.Buffer
+	if err := cp.ToASCII(&buf); err != nil {
+		return err
+	}
+	record := historyRecord{offset: h.end, length: int64(buf.Len())}
+	buf.WriteString("\n")
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if _, err := h.f.WriteAt(buf.Bytes(), h.end); err != nil {
+		h.f.Truncate(h.end)
+		return err
+	}
+	if err := h.f.Sync(); err != nil {
+		h.f.Truncate(h.end)
+		return err
+	}
+	h.end += int64(buf.Len())
+	h.index[cp.TreeHead.Size] = record
+	return nil
+}
+
+// Returns the most recent cosigned checkpoint of the given size.
+func (h *history) Get(size uint64) (checkpoint.GenericCheckpoint, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	record, ok := h.index[size]
+	if !ok {
+		return checkpoint.GenericCheckpoint{}, api.ErrNotFound
+	}
+	cp, err := h.read(record)
+	if err != nil {
+		return checkpoint.GenericCheckpoint{}, fmt.Errorf("reading history file %q failed: %v", h.fileName, err)
+	}
+	return cp, nil
+}
@@ -1,9 +1,12 @@
-// A witness implementation capable of cosigning a single Sigsum log,
-// identified by that log's public key, and corresponding
-// "sigsum.org/..." origin line.
+// A witness implementation capable of cosigning one or more Sigsum
+// logs, each identified by that log's public key, and corresponding
+// "sigsum.org/..." origin line. Optionally, it can also cosign other
+// logs producing checkpoints, identified by origin and note verifier
+// keys.
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -11,13 +14,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/dchest/safefile"
 	"github.com/pborman/getopt/v2"
 
+	"sigsum.org/sigsum-go/internal/ui"
 	"sigsum.org/sigsum-go/internal/version"
 	"sigsum.org/sigsum-go/pkg/api"
 	"sigsum.org/sigsum-go/pkg/checkpoint"
@@ -29,11 +38,20 @@ import (
 )
 
 type Settings struct {
-	keyFile     string
-	logKey      string
-	stateFile   string
-	prefix      string
-	hostAndPort string
+	keyFile    string
+	logKeys    []string
+	policyFile string
+	policyName string
+	// File listing note verifier keys of non-Sigsum logs.
+	checkpointLogs string
+	stateFile      string
+	stateDir       string
+	prefix         string
+	hostAndPort    string
+	// Abuse protection.
+	clientRateLimit string
+	originRateLimit string
+	maxRequestSize  int64
 }
 
 func main() {
@@ -46,19 +64,57 @@ func main() {
 		log.Fatal(err)
 	}
 	pub := signer.Public()
-	logPub, err := key.ReadPublicKeyFile(settings.logKey)
+	config, err := settings.serverConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
-	state := state{fileName: settings.stateFile}
-	if err := state.Load(&pub, &logPub); err != nil {
+	logKeys, err := settings.readLogKeys()
+	if err != nil {
 		log.Fatal(err)
 	}
-	witness := newWitness(signer, &pub, &logPub, &state)
+	witness := newWitness(signer, &pub)
+	config.KnownOrigin = witness.knownOrigin
+	for _, logPub := range logKeys {
+		stateFile := settings.stateFile
+		if len(settings.stateDir) > 0 {
+			logKeyHash := crypto.HashBytes(logPub[:])
+			stateFile = filepath.Join(settings.stateDir, fmt.Sprintf("%x", logKeyHash[:]))
+		}
+		if err := witness.addLog(&logPub, stateFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	handler := server.NewWitness(&config, witness)
+	if len(settings.checkpointLogs) > 0 {
+		logs, err := readCheckpointLogs(settings.checkpointLogs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, l := range logs {
+			stateFile := settings.stateFile
+			if len(settings.stateDir) > 0 {
+				stateFile = filepath.Join(settings.stateDir, fmt.Sprintf("%x", crypto.HashBytes([]byte(l.origin))))
+			}
+			if err := witness.addCheckpointLog(l.origin, l.verifiers, stateFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+		handler = server.NewGenericWitness(&config, witness)
+	}
+	if len(witness.logs) == 0 {
+		log.Fatal("No logs to cosign, use --log-key, -p, -P or --checkpoint-logs")
+	}
+	if len(settings.stateFile) > 0 && len(witness.logs) > 1 {
+		log.Fatal("The --state-file option can only be used with a single log, use --state-directory")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.Handle("GET /"+types.EndpointGetCosignedCheckpoint.Path(settings.prefix),
+		server.NewWitnessHistory(&config, witness))
 
 	httpServer := http.Server{
 		Addr:    settings.hostAndPort,
-		Handler: server.NewWitness(&server.Config{Prefix: settings.prefix}, &witness),
+		Handler: mux,
 	}
 
 	var wg sync.WaitGroup
@@ -83,10 +139,98 @@ func main() {
 	httpServer.Shutdown(shutdownCtx)
 }
 
+func (s *Settings) serverConfig() (server.Config, error) {
+	config := server.Config{Prefix: s.prefix, MaxRequestBodySize: s.maxRequestSize}
+	var err error
+	if config.ClientRateLimit, err = parseRateLimit(s.clientRateLimit); err != nil {
+		return server.Config{}, fmt.Errorf("invalid --client-rate-limit: %v", err)
+	}
+	if config.OriginRateLimit, err = parseRateLimit(s.originRateLimit); err != nil {
+		return server.Config{}, fmt.Errorf("invalid --origin-rate-limit: %v", err)
+	}
+	return config, nil
+}
+
+// Parses a rate limit of the form count/interval, e.g., "10/1m",
+// allowing bursts of count requests. An empty string means no limit.
+func parseRateLimit(s string) (server.RateLimit, error) {
+	if len(s) == 0 {
+		return server.RateLimit{}, nil
+	}
+	countString, intervalString, found := strings.Cut(s, "/")
+	if !found {
+		return server.RateLimit{}, fmt.Errorf("expected count/interval, got %q", s)
+	}
+	count, err := strconv.Atoi(countString)
+	if err != nil || count <= 0 {
+		return server.RateLimit{}, fmt.Errorf("invalid count %q", countString)
+	}
+	interval, err := time.ParseDuration(intervalString)
+	if err != nil || interval <= 0 {
+		return server.RateLimit{}, fmt.Errorf("invalid interval %q", intervalString)
+	}
+	return server.RateLimit{Rate: float64(count) / interval.Seconds(), Burst: count}, nil
+}
+
+// Returns the public keys of all logs to cosign, from --log-key
+// options and the policy, if any.
+func (s *Settings) readLogKeys() ([]crypto.PublicKey, error) {
+	var logKeys []crypto.PublicKey
+	for _, fileName := range s.logKeys {
+		logPub, err := key.ReadPublicKeyFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		logKeys = append(logKeys, logPub)
+	}
+	policy, err := ui.SelectPolicy(ui.PolicyParams{File: s.policyFile, Name: s.policyName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select policy: %v", err)
+	}
+	if policy != nil {
+		for _, entity := range policy.GetLogs() {
+			logKeys = append(logKeys, entity.PublicKey)
+		}
+	}
+	return logKeys, nil
+}
+
 func (s *Settings) parse(args []string) {
 	const usage = `
-Provides a service for cosigning a sigsum log (currently, only a
-single log), listening on the given host and port.
+Provides a service for cosigning one or more sigsum logs, listening
+on the given host and port.
+
+The logs to cosign are specified using --log-key options, and/or all
+logs of a policy, using -p or -P. For a single log, the witness state
+can be stored in a file specified with --state-file. Otherwise, use
+--state-directory; the state for each log is then stored in a file in
+that directory, named by the hex log key hash.
+
+To also cosign non-Sigsum logs producing checkpoints, list them in a
+file specified with --checkpoint-logs. Each line is a note verifier
+key (vkey), optionally followed by the log's origin, if different
+from the key name; listing several keys with the same origin accepts
+checkpoints signed by any of them, e.g., during key rotation. Such
+logs may use extension lines in checkpoints. Their state files are
+named by the hex SHA256 hash of the origin.
+
+Every cosigned checkpoint is also appended to a history file, named
+like the state file with a ".history" suffix. Historic cosigned
+checkpoints can be retrieved using the get-cosigned-checkpoint
+endpoint, e.g., with the sigsum-witness-history tool.
+
+The witness key can be held by ssh-agent, e.g., using a hardware
+token: pass the public key file to --signing-key, and set
+SSH_AUTH_SOCK. If the agent is restarted, the witness reconnects, but
+the key must be added to the agent again.
+
+To protect a public witness against abuse, add-checkpoint requests
+can be rate limited per client IP address, using --client-rate-limit,
+and per cosigned log, using --origin-rate-limit (requests for other
+logs are rejected without any cryptographic work). Limits are
+specified as count/interval, e.g., "10/1m", and allow bursts of count
+requests. Requests exceeding a limit get a 429 (Too Many Requests)
+response.
 
 Be warned: this tool is only used for internal testing.
 `
@@ -96,11 +240,18 @@ Be warned: this tool is only used for internal testing.
 
 	help := false
 	versionFlag := false
-	set.FlagLong(&s.keyFile, "signing-key", 'k', "Witness private key", "file").Mandatory()
-	set.FlagLong(&s.logKey, "log-key", 0, "Log public key", "file").Mandatory()
+	set.FlagLong(&s.keyFile, "signing-key", 'k', "Witness private key; or a corresponding public key where the private part is accessed using the SSH agent protocol", "key-file").Mandatory()
+	set.FlagLong(&s.logKeys, "log-key", 0, "Log public key (can be repeated)", "file")
+	set.FlagLong(&s.policyFile, "policy", 'p', "Cosign all logs in this trust policy file", "policy-file")
+	set.FlagLong(&s.policyName, "named-policy", 'P', "Cosign all logs in this named trust policy", "policy-name")
 	// TODO: Better name?
-	set.FlagLong(&s.stateFile, "state-file", 0, "Name of state file", "file").Mandatory()
+	set.FlagLong(&s.stateFile, "state-file", 0, "Name of state file, for a single log", "file")
+	set.FlagLong(&s.checkpointLogs, "checkpoint-logs", 0, "Also cosign non-Sigsum logs with note verifier keys listed in file", "file")
+	set.FlagLong(&s.stateDir, "state-directory", 0, "Directory with one state file per log", "directory")
 	set.FlagLong(&s.prefix, "url-prefix", 0, "Prefix preceding the endpoint names", "string")
+	set.FlagLong(&s.clientRateLimit, "client-rate-limit", 0, "Limit on add-checkpoint requests per client", "count/interval")
+	set.FlagLong(&s.originRateLimit, "origin-rate-limit", 0, "Limit on add-checkpoint requests per log", "count/interval")
+	set.FlagLong(&s.maxRequestSize, "max-request-size", 0, "Maximum size of request bodies (default 64 KiB)", "bytes")
 	set.FlagLong(&help, "help", 0, "Display help")
 	set.FlagLong(&versionFlag, "version", 'v', "Display software version")
 	err := set.Getopt(args, nil)
@@ -120,71 +271,223 @@ Be warned: this tool is only used for internal testing.
 		fmt.Fprint(os.Stderr, usage)
 		os.Exit(1)
 	}
+	if len(s.policyName) > 0 && len(s.policyFile) > 0 {
+		log.Fatal("The -P (--named-policy) and -p (--policy) options are mutually exclusive.")
+	}
+	if (len(s.stateFile) > 0) == (len(s.stateDir) > 0) {
+		log.Fatal("Exactly one of the --state-file and --state-directory options is required.")
+	}
 	if set.NArgs() != 1 {
 		log.Fatal("Mandatory HOST:PORT argument missing")
 	}
 	s.hostAndPort = set.Arg(0)
 }
 
+// A non-Sigsum log, as listed in the --checkpoint-logs file.
+type checkpointLog struct {
+	origin    string
+	verifiers []checkpoint.NoteVerifier
+}
+
+// Reads a file with one note verifier key per line, optionally
+// followed by white space and the log's origin, which may include
+// spaces. Lines with the same origin are combined. Empty lines and
+// lines starting with # are ignored.
+func readCheckpointLogs(fileName string) ([]checkpointLog, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var logs []checkpointLog
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		vkey, origin, _ := strings.Cut(line, " ")
+		var verifier checkpoint.NoteVerifier
+		if err := verifier.FromString(vkey); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, lineno, err)
+		}
+		if verifier.Type != checkpoint.SigTypeEd25519 {
+			return nil, fmt.Errorf("%s:%d: not an Ed25519 log key", fileName, lineno)
+		}
+		if origin = strings.TrimSpace(origin); len(origin) == 0 {
+			origin = verifier.Name
+		}
+		if i, ok := index[origin]; ok {
+			logs[i].verifiers = append(logs[i].verifiers, verifier)
+		} else {
+			index[origin] = len(logs)
+			logs = append(logs, checkpointLog{origin: origin, verifiers: []checkpoint.NoteVerifier{verifier}})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
 type witness struct {
 	signer  crypto.Signer
+	pub     crypto.PublicKey
 	keyHash crypto.Hash
 	keyName string
 	keyId   checkpoint.KeyId
-	logPub  crypto.PublicKey
-	origin  string
-	state   *state
+	// Cosigned logs, indexed by checkpoint origin, and by hash of
+	// the origin. Not modified after startup; each log's state and
+	// history has its own lock.
+	logs         map[string]*witnessedLog
+	byOriginHash map[crypto.Hash]*witnessedLog
 }
 
-func newWitness(signer crypto.Signer, pub *crypto.PublicKey, logPub *crypto.PublicKey, state *state) witness {
+type witnessedLog struct {
+	verifiers []checkpoint.NoteVerifier
+	// For Sigsum logs, the log's public key, used to verify the
+	// stored tree head; nil for other logs. Checkpoints from Sigsum
+	// logs must not have extension lines.
+	logPub  *crypto.PublicKey
+	state   state
+	history *history
+}
+
+func newWitness(signer crypto.Signer, pub *crypto.PublicKey) *witness {
 	keyHash := crypto.HashBytes(pub[:])
 	// Arbitrary name. TODO: Specify somewhere?
 	keyName := fmt.Sprintf("sigsum.org/v1/witness/%x", keyHash)
-	return witness{
+	return &witness{
 		signer:  signer,
+		pub:     *pub,
 		keyHash: keyHash,
 		keyName: keyName,
 		keyId:   checkpoint.NewWitnessKeyId(keyName, pub),
-		logPub:  *logPub,
-		origin:  types.SigsumCheckpointOrigin(logPub),
-		state:   state,
+		logs:    make(map[string]*witnessedLog),
+
+		byOriginHash: make(map[crypto.Hash]*witnessedLog),
 	}
 }
 
-func (w *witness) AddCheckpoint(_ context.Context, req requests.AddCheckpoint) ([]checkpoint.CosignatureLine, error) {
-	if req.Checkpoint.Origin != w.origin {
-		return nil, api.ErrNotFound
+func (w *witness) add(origin string, l *witnessedLog) error {
+	if _, ok := w.logs[origin]; ok {
+		return fmt.Errorf("duplicate log %q", origin)
+	}
+	if err := l.state.Load(&w.pub, origin, l.logPub); err != nil {
+		return fmt.Errorf("loading state for log %q failed: %v", origin, err)
 	}
+	var err error
+	if l.history, err = openHistory(l.state.fileName + ".history"); err != nil {
+		return err
+	}
+	w.logs[origin] = l
+	w.byOriginHash[crypto.HashBytes([]byte(origin))] = l
+	return nil
+}
+
+// Adds a Sigsum log to cosign, loading its state from the given
+// file, if it exists. The history of cosigned checkpoints is stored
+// in a file with the same name and a ".history" suffix.
+func (w *witness) addLog(logPub *crypto.PublicKey, stateFile string) error {
+	origin := types.SigsumCheckpointOrigin(logPub)
+	return w.add(origin, &witnessedLog{
+		verifiers: []checkpoint.NoteVerifier{checkpoint.NewNoteVerifier(origin, checkpoint.SigTypeEd25519, logPub)},
+		logPub:    logPub,
+		state:     state{fileName: stateFile},
+	})
+}
+
+// Adds a non-Sigsum log, identified by origin and note verifier
+// keys.
+func (w *witness) addCheckpointLog(origin string, verifiers []checkpoint.NoteVerifier, stateFile string) error {
+	return w.add(origin, &witnessedLog{verifiers: verifiers, state: state{fileName: stateFile}})
+}
 
-	if err := req.Checkpoint.Verify(&w.logPub); err != nil {
+func (w *witness) knownOrigin(origin string) bool {
+	_, ok := w.logs[origin]
+	return ok
+}
+
+func (w *witness) AddCheckpoint(ctx context.Context, req requests.AddCheckpoint) ([]checkpoint.CosignatureLine, error) {
+	return w.AddGenericCheckpoint(ctx, requests.AddGenericCheckpoint{
+		OldSize: req.OldSize, Proof: req.Proof, Checkpoint: req.Checkpoint.Generic(),
+	})
+}
+
+func (w *witness) AddGenericCheckpoint(_ context.Context, req requests.AddGenericCheckpoint) ([]checkpoint.CosignatureLine, error) {
+	l, ok := w.logs[req.Checkpoint.Origin]
+	if !ok {
+		return nil, api.ErrNotFound
+	}
+	if l.logPub != nil && len(req.Checkpoint.Extensions) > 0 {
+		return nil, api.ErrForbidden.WithError(fmt.Errorf("unexpected extension lines in sigsum checkpoint"))
+	}
+	// Cheap check before any cryptographic work; Update checks
+	// again with the lock held.
+	if size := l.state.Size(); req.OldSize != size {
+		return nil, api.ErrConflict.WithOldSize(size)
+	}
+	signature, err := req.Checkpoint.Verify(l.verifiers)
+	if err != nil {
 		return nil, api.ErrForbidden.WithError(err)
 	}
-	cs, err := w.state.Update(&req.Checkpoint.SignedTreeHead, req.OldSize, &req.Proof, &w.keyHash,
+	sth := types.SignedTreeHead{TreeHead: req.Checkpoint.TreeHead, Signature: signature}
+	cs, err := l.state.Update(&sth, req.OldSize, &req.Proof, &w.keyHash,
 		func() (types.Cosignature, error) {
-			return req.Checkpoint.Cosign(w.signer, uint64(time.Now().Unix()))
+			cs, err := req.Checkpoint.Cosign(w.signer, uint64(time.Now().Unix()))
+			if err != nil {
+				return types.Cosignature{}, err
+			}
+			// Record in the history before the state is
+			// updated, so that every cosignature the
+			// witness has returned can be looked up.
+			csl := w.cosignatureLine(cs)
+			cp := req.Checkpoint
+			cp.Signatures = append(slices.Clone(cp.Signatures), csl.NoteSignature())
+			if err := l.history.Append(&cp); err != nil {
+				return types.Cosignature{}, fmt.Errorf("appending to history failed: %v", err)
+			}
+			return cs, nil
 		})
 
 	if err != nil {
 		return nil, err
 	}
-	return []checkpoint.CosignatureLine{
-		checkpoint.CosignatureLine{
-			KeyName:     w.keyName,
-			KeyId:       w.keyId,
-			Cosignature: cs,
-		},
-	}, nil
+	return []checkpoint.CosignatureLine{w.cosignatureLine(cs)}, nil
+}
+
+func (w *witness) cosignatureLine(cs types.Cosignature) checkpoint.CosignatureLine {
+	return checkpoint.CosignatureLine{
+		KeyName:     w.keyName,
+		KeyId:       w.keyId,
+		Cosignature: cs,
+	}
+}
+
+func (w *witness) GetCosignedCheckpoint(_ context.Context, req requests.CosignedCheckpoint) (checkpoint.GenericCheckpoint, error) {
+	l, ok := w.byOriginHash[req.OriginHash]
+	if !ok {
+		return checkpoint.GenericCheckpoint{}, api.ErrNotFound
+	}
+	return l.history.Get(req.Size)
 }
 
 type state struct {
 	fileName string
-	// Synchronizes all updates to both the size field and the
+	// Synchronizes all updates to both the tree head and the
 	// underlying file.
 	m  sync.Mutex
 	th types.TreeHead
+	// Copy of th.Size, updated with the lock held, but can be
+	// read without it, so that the cheap size check isn't blocked
+	// by an ongoing update.
+	size atomic.Uint64
 }
 
-func (s *state) Load(pub, logPub *crypto.PublicKey) error {
+// If logPub is non-nil, the log's signature on the stored tree head
+// is verified. The witness' own cosignature is always verified.
+func (s *state) Load(pub *crypto.PublicKey, origin string, logPub *crypto.PublicKey) error {
 	f, err := os.Open(s.fileName)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
@@ -202,7 +505,7 @@ func (s *state) Load(pub, logPub *crypto.PublicKey) error {
 	if err := cth.FromASCII(f); err != nil {
 		return err
 	}
-	if !cth.Verify(logPub) {
+	if logPub != nil && !cth.Verify(logPub) {
 		return fmt.Errorf("Invalid log signature on stored tree head")
 	}
 
@@ -210,13 +513,18 @@ func (s *state) Load(pub, logPub *crypto.PublicKey) error {
 	if !ok {
 		return fmt.Errorf("No matching cosignature on stored tree head")
 	}
-	if !cs.Verify(pub, types.SigsumCheckpointOrigin(logPub), &cth.TreeHead) {
+	if !cs.Verify(pub, origin, &cth.TreeHead) {
 		return fmt.Errorf("Invalid cosignature on stored tree head")
 	}
 	s.th = cth.SignedTreeHead.TreeHead
+	s.size.Store(s.th.Size)
 	return nil
 }
 
+func (s *state) Size() uint64 {
+	return s.size.Load()
+}
+
 // Must be called with lock held.
 func (s *state) Store(cth *types.CosignedTreeHead) error {
 	if cth.Size < s.th.Size {
@@ -236,7 +544,9 @@ func (s *state) Store(cth *types.CosignedTreeHead) error {
 	return f.Commit()
 }
 
-// On success, returns stored cosignature. On failure, returns HTTP status code and error.
+// The cosign function is called with the lock held, and only if the
+// new tree head is consistent with the current state. On success,
+// returns stored cosignature. On failure, returns HTTP status code and error.
 func (s *state) Update(sth *types.SignedTreeHead, oldSize uint64, proof *types.ConsistencyProof, keyHash *crypto.Hash,
 	cosign func() (types.Cosignature, error)) (types.Cosignature, error) {
 
@@ -263,6 +573,7 @@ func (s *state) Update(sth *types.SignedTreeHead, oldSize uint64, proof *types.C
 		return types.Cosignature{}, err
 	}
 	s.th = sth.TreeHead
+	s.size.Store(s.th.Size)
 
 	return cs, nil
 }
@@ -3,33 +3,74 @@ package ssh
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"sync"
+	"time"
 
 	"sigsum.org/sigsum-go/pkg/crypto"
 )
 
 const (
-	sshAgentEnv          = "SSH_AUTH_SOCK"
-	sshAgentFailure      = 5
-	sshAgentSignRequest  = 13
-	sshAgentSignResponse = 14
+	sshAgentEnv                = "SSH_AUTH_SOCK"
+	sshAgentFailure            = 5
+	sshAgentIdentitiesRequest  = 11
+	sshAgentIdentitiesResponse = 12
+	sshAgentSignRequest        = 13
+	sshAgentSignResponse       = 14
+
+	// Large enough for listing a few dozen keys of any type.
+	maxResponseLength = 64 * 1024
 )
 
+var errAgentRefused = errors.New("ssh-agent refused signature request")
+
+// Timeout for each request to the agent, so that a hung agent doesn't
+// block the caller forever.
+var requestTimeout = 30 * time.Second
+
+// Failure communicating with the agent. The connection can't be used
+// after such an error, e.g., since the response may be partially
+// read.
+type connectionError struct {
+	err error
+}
+
+func (e *connectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *connectionError) Unwrap() error {
+	return e.err
+}
+
 type Connection struct {
 	conn io.ReadWriter
+	// Socket name, if connected using ConnectTo. Used by Signer
+	// to reconnect.
+	sockName string
 }
 
+// A Signer uses a private key held by ssh-agent. If the connection
+// fails, e.g., because the agent has been restarted, the signer
+// reconnects to the same socket, so that it can be used by long
+// running servers.
 type Signer struct {
 	publicKey crypto.PublicKey
-	conn      *Connection
+	// Protects conn, which is replaced when reconnecting.
+	m    sync.Mutex
+	conn *Connection
 }
 
 func ConnectTo(sockName string) (*Connection, error) {
 	conn, err := net.Dial("unix", sockName)
-	return &Connection{conn: conn}, err
+	if err != nil {
+		return nil, err
+	}
+	return &Connection{conn: conn, sockName: sockName}, nil
 }
 
 func Connect() (*Connection, error) {
@@ -40,6 +81,20 @@ func Connect() (*Connection, error) {
 }
 
 func (c *Connection) request(msg []byte) ([]byte, error) {
+	if conn, ok := c.conn.(interface{ SetDeadline(time.Time) error }); ok {
+		if err := conn.SetDeadline(time.Now().Add(requestTimeout)); err != nil {
+			return nil, &connectionError{err}
+		}
+	}
+	response, err := c.exchange(msg)
+	if err != nil {
+		return nil, &connectionError{err}
+	}
+	return response, nil
+}
+
+// Writes a request, and reads the response.
+func (c *Connection) exchange(msg []byte) ([]byte, error) {
 	request := serializeString(msg)
 	_, err := c.conn.Write(request)
 	if err != nil {
@@ -52,7 +107,7 @@ func (c *Connection) request(msg []byte) ([]byte, error) {
 		return nil, err
 	}
 	length := binary.BigEndian.Uint32(lenBuf)
-	if length == 0 || length > 1000 {
+	if length == 0 || length > maxResponseLength {
 		return nil, fmt.Errorf("read from agent gave unexpected length: %d", length)
 	}
 	buffer := make([]byte, length)
@@ -76,7 +131,7 @@ func (c Connection) SignEd25519(publicKey *crypto.PublicKey, msg []byte) (crypto
 
 	switch msgType, body := buffer[0], buffer[1:]; msgType {
 	case sshAgentFailure:
-		return crypto.Signature{}, fmt.Errorf("ssh-agent refused signature request")
+		return crypto.Signature{}, errAgentRefused
 	case sshAgentSignResponse:
 		return parseSignature(body)
 	default:
@@ -84,20 +139,118 @@ func (c Connection) SignEd25519(publicKey *crypto.PublicKey, msg []byte) (crypto
 	}
 }
 
+// Returns the Ed25519 keys held by the agent. Keys of other types
+// are ignored.
+func (c *Connection) ListEd25519Keys() ([]crypto.PublicKey, error) {
+	buffer, err := c.request([]byte{sshAgentIdentitiesRequest})
+	if err != nil {
+		return nil, err
+	}
+	switch msgType, body := buffer[0], buffer[1:]; msgType {
+	case sshAgentFailure:
+		return nil, fmt.Errorf("ssh-agent refused to list keys")
+	case sshAgentIdentitiesResponse:
+		return parseIdentities(body)
+	default:
+		return nil, fmt.Errorf("unexpected ssh-agent response, type %d", msgType)
+	}
+}
+
+// Fails with a descriptive error if the agent doesn't hold the
+// private key corresponding to publicKey.
+func (c *Connection) checkKey(publicKey *crypto.PublicKey) error {
+	keys, err := c.ListEd25519Keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key == *publicKey {
+			return nil
+		}
+	}
+	return fmt.Errorf("ssh-agent does not hold the private key for public key with key hash %x",
+		crypto.HashBytes(publicKey[:]))
+}
+
+func (c *Connection) Close() error {
+	if closer, ok := c.conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Creates a signer, after checking that the agent holds the private
+// key corresponding to publicKey.
 func (c Connection) NewSigner(publicKey *crypto.PublicKey) (*Signer, error) {
-	// TODO: Use SSH_AGENTC_REQUEST_IDENIFIER to list public keys,
-	// and fail if given key is not on the list.
+	if err := c.checkKey(publicKey); err != nil {
+		return nil, err
+	}
 	return &Signer{publicKey: *publicKey, conn: &c}, nil
 }
 
 func (s *Signer) Sign(message []byte) (crypto.Signature, error) {
-	return s.conn.SignEd25519(&s.publicKey, message)
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	signature, err := s.sign(message)
+	var connErr *connectionError
+	if !errors.As(err, &connErr) || len(s.conn.sockName) == 0 {
+		return signature, err
+	}
+	// Connection failure, likely because the agent has been
+	// restarted, or a timeout. Reconnect and retry once.
+	s.conn.Close()
+	conn, connectErr := ConnectTo(s.conn.sockName)
+	if connectErr != nil {
+		return crypto.Signature{}, fmt.Errorf("ssh-agent request failed: %v, and reconnecting failed: %v", err, connectErr)
+	}
+	s.conn = conn
+	return s.sign(message)
+}
+
+// Must be called with lock held.
+func (s *Signer) sign(message []byte) (crypto.Signature, error) {
+	signature, err := s.conn.SignEd25519(&s.publicKey, message)
+	if errors.Is(err, errAgentRefused) {
+		// Most likely, the key has been removed from the
+		// agent, e.g., because the agent has been restarted.
+		if err := s.conn.checkKey(&s.publicKey); err != nil {
+			return crypto.Signature{}, err
+		}
+	}
+	return signature, err
 }
 
 func (s *Signer) Public() crypto.PublicKey {
 	return s.publicKey
 }
 
+func parseIdentities(body []byte) ([]crypto.PublicKey, error) {
+	n, body := parseUint32(body)
+	if body == nil {
+		return nil, fmt.Errorf("invalid identities response")
+	}
+	var keys []crypto.PublicKey
+	for i := uint32(0); i < n; i++ {
+		var blob []byte
+		blob, body = parseString(body)
+		if body == nil {
+			return nil, fmt.Errorf("invalid identities response, key %d", i)
+		}
+		// Skip comment.
+		if _, body = parseString(body); body == nil {
+			return nil, fmt.Errorf("invalid identities response, key %d", i)
+		}
+		if key, err := parsePublicEd25519(blob); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	if len(body) > 0 {
+		return nil, fmt.Errorf("invalid identities response, trailing data")
+	}
+	return keys, nil
+}
+
 func parseSignature(blob []byte) (crypto.Signature, error) {
 	signature := skipPrefix(blob, bytes.Join([][]byte{
 		serializeUint32(83), // length of signature
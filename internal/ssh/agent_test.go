@@ -2,11 +2,17 @@ package ssh
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"sigsum.org/sigsum-go/pkg/crypto"
 )
@@ -69,7 +75,7 @@ func TestRequest(t *testing.T) {
 		if table.expWireRequest != nil {
 			mockConn.writeBuf = []byte{}
 		}
-		c := Connection{&mockConn}
+		c := Connection{conn: &mockConn}
 		response, err := c.request(table.request)
 		if err != nil {
 			if table.expResponse != nil {
@@ -110,7 +116,7 @@ func TestSignEd25519(t *testing.T) {
 	}, nil))
 
 	mockConn := mockConnection{readBuf: response, writeBuf: []byte{}}
-	c := Connection{&mockConn}
+	c := Connection{conn: &mockConn}
 
 	resp, err := c.SignEd25519(&publicKey, msg)
 	if err != nil {
@@ -140,7 +146,7 @@ func TestSignEd25519Fail(t *testing.T) {
 		{"signature parse failure", h("000000580e000000530000000b7373682d656432353531380000004084443b7c0c7fef71eaed5acd742c6cf765b4f2af4cf901adaad0b56dccbe72f42cafe3d3649a352173b7ac38a6f702050b71f5a6212c6d5a26053daca445db0a"), "invalid signature blob"},
 	} {
 		mockConn := mockConnection{readBuf: table.wireResponse, writeBuf: []byte{}}
-		c := Connection{&mockConn}
+		c := Connection{conn: &mockConn}
 
 		signature, err := c.SignEd25519(&crypto.PublicKey{}, []byte("msg"))
 		if err == nil {
@@ -150,3 +156,260 @@ func TestSignEd25519Fail(t *testing.T) {
 		}
 	}
 }
+
+func identitiesResponse(keys ...[]byte) []byte {
+	parts := [][]byte{[]byte{sshAgentIdentitiesResponse}, serializeUint32(uint32(len(keys)))}
+	for _, key := range keys {
+		parts = append(parts, serializeString(key), serializeString("comment"))
+	}
+	return serializeString(bytes.Join(parts, nil))
+}
+
+func TestListEd25519Keys(t *testing.T) {
+	publicKey := crypto.PublicKey{1, 2, 3}
+	otherKey := bytes.Join([][]byte{serializeString("ssh-rsa"), serializeString("xyz")}, nil)
+	mockConn := mockConnection{
+		readBuf:  identitiesResponse(otherKey, serializePublicEd25519(&publicKey)),
+		writeBuf: []byte{},
+	}
+	c := Connection{conn: &mockConn}
+	keys, err := c.ListEd25519Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != publicKey {
+		t.Errorf("unexpected keys: %x", keys)
+	}
+	if got, want := mockConn.writeBuf, h("000000010b"); !bytes.Equal(got, want) {
+		t.Errorf("unexpected request on the wire, got %x, wanted %x", got, want)
+	}
+
+	for _, response := range [][]byte{
+		h("0000000105"),
+		h("000000020c00"),
+		h("000000090c00000001000000ff"),
+		h("000000060c000000000a"),
+	} {
+		c := Connection{conn: &mockConnection{readBuf: response, writeBuf: []byte{}}}
+		if keys, err := c.ListEd25519Keys(); err == nil {
+			t.Errorf("unexpected success for response %x, got keys %x", response, keys)
+		}
+	}
+}
+
+func TestNewSignerMissingKey(t *testing.T) {
+	publicKey := crypto.PublicKey{1, 2, 3}
+	c := Connection{conn: &mockConnection{readBuf: identitiesResponse(), writeBuf: []byte{}}}
+	_, err := c.NewSigner(&publicKey)
+	if err == nil || !strings.Contains(err.Error(), "does not hold") {
+		t.Errorf("expected error for missing key, got: %v", err)
+	}
+}
+
+// A minimal ssh-agent, listening on a unix socket, holding the
+// given keys.
+type fakeAgent struct {
+	listener net.Listener
+	m        sync.Mutex
+	signers  []crypto.Signer
+	conns    []net.Conn
+	// If set, requests are read but never answered.
+	hang bool
+}
+
+func startFakeAgent(t *testing.T, sockName string, signers ...crypto.Signer) *fakeAgent {
+	t.Helper()
+	listener, err := net.Listen("unix", sockName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := fakeAgent{listener: listener, signers: signers}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			a.m.Lock()
+			a.conns = append(a.conns, conn)
+			a.m.Unlock()
+			go a.serve(conn)
+		}
+	}()
+	return &a
+}
+
+func (a *fakeAgent) serve(conn net.Conn) {
+	c := Connection{conn: conn}
+	for {
+		lenBuf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, lenBuf); err != nil {
+			return
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(lenBuf))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		a.m.Lock()
+		signers, hang := a.signers, a.hang
+		a.m.Unlock()
+		if hang {
+			continue
+		}
+		var response []byte
+		switch msg[0] {
+		case sshAgentIdentitiesRequest:
+			var keys [][]byte
+			for _, signer := range signers {
+				pub := signer.Public()
+				keys = append(keys, serializePublicEd25519(&pub))
+			}
+			response = identitiesResponse(keys...)
+		case sshAgentSignRequest:
+			response = serializeString([]byte{sshAgentFailure})
+			blob, rest := parseString(msg[1:])
+			data, _ := parseString(rest)
+			for _, signer := range signers {
+				pub := signer.Public()
+				if !bytes.Equal(blob, serializePublicEd25519(&pub)) {
+					continue
+				}
+				signature, _ := signer.Sign(data)
+				response = serializeString(bytes.Join([][]byte{
+					[]byte{sshAgentSignResponse},
+					serializeString(bytes.Join([][]byte{
+						serializeString("ssh-ed25519"),
+						serializeString(signature[:]),
+					}, nil)),
+				}, nil))
+			}
+		default:
+			response = serializeString([]byte{sshAgentFailure})
+		}
+		if _, err := c.conn.Write(response); err != nil {
+			return
+		}
+	}
+}
+
+func (a *fakeAgent) stop() {
+	a.listener.Close()
+	a.m.Lock()
+	defer a.m.Unlock()
+	for _, conn := range a.conns {
+		conn.Close()
+	}
+}
+
+func TestSignerReconnect(t *testing.T) {
+	sockName := filepath.Join(t.TempDir(), "agent.sock")
+	privateKey := crypto.NewEd25519Signer(&crypto.PrivateKey{17})
+	publicKey := privateKey.Public()
+	msg := []byte("abc")
+
+	agent := startFakeAgent(t, sockName, privateKey)
+	c, err := ConnectTo(sockName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := c.NewSigner(&publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSign := func(desc string) {
+		signature, err := signer.Sign(msg)
+		if err != nil {
+			t.Fatalf("%s: signing failed: %v", desc, err)
+		}
+		if !crypto.Verify(&publicKey, msg, &signature) {
+			t.Errorf("%s: invalid signature", desc)
+		}
+	}
+	checkSign("initial")
+
+	// Restart agent.
+	agent.stop()
+	agent = startFakeAgent(t, sockName, privateKey)
+	checkSign("after restart")
+
+	// Restart agent, without the key.
+	agent.stop()
+	agent = startFakeAgent(t, sockName)
+	defer agent.stop()
+	if _, err := signer.Sign(msg); err == nil || !strings.Contains(err.Error(), "does not hold") {
+		t.Errorf("expected error for missing key, got: %v", err)
+	}
+
+	// No agent.
+	agent.stop()
+	if _, err := signer.Sign(msg); err == nil || !strings.Contains(err.Error(), "reconnecting failed") {
+		t.Errorf("expected error for missing agent, got: %v", err)
+	}
+}
+
+func (a *fakeAgent) numConns() int {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return len(a.conns)
+}
+
+func TestSignerRefused(t *testing.T) {
+	sockName := filepath.Join(t.TempDir(), "agent.sock")
+	privateKey := crypto.NewEd25519Signer(&crypto.PrivateKey{17})
+	publicKey := privateKey.Public()
+
+	agent := startFakeAgent(t, sockName, privateKey)
+	defer agent.stop()
+	c, err := ConnectTo(sockName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := c.NewSigner(&publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Remove the key from the running agent.
+	agent.m.Lock()
+	agent.signers = nil
+	agent.m.Unlock()
+
+	if _, err := signer.Sign([]byte("abc")); err == nil || !strings.Contains(err.Error(), "does not hold") {
+		t.Errorf("expected error for missing key, got: %v", err)
+	}
+	// The connection is fine, and should be kept.
+	if got := agent.numConns(); got != 1 {
+		t.Errorf("unexpected reconnect, %d connections", got)
+	}
+}
+
+func TestSignerTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { requestTimeout = timeout }(requestTimeout)
+	requestTimeout = 100 * time.Millisecond
+
+	sockName := filepath.Join(t.TempDir(), "agent.sock")
+	privateKey := crypto.NewEd25519Signer(&crypto.PrivateKey{17})
+	publicKey := privateKey.Public()
+
+	agent := startFakeAgent(t, sockName, privateKey)
+	defer agent.stop()
+	c, err := ConnectTo(sockName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := c.NewSigner(&publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.m.Lock()
+	agent.hang = true
+	agent.m.Unlock()
+
+	var netErr net.Error
+	if _, err := signer.Sign([]byte("abc")); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected timeout, got: %v", err)
+	}
+	// Retried once, on a new connection.
+	if got := agent.numConns(); got != 2 {
+		t.Errorf("unexpected number of connections: %d", got)
+	}
+}
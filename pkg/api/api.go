@@ -5,6 +5,7 @@ import (
 	"context"
 
 	"sigsum.org/sigsum-go/pkg/checkpoint"
+	"sigsum.org/sigsum-go/pkg/crypto"
 	"sigsum.org/sigsum-go/pkg/requests"
 	"sigsum.org/sigsum-go/pkg/submit-token"
 	"sigsum.org/sigsum-go/pkg/types"
@@ -28,7 +29,26 @@ type Witness interface {
 	AddCheckpoint(context.Context, requests.AddCheckpoint) ([]checkpoint.CosignatureLine, error)
 }
 
+// Interface for a witness that cosigns checkpoints from logs that
+// aren't necessarily Sigsum logs, see checkpoint.GenericCheckpoint.
+type GenericWitness interface {
+	AddGenericCheckpoint(context.Context, requests.AddGenericCheckpoint) ([]checkpoint.CosignatureLine, error)
+}
+
+// Interface for reading a witness' history of cosigned checkpoints.
+// The returned checkpoint includes the log's signature lines and the
+// witness' cosignature line.
+type WitnessHistory interface {
+	GetCosignedCheckpoint(context.Context, requests.CosignedCheckpoint) (checkpoint.GenericCheckpoint, error)
+}
+
 // Interface for the secondary node's api.
 type Secondary interface {
 	GetSecondaryTreeHead(context.Context) (types.SignedTreeHead, error)
 }
+
+// Interface for gossip between monitors: returns the latest tree head
+// the monitor has seen for the log identified by the key hash.
+type Gossip interface {
+	GetGossipTreeHead(ctx context.Context, logKeyHash crypto.Hash) (types.SignedTreeHead, error)
+}
@@ -24,6 +24,12 @@
 // database tree" which isn't a syntactically valid key name), or
 // logs that sign their checkpoints using multiple Ed25519 signatures,
 // e.g., for key rotation.
+//
+// To witness such logs, use GenericCheckpoint instead, which lifts
+// these restrictions, and identifies the log by its origin and a set
+// of note verifier keys. Since a GenericCheckpoint is never produced
+// when parsing a Checkpoint, these relaxed rules apply only where
+// explicitly requested.
 
 package checkpoint
 
@@ -18,10 +18,20 @@ type CosignatureLine struct {
 }
 
 func (csl *CosignatureLine) ToASCII(w io.Writer) error {
+	s := csl.NoteSignature()
+	return writeNoteSignature(w, s.KeyName, s.KeyId, s.Signature)
+}
+
+// Returns the cosignature line as a generic note signature, e.g., for
+// attaching it to a GenericCheckpoint.
+func (csl *CosignatureLine) NoteSignature() NoteSignature {
 	timestamp := [8]byte{}
 	binary.BigEndian.PutUint64(timestamp[:], csl.Timestamp)
-	return writeNoteSignature(w,
-		csl.KeyName, csl.KeyId, bytes.Join([][]byte{timestamp[:], csl.Signature[:]}, nil))
+	return NoteSignature{
+		KeyName:   csl.KeyName,
+		KeyId:     csl.KeyId,
+		Signature: bytes.Join([][]byte{timestamp[:], csl.Signature[:]}, nil),
+	}
 }
 
 func CosignatureLinesFromASCII(r io.Reader) ([]CosignatureLine, error) {
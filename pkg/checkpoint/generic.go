@@ -0,0 +1,208 @@
+package checkpoint
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"sigsum.org/sigsum-go/pkg/ascii"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+// Represents a signature line of a signed note, with a signature of
+// any type.
+type NoteSignature struct {
+	KeyName   string
+	KeyId     KeyId
+	Signature []byte
+}
+
+// A GenericCheckpoint represents a checkpoint from any log conforming
+// to the checkpoint spec, without the restrictions of Checkpoint
+// listed in the package documentation: the origin can differ from
+// the log's key name, extension lines are allowed, and all signature
+// lines are retained, so that a log can sign with multiple keys,
+// e.g., during key rotation. It is intended for witnessing non-Sigsum
+// logs, where the log is identified by its origin and a list of note
+// verifier keys.
+type GenericCheckpoint struct {
+	Origin   string
+	TreeHead types.TreeHead
+	// Extension lines, without newline characters.
+	Extensions []string
+	Signatures []NoteSignature
+}
+
+// Returns the note text, i.e., the part of the checkpoint signed by
+// the log.
+func (cp *GenericCheckpoint) Body() string {
+	var b strings.Builder
+	b.WriteString(cp.TreeHead.FormatCheckpoint(cp.Origin))
+	for _, line := range cp.Extensions {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (cp *GenericCheckpoint) ToASCII(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s\n", cp.Body()); err != nil {
+		return err
+	}
+	for _, s := range cp.Signatures {
+		if err := writeNoteSignature(w, s.KeyName, s.KeyId, s.Signature); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cp *GenericCheckpoint) Parse(p *ascii.LineReader) error {
+	origin, err := p.GetLine()
+	if err != nil {
+		return err
+	}
+	if len(origin) == 0 {
+		return fmt.Errorf("invalid checkpoint, empty origin")
+	}
+	cp.Origin = origin
+
+	sizeLine, err := p.GetLine()
+	if err != nil {
+		return err
+	}
+	cp.TreeHead.Size, err = ascii.IntFromDecimal(sizeLine)
+	if err != nil {
+		return err
+	}
+	hashLine, err := p.GetLine()
+	if err != nil {
+		return err
+	}
+	cp.TreeHead.RootHash, err = crypto.HashFromBase64(hashLine)
+	if err != nil {
+		return fmt.Errorf("invalid checkpoint, bad root hash %q: %v", hashLine, err)
+	}
+
+	cp.Extensions = nil
+	for {
+		line, err := p.GetLine()
+		if err != nil {
+			return fmt.Errorf("invalid checkpoint, no empty line after checkpoint body: %v", err)
+		}
+		if len(line) == 0 {
+			break
+		}
+		cp.Extensions = append(cp.Extensions, line)
+	}
+
+	cp.Signatures = nil
+	signatureCount := 0
+	for {
+		line, err := p.GetLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		signatureCount++
+		if signatureCount > signatureLimit {
+			return fmt.Errorf("invalid checkpoint, too many signatures")
+		}
+		name, keyId, signature, err := parseNoteSignatureLine(line)
+		if err != nil {
+			if err != ErrUnwantedSignature {
+				return fmt.Errorf("invalid signature line %d: %s", signatureCount, err)
+			}
+			continue
+		}
+		cp.Signatures = append(cp.Signatures, NoteSignature{KeyName: name, KeyId: keyId, Signature: signature})
+	}
+	if len(cp.Signatures) == 0 {
+		return fmt.Errorf("invalid checkpoint, no signatures")
+	}
+	return nil
+}
+
+func (cp *GenericCheckpoint) FromASCII(r io.Reader) error {
+	p := ascii.NewLineReader(r)
+	return cp.Parse(&p)
+}
+
+// Verifies that at least one of the signature lines is a valid
+// Ed25519 signature by one of the verifiers, matched by key name and
+// key id. Signature lines for other keys are ignored. On success,
+// returns the first valid signature.
+func (cp *GenericCheckpoint) Verify(verifiers []NoteVerifier) (crypto.Signature, error) {
+	body := []byte(cp.Body())
+	for _, s := range cp.Signatures {
+		if len(s.Signature) != crypto.SignatureSize {
+			continue
+		}
+		var signature crypto.Signature
+		copy(signature[:], s.Signature)
+		for _, v := range verifiers {
+			if v.Type == SigTypeEd25519 && v.Name == s.KeyName && v.KeyId == s.KeyId &&
+				crypto.Verify(&v.PublicKey, body, &signature) {
+				return signature, nil
+			}
+		}
+	}
+	return crypto.Signature{}, fmt.Errorf("no valid checkpoint signature by a known key")
+}
+
+// Cosigns the checkpoint. As specified for cosignature/v1,
+// extension lines are not covered by the cosignature.
+func (cp *GenericCheckpoint) Cosign(signer crypto.Signer, timestamp uint64) (types.Cosignature, error) {
+	return cp.TreeHead.Cosign(signer, cp.Origin, timestamp)
+}
+
+// Returns the signature lines that have the size of a cosignature,
+// i.e., a timestamp and an Ed25519 signature, interpreted as
+// cosignature lines. The cosignatures are not verified.
+func (cp *GenericCheckpoint) CosignatureLines() []CosignatureLine {
+	var lines []CosignatureLine
+	for _, s := range cp.Signatures {
+		if len(s.Signature) != 8+crypto.SignatureSize {
+			continue
+		}
+		csl := CosignatureLine{
+			KeyName:     s.KeyName,
+			KeyId:       s.KeyId,
+			Cosignature: types.Cosignature{Timestamp: binary.BigEndian.Uint64(s.Signature[:8])},
+		}
+		copy(csl.Signature[:], s.Signature[8:])
+		lines = append(lines, csl)
+	}
+	return lines
+}
+
+// Returns a verified cosignature identified by public key, like
+// Checkpoint.VerifyCosignatureByKey.
+func (cp *GenericCheckpoint) VerifyCosignatureByKey(publicKey *crypto.PublicKey) (types.Cosignature, error) {
+	for _, csl := range cp.CosignatureLines() {
+		if keyId := NewWitnessKeyId(csl.KeyName, publicKey); csl.KeyId != keyId {
+			continue
+		}
+		if !csl.Cosignature.Verify(publicKey, cp.Origin, &cp.TreeHead) {
+			return types.Cosignature{}, fmt.Errorf("cosignature not valid")
+		}
+		return csl.Cosignature, nil
+	}
+	return types.Cosignature{}, fmt.Errorf("no cosignature for given key")
+}
+
+// Returns a GenericCheckpoint with the same contents, including only
+// the log's own signature line.
+func (cp *Checkpoint) Generic() GenericCheckpoint {
+	return GenericCheckpoint{
+		Origin:   cp.Origin,
+		TreeHead: cp.TreeHead,
+		Signatures: []NoteSignature{
+			NoteSignature{KeyName: cp.Origin, KeyId: cp.KeyId, Signature: cp.Signature[:]},
+		},
+	}
+}
@@ -0,0 +1,152 @@
+package checkpoint
+
+import (
+	"bytes"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+)
+
+func TestGenericCheckpointGoSumDB(t *testing.T) {
+	// See TestGoSumDBCheckpoint.
+	const (
+		dbCheckpoint = `go.sum database tree
+30055305
+mXfgRcJ0bG0j3CPdKwgGWtUzBUbX67saZGRmFuJGGsM=
+
+— sum.golang.org Az3grpgEild5qw7+5dtV13Kf1C2Xurm8q4fhdxvcsDHnqNTaxL2AFjBY+2TyGKevucFcAAlWFJJYle3EJlDCrQ+y3A8=
+`
+		noteKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8"
+	)
+	var nv NoteVerifier
+	if err := nv.FromString(noteKey); err != nil {
+		t.Fatal(err)
+	}
+	var cp GenericCheckpoint
+	if err := cp.FromASCII(bytes.NewBufferString(dbCheckpoint)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cp.Origin, "go.sum database tree"; got != want {
+		t.Errorf("unexpected origin: got %s, want: %s", got, want)
+	}
+	if _, err := cp.Verify([]NoteVerifier{nv}); err != nil {
+		t.Errorf("verifying checkpoint failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := cp.ToASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != dbCheckpoint {
+		t.Errorf("formatting roundtrip failed: got:\n%q\nwant:\n%q", got, dbCheckpoint)
+	}
+}
+
+func TestGenericCheckpointKeyRotation(t *testing.T) {
+	oldSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	newSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	otherSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
+	verifier := func(name string, signer crypto.Signer) NoteVerifier {
+		pub := signer.Public()
+		return NewNoteVerifier(name, SigTypeEd25519, &pub)
+	}
+	oldVerifier := verifier("example.org/log-2024", oldSigner)
+	newVerifier := verifier("example.org/log-2025", newSigner)
+
+	cp := GenericCheckpoint{
+		Origin:     "Example log",
+		TreeHead:   testTreeHead,
+		Extensions: []string{"extension line"},
+	}
+	sign := func(v NoteVerifier, signer crypto.Signer) {
+		signature, err := signer.Sign([]byte(cp.Body()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cp.Signatures = append(cp.Signatures, NoteSignature{KeyName: v.Name, KeyId: v.KeyId, Signature: signature[:]})
+	}
+	sign(oldVerifier, oldSigner)
+	sign(newVerifier, newSigner)
+
+	var buf bytes.Buffer
+	if err := cp.ToASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var parsed GenericCheckpoint
+	if err := parsed.FromASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Extensions) != 1 || len(parsed.Signatures) != 2 {
+		t.Fatalf("unexpected parsed checkpoint: %v", parsed)
+	}
+	for _, verifiers := range [][]NoteVerifier{
+		{oldVerifier}, {newVerifier}, {oldVerifier, newVerifier},
+	} {
+		if _, err := parsed.Verify(verifiers); err != nil {
+			t.Errorf("verifying with %v failed: %v", verifiers, err)
+		}
+	}
+	if _, err := parsed.Verify([]NoteVerifier{verifier("example.org/log-2024", otherSigner)}); err == nil {
+		t.Errorf("checkpoint accepted with wrong key")
+	}
+	// Key name must match.
+	if _, err := parsed.Verify([]NoteVerifier{verifier("example.org/other", newSigner)}); err == nil {
+		t.Errorf("checkpoint accepted with wrong key name")
+	}
+	parsed.Extensions[0] = "modified"
+	if _, err := parsed.Verify([]NoteVerifier{oldVerifier, newVerifier}); err == nil {
+		t.Errorf("checkpoint accepted with modified extension line")
+	}
+
+	// Extension lines are not covered by the cosignature.
+	witness := crypto.NewEd25519Signer(&crypto.PrivateKey{4})
+	witnessPub := witness.Public()
+	cs, err := parsed.Cosign(witness, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cs.Verify(&witnessPub, cp.Origin, &cp.TreeHead) {
+		t.Errorf("cosignature not valid")
+	}
+
+	// Attached cosignature lines survive a roundtrip, and are
+	// distinguished from the log's signature lines.
+	keyName := "example.org/witness"
+	csl := CosignatureLine{KeyName: keyName, KeyId: NewWitnessKeyId(keyName, &witnessPub), Cosignature: cs}
+	parsed.Signatures = append(parsed.Signatures, csl.NoteSignature())
+	buf.Reset()
+	if err := parsed.ToASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var cosigned GenericCheckpoint
+	if err := cosigned.FromASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := cosigned.CosignatureLines(); len(got) != 1 || got[0] != csl {
+		t.Errorf("unexpected cosignature lines: %v", got)
+	}
+	if got, err := cosigned.VerifyCosignatureByKey(&witnessPub); err != nil {
+		t.Errorf("verifying cosignature failed: %v", err)
+	} else if got != cs {
+		t.Errorf("unexpected cosignature: got %v, want %v", got, cs)
+	}
+	otherPub := otherSigner.Public()
+	if _, err := cosigned.VerifyCosignatureByKey(&otherPub); err == nil {
+		t.Errorf("cosignature accepted with wrong key")
+	}
+}
+
+func TestGenericCheckpointInvalid(t *testing.T) {
+	for _, in := range []string{
+		// No signatures.
+		"example.org/log\n10\nHA5HAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n\n",
+		// No empty line.
+		"example.org/log\n10\nHA5HAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n",
+		// Empty origin.
+		"\n10\nHA5HAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n\n— example.org/log AAAAAAAA\n",
+	} {
+		var cp GenericCheckpoint
+		if err := cp.FromASCII(bytes.NewBufferString(in)); err == nil {
+			t.Errorf("invalid checkpoint accepted: %q", in)
+		}
+	}
+}
@@ -44,8 +44,10 @@ func writeNoteSignature(w io.Writer, keyName string, keyId KeyId, signature []by
 }
 
 // Input is a single signature line, with no trailing newline
-// character. Returns key name, key id and base64-decoded signature blob.
-func parseNoteSignature(line string, signatureSize int) (string, KeyId, []byte, error) {
+// character. Returns key name, key id and base64-decoded signature
+// blob, of any size. Lines too short to include a key id result in
+// ErrUnwantedSignature.
+func parseNoteSignatureLine(line string) (string, KeyId, []byte, error) {
 	fields := strings.Split(line, " ")
 	if len(fields) != 3 || fields[0] != "\u2014" {
 		return "", KeyId{}, nil, fmt.Errorf("invalid signature line %q", line)
@@ -54,7 +56,7 @@ func parseNoteSignature(line string, signatureSize int) (string, KeyId, []byte,
 	if err != nil {
 		return "", KeyId{}, nil, err
 	}
-	if len(blob) != 4+signatureSize {
+	if len(blob) < 4 {
 		return "", KeyId{}, nil, ErrUnwantedSignature
 	}
 	var keyId KeyId
@@ -62,6 +64,19 @@ func parseNoteSignature(line string, signatureSize int) (string, KeyId, []byte,
 	return fields[1], keyId, blob[4:], nil
 }
 
+// Like parseNoteSignatureLine, but returns ErrUnwantedSignature if
+// the signature size is not as expected.
+func parseNoteSignature(line string, signatureSize int) (string, KeyId, []byte, error) {
+	name, keyId, signature, err := parseNoteSignatureLine(line)
+	if err != nil {
+		return "", KeyId{}, nil, err
+	}
+	if len(signature) != signatureSize {
+		return "", KeyId{}, nil, ErrUnwantedSignature
+	}
+	return name, keyId, signature, nil
+}
+
 func WriteEd25519Signature(w io.Writer, origin string, keyId KeyId, signature *crypto.Signature) error {
 	return writeNoteSignature(w, origin, keyId, signature[:])
 }
@@ -7,6 +7,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +16,7 @@ import (
 	"sigsum.org/sigsum-go/pkg/api"
 	"sigsum.org/sigsum-go/pkg/ascii"
 	"sigsum.org/sigsum-go/pkg/checkpoint"
+	"sigsum.org/sigsum-go/pkg/crypto"
 	"sigsum.org/sigsum-go/pkg/requests"
 	token "sigsum.org/sigsum-go/pkg/submit-token"
 	"sigsum.org/sigsum-go/pkg/types"
@@ -53,6 +55,16 @@ func (cli *Client) GetSecondaryTreeHead(ctx context.Context) (sth types.SignedTr
 	return
 }
 
+func (cli *Client) GetGossipTreeHead(ctx context.Context, logKeyHash crypto.Hash) (sth types.SignedTreeHead, err error) {
+	err = cli.get(ctx, types.EndpointGetGossipTreeHead.Path(cli.config.URL)+hex.EncodeToString(logKeyHash[:]), sth.FromASCII)
+	return
+}
+
+func (cli *Client) GetCosignedCheckpoint(ctx context.Context, req requests.CosignedCheckpoint) (cp checkpoint.GenericCheckpoint, err error) {
+	err = cli.get(ctx, req.ToURL(types.EndpointGetCosignedCheckpoint.Path(cli.config.URL)), cp.FromASCII)
+	return
+}
+
 func (cli *Client) GetTreeHead(ctx context.Context) (cth types.CosignedTreeHead, err error) {
 	err = cli.get(ctx, types.EndpointGetTreeHead.Path(cli.config.URL), cth.FromASCII)
 	return
@@ -0,0 +1,206 @@
+// The cosign package implements the log's side of the witness
+// protocol: it drives a cosigning round by sending add-checkpoint
+// requests to each witness, and assembles the returned cosignatures
+// into a cosigned tree head. See
+// https://github.com/C2SP/C2SP/blob/main/tlog-witness.md.
+package cosign
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/api"
+	"sigsum.org/sigsum-go/pkg/checkpoint"
+	"sigsum.org/sigsum-go/pkg/client"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/log"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultUserAgent = "sigsum-go cosign"
+
+	// Max number of add-checkpoint requests to a single witness
+	// in a round. Needs to be at least two, to recover after a
+	// Conflict response with the witness' current size.
+	maxAttempts = 3
+)
+
+type Witness struct {
+	PublicKey crypto.PublicKey
+	Client    api.Witness
+}
+
+// Returns a Witness for each witness in the policy that has an URL.
+func WitnessesFromPolicy(p *policy.Policy, userAgent string, httpClient *http.Client) []Witness {
+	if len(userAgent) == 0 {
+		userAgent = defaultUserAgent
+	}
+	var witnesses []Witness
+	for _, entity := range p.GetWitnessesWithUrl() {
+		witnesses = append(witnesses, Witness{
+			PublicKey: entity.PublicKey,
+			Client: client.New(client.Config{
+				UserAgent:  userAgent,
+				URL:        entity.URL,
+				HTTPClient: httpClient,
+			}),
+		})
+	}
+	return witnesses
+}
+
+type Config struct {
+	// The log's signing key.
+	Signer    crypto.Signer
+	Witnesses []Witness
+	// Used to produce the consistency proofs of add-checkpoint
+	// requests. Must support trivial proofs, as documented for
+	// api.Log.
+	GetConsistencyProof func(context.Context, requests.ConsistencyProof) (types.ConsistencyProof, error)
+	// Timeout for each witness' part of a cosigning round. Zero
+	// implies a default timeout is used.
+	Timeout time.Duration
+}
+
+func (c *Config) getTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return c.Timeout
+}
+
+// State for a single witness.
+type witnessState struct {
+	Witness
+	keyHash crypto.Hash
+	// The tree size the witness is believed to have cosigned
+	// most recently. Initially zero; corrected based on the
+	// witness' Conflict responses.
+	size uint64
+}
+
+type Collector struct {
+	config Config
+	pub    crypto.PublicKey
+	origin string
+	keyId  checkpoint.KeyId
+
+	// Serializes cosigning rounds, since each round updates the
+	// witnesses' sizes.
+	m         sync.Mutex
+	witnesses []witnessState
+}
+
+func NewCollector(config *Config) (*Collector, error) {
+	if config.Signer == nil {
+		return nil, fmt.Errorf("no log signer configured")
+	}
+	if config.GetConsistencyProof == nil {
+		return nil, fmt.Errorf("no consistency proof function configured")
+	}
+	pub := config.Signer.Public()
+	origin := types.SigsumCheckpointOrigin(&pub)
+	c := Collector{
+		config: *config,
+		pub:    pub,
+		origin: origin,
+		keyId:  checkpoint.NewLogKeyId(origin, &pub),
+	}
+	for _, w := range config.Witnesses {
+		keyHash := crypto.HashBytes(w.PublicKey[:])
+		for _, other := range c.witnesses {
+			if other.keyHash == keyHash {
+				return nil, fmt.Errorf("duplicate witness: %x", w.PublicKey)
+			}
+		}
+		c.witnesses = append(c.witnesses, witnessState{Witness: w, keyHash: keyHash})
+	}
+	return &c, nil
+}
+
+// Signs the tree head, and requests cosignatures from all witnesses
+// in parallel. Witnesses that fail are logged and omitted from the
+// result; checking that enough cosignatures were collected is left to
+// the caller, e.g., using policy.VerifyCosignedTreeHead. Fails only
+// if the log's signature can't be created.
+func (c *Collector) GetCosignedTreeHead(ctx context.Context, th *types.TreeHead) (types.CosignedTreeHead, error) {
+	sth, err := th.Sign(c.config.Signer)
+	if err != nil {
+		return types.CosignedTreeHead{}, err
+	}
+	cp := checkpoint.Checkpoint{SignedTreeHead: sth, Origin: c.origin, KeyId: c.keyId}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	cth := types.CosignedTreeHead{
+		SignedTreeHead: sth,
+		Cosignatures:   make(map[crypto.Hash]types.Cosignature),
+	}
+	var cthM sync.Mutex
+	var wg sync.WaitGroup
+	for i := range c.witnesses {
+		wg.Add(1)
+		go func(w *witnessState) {
+			defer wg.Done()
+			cs, err := c.cosign(ctx, w, &cp)
+			if err != nil {
+				log.Warning("Getting cosignature from witness %x failed: %v", w.keyHash, err)
+				return
+			}
+			cthM.Lock()
+			defer cthM.Unlock()
+			cth.Cosignatures[w.keyHash] = cs
+		}(&c.witnesses[i])
+	}
+	wg.Wait()
+	return cth, nil
+}
+
+// Runs the add-checkpoint exchange with a single witness. On
+// success, returns the verified cosignature, and updates the
+// witness' size.
+func (c *Collector) cosign(ctx context.Context, w *witnessState, cp *checkpoint.Checkpoint) (types.Cosignature, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.getTimeout())
+	defer cancel()
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if w.size > cp.Size {
+			return types.Cosignature{}, fmt.Errorf("witness tree size %d is larger than log's size %d", w.size, cp.Size)
+		}
+		proof, err := c.config.GetConsistencyProof(ctx, requests.ConsistencyProof{
+			OldSize: w.size,
+			NewSize: cp.Size,
+		})
+		if err != nil {
+			return types.Cosignature{}, fmt.Errorf("getting consistency proof %d -> %d failed: %v", w.size, cp.Size, err)
+		}
+		signatures, err := w.Client.AddCheckpoint(ctx, requests.AddCheckpoint{
+			OldSize:    w.size,
+			Proof:      proof,
+			Checkpoint: *cp,
+		})
+		if err != nil {
+			if oldSize, ok := api.ErrorConflictOldSize(err); ok {
+				log.Debug("Witness %x has tree size %d, expected %d", w.keyHash, oldSize, w.size)
+				w.size = oldSize
+				continue
+			}
+			return types.Cosignature{}, err
+		}
+		cs, err := cp.VerifyCosignatureByKey(signatures, &w.PublicKey)
+		if err != nil {
+			return types.Cosignature{}, err
+		}
+		w.size = cp.Size
+		return cs, nil
+	}
+	return types.Cosignature{}, fmt.Errorf("giving up after %d add-checkpoint attempts", maxAttempts)
+}
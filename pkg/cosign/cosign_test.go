@@ -0,0 +1,190 @@
+package cosign
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/api"
+	"sigsum.org/sigsum-go/pkg/checkpoint"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/merkle"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/server"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+// Implements api.Witness, for a single log.
+type testWitness struct {
+	signer  crypto.Signer
+	keyName string
+	logPub  crypto.PublicKey
+	th      types.TreeHead
+	broken  bool
+}
+
+func newTestWitness(i int, logPub *crypto.PublicKey) *testWitness {
+	return &testWitness{
+		signer:  crypto.NewEd25519Signer(&crypto.PrivateKey{byte(i + 10)}),
+		keyName: fmt.Sprintf("example.org/witness-%d", i),
+		logPub:  *logPub,
+		th:      types.NewEmptyTreeHead(),
+	}
+}
+
+func (w *testWitness) AddCheckpoint(_ context.Context, req requests.AddCheckpoint) ([]checkpoint.CosignatureLine, error) {
+	if w.broken {
+		return nil, fmt.Errorf("witness is broken")
+	}
+	if req.OldSize != w.th.Size {
+		return nil, api.ErrConflict.WithOldSize(w.th.Size)
+	}
+	if err := req.Checkpoint.Verify(&w.logPub); err != nil {
+		return nil, api.ErrForbidden.WithError(err)
+	}
+	if err := req.Proof.Verify(&w.th, &req.Checkpoint.TreeHead); err != nil {
+		return nil, api.ErrUnprocessableEntity.WithError(err)
+	}
+	cs, err := req.Checkpoint.Cosign(w.signer, 1000)
+	if err != nil {
+		return nil, err
+	}
+	w.th = req.Checkpoint.TreeHead
+	pub := w.signer.Public()
+	return []checkpoint.CosignatureLine{checkpoint.CosignatureLine{
+		KeyName:     w.keyName,
+		KeyId:       checkpoint.NewWitnessKeyId(w.keyName, &pub),
+		Cosignature: cs,
+	}}, nil
+}
+
+type testTree struct {
+	tree merkle.Tree
+}
+
+func (t *testTree) add(count int) types.TreeHead {
+	for i := 0; i < count; i++ {
+		var leaf [8]byte
+		binary.BigEndian.PutUint64(leaf[:], t.tree.Size())
+		h := merkle.HashLeafNode(leaf[:])
+		t.tree.AddLeafHash(&h)
+	}
+	return types.TreeHead{Size: t.tree.Size(), RootHash: t.tree.GetRootHash()}
+}
+
+func (t *testTree) getConsistencyProof(_ context.Context, req requests.ConsistencyProof) (types.ConsistencyProof, error) {
+	path, err := t.tree.ProveConsistency(req.OldSize, req.NewSize)
+	return types.ConsistencyProof{Path: path}, err
+}
+
+func TestCollector(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	logPub := logSigner.Public()
+
+	var witnesses []Witness
+	var testWitnesses []*testWitness
+	var witnessKeys []crypto.PublicKey
+	for i := 0; i < 3; i++ {
+		w := newTestWitness(i, &logPub)
+		testWitnesses = append(testWitnesses, w)
+		witnesses = append(witnesses, Witness{PublicKey: w.signer.Public(), Client: w})
+		witnessKeys = append(witnessKeys, w.signer.Public())
+	}
+	p, err := policy.NewKofNPolicy([]crypto.PublicKey{logPub}, witnessKeys, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logKeyHash := crypto.HashBytes(logPub[:])
+
+	tree := testTree{tree: merkle.NewTree()}
+	newCollector := func() *Collector {
+		c, err := NewCollector(&Config{
+			Signer:              logSigner,
+			Witnesses:           witnesses,
+			GetConsistencyProof: tree.getConsistencyProof,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	collector := newCollector()
+	for i, count := range []int{0, 1, 5, 0, 17} {
+		th := tree.add(count)
+		if i == 3 {
+			// A new collector doesn't know the
+			// witnesses' sizes.
+			collector = newCollector()
+		}
+		cth, err := collector.GetCosignedTreeHead(context.Background(), &th)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cth.TreeHead != th {
+			t.Fatalf("unexpected tree head, got %v, want %v", cth.TreeHead, th)
+		}
+		if err := p.VerifyCosignedTreeHead(&logKeyHash, &cth); err != nil {
+			t.Errorf("round %d: cosigned tree head not valid: %v", i, err)
+		}
+	}
+
+	// A single failing witness should be omitted.
+	testWitnesses[1].broken = true
+	th := tree.add(3)
+	cth, err := collector.GetCosignedTreeHead(context.Background(), &th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(cth.Cosignatures), 2; got != want {
+		t.Errorf("unexpected number of cosignatures, got %d, want %d", got, want)
+	}
+	if err := p.VerifyCosignedTreeHead(&logKeyHash, &cth); err == nil {
+		t.Errorf("expected quorum failure with a broken witness")
+	}
+}
+
+func TestCollectorHTTP(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	logPub := logSigner.Public()
+
+	w := newTestWitness(0, &logPub)
+	httpServer := httptest.NewServer(server.NewWitness(&server.Config{}, w))
+	defer httpServer.Close()
+
+	tree := testTree{tree: merkle.NewTree()}
+	// Advance the witness, so that the first request gets a
+	// Conflict response.
+	oldTh := tree.add(4)
+	w.th = oldTh
+
+	p, err := policy.NewPolicy(
+		policy.AddLog(&policy.Entity{PublicKey: logPub}),
+		policy.AddWitness("w", &policy.Entity{PublicKey: w.signer.Public(), URL: httpServer.URL}),
+		policy.SetQuorum("w"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector, err := NewCollector(&Config{
+		Signer:              logSigner,
+		Witnesses:           WitnessesFromPolicy(p, "", nil),
+		GetConsistencyProof: tree.getConsistencyProof,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	th := tree.add(3)
+	cth, err := collector.GetCosignedTreeHead(context.Background(), &th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logKeyHash := crypto.HashBytes(logPub[:])
+	if err := p.VerifyCosignedTreeHead(&logKeyHash, &cth); err != nil {
+		t.Errorf("cosigned tree head not valid: %v", err)
+	}
+	if got, want := collector.witnesses[0].size, th.Size; got != want {
+		t.Errorf("unexpected witness size, got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,435 @@
+// The evidence package defines self-contained records of misbehavior
+// by logs and witnesses, with an ASCII format suitable for passing
+// on to third parties. Each record includes the public key of the
+// misbehaving party, and can be verified without any other
+// information, except for checking that key against a trust policy.
+//
+// Some kinds of evidence are conclusive: they consist of signatures
+// that a correctly behaving log or witness would never produce.
+// Others demonstrate only that the log responded to a request with
+// an invalid proof; a third party can double check such evidence by
+// asking the log for the same proof.
+package evidence
+
+import (
+	"fmt"
+	"io"
+
+	"sigsum.org/sigsum-go/pkg/ascii"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+const EvidenceVersion = 1
+
+type Type string
+
+const (
+	// Two log signed tree heads that are not consistent.
+	TypeInconsistentTreeHeads = Type("inconsistent-tree-heads")
+	// A leaf included in the log, but with invalid signature.
+	TypeInvalidLeafSignature = Type("invalid-leaf-signature")
+	// An invalid inclusion proof from the log.
+	TypeInvalidInclusionProof = Type("invalid-inclusion-proof")
+	// A witness cosigning two different trees of the same size.
+	TypeWitnessSplitView = Type("witness-split-view")
+)
+
+type Evidence interface {
+	Type() Type
+	// The public key of the misbehaving log or witness.
+	Key() crypto.PublicKey
+	// Checks that the evidence is valid, i.e., that it is
+	// properly signed and actually demonstrates misbehavior. On
+	// success, returns whether or not the evidence is conclusive.
+	Verify() (bool, error)
+	// Writes the evidence, in the format read by FromASCII.
+	ToASCII(w io.Writer) error
+
+	// Writes and parses the type-specific part.
+	toASCII(w io.Writer) error
+	parse(p *ascii.Parser) error
+}
+
+// Reads evidence of any type.
+func FromASCII(r io.Reader) (Evidence, error) {
+	p := ascii.NewParser(r)
+	version, err := p.GetInt("version")
+	if err != nil {
+		return nil, fmt.Errorf("invalid version line: %v", err)
+	}
+	if version != EvidenceVersion {
+		return nil, fmt.Errorf("unknown version %d, wanted %d", version, EvidenceVersion)
+	}
+	t, err := p.GetValues("type", 1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type line: %v", err)
+	}
+	var e Evidence
+	switch Type(t[0]) {
+	case TypeInconsistentTreeHeads:
+		e = &InconsistentTreeHeads{}
+	case TypeInvalidLeafSignature:
+		e = &InvalidLeafSignature{}
+	case TypeInvalidInclusionProof:
+		e = &InvalidInclusionProof{}
+	case TypeWitnessSplitView:
+		e = &WitnessSplitView{}
+	default:
+		return nil, fmt.Errorf("unknown evidence type %q", t[0])
+	}
+	if err := e.parse(&p); err != nil {
+		return nil, err
+	}
+	if err := p.GetEOF(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func toASCII(w io.Writer, e Evidence) error {
+	if err := ascii.WriteInt(w, "version", EvidenceVersion); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "type=%s\n", e.Type()); err != nil {
+		return err
+	}
+	return e.toASCII(w)
+}
+
+func writeEmptyLine(w io.Writer) error {
+	_, err := fmt.Fprint(w, "\n")
+	return err
+}
+
+// Like types.ConsistencyProof.Parse, but accepts an empty path.
+func parsePath(p *ascii.Parser) ([]crypto.Hash, error) {
+	var path []crypto.Hash
+	for {
+		hash, err := p.GetHash("node_hash")
+		if err == io.EOF {
+			return path, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, hash)
+	}
+}
+
+func writePath(w io.Writer, path []crypto.Hash) error {
+	for _, hash := range path {
+		if err := ascii.WriteHash(w, "node_hash", &hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Two signed tree heads from the same log, that the log can't prove
+// consistent. Conclusive if the tree heads are of the same size, but
+// with different root hashes. Otherwise, Proof is the (invalid)
+// consistency proof the log returned when asked.
+type InconsistentTreeHeads struct {
+	LogKey crypto.PublicKey
+	// Old.Size <= New.Size
+	Old   types.SignedTreeHead
+	New   types.SignedTreeHead
+	Proof types.ConsistencyProof
+}
+
+// Orders the tree heads by size.
+func NewInconsistentTreeHeads(logKey *crypto.PublicKey, a, b *types.SignedTreeHead, proof *types.ConsistencyProof) *InconsistentTreeHeads {
+	if a.Size > b.Size {
+		a, b = b, a
+	}
+	e := InconsistentTreeHeads{LogKey: *logKey, Old: *a, New: *b}
+	if proof != nil && a.Size < b.Size {
+		e.Proof = *proof
+	}
+	return &e
+}
+
+func (e *InconsistentTreeHeads) Type() Type            { return TypeInconsistentTreeHeads }
+func (e *InconsistentTreeHeads) Key() crypto.PublicKey { return e.LogKey }
+
+func (e *InconsistentTreeHeads) Verify() (bool, error) {
+	if !e.Old.Verify(&e.LogKey) || !e.New.Verify(&e.LogKey) {
+		return false, fmt.Errorf("invalid log signature on tree head")
+	}
+	if e.Old.Size > e.New.Size {
+		return false, fmt.Errorf("invalid evidence, tree heads in wrong order")
+	}
+	if e.Old.Size == e.New.Size {
+		if e.Old.RootHash == e.New.RootHash {
+			return false, fmt.Errorf("invalid evidence, identical tree heads")
+		}
+		return true, nil
+	}
+	if e.Proof.Verify(&e.Old.TreeHead, &e.New.TreeHead) == nil {
+		return false, fmt.Errorf("invalid evidence, tree heads are consistent")
+	}
+	return false, nil
+}
+
+func (e *InconsistentTreeHeads) ToASCII(w io.Writer) error { return toASCII(w, e) }
+
+func (e *InconsistentTreeHeads) toASCII(w io.Writer) error {
+	if err := ascii.WritePublicKey(w, "log_key", &e.LogKey); err != nil {
+		return err
+	}
+	for _, sth := range []*types.SignedTreeHead{&e.Old, &e.New} {
+		if err := writeEmptyLine(w); err != nil {
+			return err
+		}
+		if err := sth.ToASCII(w); err != nil {
+			return err
+		}
+	}
+	if len(e.Proof.Path) == 0 {
+		return nil
+	}
+	if err := writeEmptyLine(w); err != nil {
+		return err
+	}
+	return writePath(w, e.Proof.Path)
+}
+
+func (e *InconsistentTreeHeads) parse(p *ascii.Parser) error {
+	var err error
+	if e.LogKey, err = p.GetPublicKey("log_key"); err != nil {
+		return err
+	}
+	for _, sth := range []*types.SignedTreeHead{&e.Old, &e.New} {
+		if err := p.GetEmptyLine(); err != nil {
+			return err
+		}
+		if err := sth.Parse(p); err != nil {
+			return err
+		}
+	}
+	if err := p.GetEmptyLine(); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	e.Proof.Path, err = parsePath(p)
+	return err
+}
+
+// A leaf, with an inclusion proof demonstrating that it is included
+// in the log, but where the signature is not valid for the submitter
+// key with the leaf's key hash. Conclusive, since a log must verify
+// the signature before accepting a leaf.
+type InvalidLeafSignature struct {
+	LogKey    crypto.PublicKey
+	SubmitKey crypto.PublicKey
+	TreeHead  types.SignedTreeHead
+	Leaf      types.Leaf
+	Inclusion types.InclusionProof
+}
+
+func (e *InvalidLeafSignature) Type() Type            { return TypeInvalidLeafSignature }
+func (e *InvalidLeafSignature) Key() crypto.PublicKey { return e.LogKey }
+
+func (e *InvalidLeafSignature) Verify() (bool, error) {
+	if !e.TreeHead.Verify(&e.LogKey) {
+		return false, fmt.Errorf("invalid log signature on tree head")
+	}
+	if crypto.HashBytes(e.SubmitKey[:]) != e.Leaf.KeyHash {
+		return false, fmt.Errorf("invalid evidence, submit key doesn't match leaf key hash")
+	}
+	leafHash := e.Leaf.ToHash()
+	if err := e.Inclusion.Verify(&leafHash, &e.TreeHead.TreeHead); err != nil {
+		return false, fmt.Errorf("invalid evidence, leaf not included: %v", err)
+	}
+	if e.Leaf.Verify(&e.SubmitKey) {
+		return false, fmt.Errorf("invalid evidence, leaf signature is valid")
+	}
+	return true, nil
+}
+
+func (e *InvalidLeafSignature) ToASCII(w io.Writer) error { return toASCII(w, e) }
+
+func (e *InvalidLeafSignature) toASCII(w io.Writer) error {
+	if err := ascii.WritePublicKey(w, "log_key", &e.LogKey); err != nil {
+		return err
+	}
+	if err := ascii.WritePublicKey(w, "submit_key", &e.SubmitKey); err != nil {
+		return err
+	}
+	return writeLeafParts(w, &e.TreeHead, &e.Leaf, &e.Inclusion)
+}
+
+func (e *InvalidLeafSignature) parse(p *ascii.Parser) error {
+	var err error
+	if e.LogKey, err = p.GetPublicKey("log_key"); err != nil {
+		return err
+	}
+	if e.SubmitKey, err = p.GetPublicKey("submit_key"); err != nil {
+		return err
+	}
+	return parseLeafParts(p, &e.TreeHead, &e.Leaf, &e.Inclusion)
+}
+
+// A leaf with an invalid inclusion proof returned by the log. Not
+// conclusive, since the leaf may not be included in the log at all.
+type InvalidInclusionProof struct {
+	LogKey    crypto.PublicKey
+	TreeHead  types.SignedTreeHead
+	Leaf      types.Leaf
+	Inclusion types.InclusionProof
+}
+
+func (e *InvalidInclusionProof) Type() Type            { return TypeInvalidInclusionProof }
+func (e *InvalidInclusionProof) Key() crypto.PublicKey { return e.LogKey }
+
+func (e *InvalidInclusionProof) Verify() (bool, error) {
+	if !e.TreeHead.Verify(&e.LogKey) {
+		return false, fmt.Errorf("invalid log signature on tree head")
+	}
+	leafHash := e.Leaf.ToHash()
+	if e.Inclusion.Verify(&leafHash, &e.TreeHead.TreeHead) == nil {
+		return false, fmt.Errorf("invalid evidence, inclusion proof is valid")
+	}
+	return false, nil
+}
+
+func (e *InvalidInclusionProof) ToASCII(w io.Writer) error { return toASCII(w, e) }
+
+func (e *InvalidInclusionProof) toASCII(w io.Writer) error {
+	if err := ascii.WritePublicKey(w, "log_key", &e.LogKey); err != nil {
+		return err
+	}
+	return writeLeafParts(w, &e.TreeHead, &e.Leaf, &e.Inclusion)
+}
+
+func (e *InvalidInclusionProof) parse(p *ascii.Parser) error {
+	var err error
+	if e.LogKey, err = p.GetPublicKey("log_key"); err != nil {
+		return err
+	}
+	return parseLeafParts(p, &e.TreeHead, &e.Leaf, &e.Inclusion)
+}
+
+func writeLeafParts(w io.Writer, sth *types.SignedTreeHead, leaf *types.Leaf, proof *types.InclusionProof) error {
+	if err := writeEmptyLine(w); err != nil {
+		return err
+	}
+	if err := sth.ToASCII(w); err != nil {
+		return err
+	}
+	if err := writeEmptyLine(w); err != nil {
+		return err
+	}
+	if err := leaf.ToASCII(w); err != nil {
+		return err
+	}
+	if err := ascii.WriteInt(w, "leaf_index", proof.LeafIndex); err != nil {
+		return err
+	}
+	return writePath(w, proof.Path)
+}
+
+func parseLeafParts(p *ascii.Parser, sth *types.SignedTreeHead, leaf *types.Leaf, proof *types.InclusionProof) error {
+	if err := p.GetEmptyLine(); err != nil {
+		return err
+	}
+	if err := sth.Parse(p); err != nil {
+		return err
+	}
+	if err := p.GetEmptyLine(); err != nil {
+		return err
+	}
+	if err := leaf.Parse(p); err != nil {
+		return err
+	}
+	var err error
+	if proof.LeafIndex, err = p.GetInt("leaf_index"); err != nil {
+		return err
+	}
+	proof.Path, err = parsePath(p)
+	return err
+}
+
+// Two cosignatures by the same witness, on different trees of the
+// same size for the same log. Conclusive, since a witness must
+// check consistency before cosigning.
+type WitnessSplitView struct {
+	LogKey     crypto.PublicKey
+	WitnessKey crypto.PublicKey
+	TreeHeads  [2]types.TreeHead
+	// Cosignatures on the respective tree heads.
+	Cosignatures [2]types.Cosignature
+}
+
+func (e *WitnessSplitView) Type() Type            { return TypeWitnessSplitView }
+func (e *WitnessSplitView) Key() crypto.PublicKey { return e.WitnessKey }
+
+func (e *WitnessSplitView) Verify() (bool, error) {
+	origin := types.SigsumCheckpointOrigin(&e.LogKey)
+	for i := range e.TreeHeads {
+		if !e.Cosignatures[i].Verify(&e.WitnessKey, origin, &e.TreeHeads[i]) {
+			return false, fmt.Errorf("invalid witness cosignature on tree head")
+		}
+	}
+	if e.TreeHeads[0].Size != e.TreeHeads[1].Size {
+		return false, fmt.Errorf("invalid evidence, tree heads of different size")
+	}
+	if e.TreeHeads[0].RootHash == e.TreeHeads[1].RootHash {
+		return false, fmt.Errorf("invalid evidence, identical tree heads")
+	}
+	return true, nil
+}
+
+func (e *WitnessSplitView) ToASCII(w io.Writer) error { return toASCII(w, e) }
+
+func (e *WitnessSplitView) toASCII(w io.Writer) error {
+	if err := ascii.WritePublicKey(w, "log_key", &e.LogKey); err != nil {
+		return err
+	}
+	if err := ascii.WritePublicKey(w, "witness_key", &e.WitnessKey); err != nil {
+		return err
+	}
+	keyHash := crypto.HashBytes(e.WitnessKey[:])
+	for i := range e.TreeHeads {
+		if err := writeEmptyLine(w); err != nil {
+			return err
+		}
+		if err := e.TreeHeads[i].ToASCII(w); err != nil {
+			return err
+		}
+		if err := e.Cosignatures[i].ToASCII(w, &keyHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *WitnessSplitView) parse(p *ascii.Parser) error {
+	var err error
+	if e.LogKey, err = p.GetPublicKey("log_key"); err != nil {
+		return err
+	}
+	if e.WitnessKey, err = p.GetPublicKey("witness_key"); err != nil {
+		return err
+	}
+	keyHash := crypto.HashBytes(e.WitnessKey[:])
+	for i := range e.TreeHeads {
+		if err := p.GetEmptyLine(); err != nil {
+			return err
+		}
+		if err := e.TreeHeads[i].Parse(p); err != nil {
+			return err
+		}
+		csKeyHash, err := e.Cosignatures[i].Parse(p)
+		if err != nil {
+			return err
+		}
+		if csKeyHash != keyHash {
+			return fmt.Errorf("unexpected key hash on cosignature line")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package evidence
+
+import (
+	"bytes"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/merkle"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+type testLog struct {
+	signer crypto.Signer
+	tree   merkle.Tree
+	leaves []types.Leaf
+}
+
+func newTestLog() *testLog {
+	return &testLog{signer: crypto.NewEd25519Signer(&crypto.PrivateKey{1}), tree: merkle.NewTree()}
+}
+
+func (l *testLog) add(t *testing.T, leaf types.Leaf) {
+	h := leaf.ToHash()
+	if !l.tree.AddLeafHash(&h) {
+		t.Fatalf("duplicate leaf")
+	}
+	l.leaves = append(l.leaves, leaf)
+}
+
+func (l *testLog) addSigned(t *testing.T, signer crypto.Signer, count int) {
+	pub := signer.Public()
+	for i := 0; i < count; i++ {
+		checksum := crypto.Hash{byte(len(l.leaves)), byte(i)}
+		signature, err := types.SignLeafChecksum(signer, &checksum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.add(t, types.Leaf{Checksum: checksum, Signature: signature, KeyHash: crypto.HashBytes(pub[:])})
+	}
+}
+
+func (l *testLog) treeHead(t *testing.T) types.SignedTreeHead {
+	th := types.TreeHead{Size: l.tree.Size(), RootHash: l.tree.GetRootHash()}
+	sth, err := th.Sign(l.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sth
+}
+
+func (l *testLog) inclusion(t *testing.T, index uint64) types.InclusionProof {
+	path, err := l.tree.ProveInclusion(index, l.tree.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.InclusionProof{LeafIndex: index, Path: path}
+}
+
+func roundTrip(t *testing.T, e Evidence) Evidence {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := e.ToASCII(&buf); err != nil {
+		t.Fatalf("ToASCII failed: %v", err)
+	}
+	ascii := buf.String()
+	parsed, err := FromASCII(&buf)
+	if err != nil {
+		t.Fatalf("FromASCII failed: %v, input:\n%s", err, ascii)
+	}
+	// Compare serialized form, since an empty path may be
+	// represented as either nil or an empty slice.
+	if err := parsed.ToASCII(&buf); err != nil {
+		t.Fatalf("ToASCII failed: %v", err)
+	}
+	if got := buf.String(); got != ascii {
+		t.Errorf("round trip failed, got:\n%s\nwant:\n%s", got, ascii)
+	}
+	return parsed
+}
+
+func checkVerify(t *testing.T, desc string, e Evidence, wantValid, wantConclusive bool) {
+	t.Helper()
+	conclusive, err := e.Verify()
+	if !wantValid {
+		if err == nil {
+			t.Errorf("%s: invalid evidence not rejected", desc)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("%s: verify failed: %v", desc, err)
+	} else if conclusive != wantConclusive {
+		t.Errorf("%s: unexpected conclusive: %v", desc, conclusive)
+	}
+}
+
+func TestInconsistentTreeHeads(t *testing.T) {
+	submitter := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	log := newTestLog()
+	fork := newTestLog()
+	logKey := log.signer.Public()
+
+	log.addSigned(t, submitter, 3)
+	fork.addSigned(t, submitter, 3)
+	// Note that the forked log uses the same signing key.
+	fork.addSigned(t, crypto.NewEd25519Signer(&crypto.PrivateKey{3}), 2)
+	log.addSigned(t, submitter, 2)
+	small := log.treeHead(t)
+	forkSmall := fork.treeHead(t)
+	log.addSigned(t, submitter, 4)
+	large := log.treeHead(t)
+
+	path, err := log.tree.ProveConsistency(small.Size, large.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof := types.ConsistencyProof{Path: path}
+
+	e := roundTrip(t, NewInconsistentTreeHeads(&logKey, &small, &forkSmall, nil))
+	checkVerify(t, "same size", e, true, true)
+	checkVerify(t, "identical", NewInconsistentTreeHeads(&logKey, &small, &small, nil), false, false)
+
+	// Order of arguments doesn't matter.
+	e = roundTrip(t, NewInconsistentTreeHeads(&logKey, &large, &forkSmall, &proof))
+	checkVerify(t, "fork, smaller", e, true, false)
+	checkVerify(t, "consistent", NewInconsistentTreeHeads(&logKey, &large, &small, &proof), false, false)
+
+	otherKey := crypto.PublicKey{1}
+	checkVerify(t, "wrong key", NewInconsistentTreeHeads(&otherKey, &small, &forkSmall, nil), false, false)
+}
+
+func TestInvalidLeafSignature(t *testing.T) {
+	submitter := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	submitKey := submitter.Public()
+	log := newTestLog()
+	logKey := log.signer.Public()
+
+	log.addSigned(t, submitter, 3)
+	bad := log.leaves[1]
+	bad.Checksum[31] ^= 1
+	log.add(t, bad)
+	log.addSigned(t, submitter, 3)
+
+	e := roundTrip(t, &InvalidLeafSignature{
+		LogKey:    logKey,
+		SubmitKey: submitKey,
+		TreeHead:  log.treeHead(t),
+		Leaf:      bad,
+		Inclusion: log.inclusion(t, 3),
+	})
+	checkVerify(t, "bad signature", e, true, true)
+
+	checkVerify(t, "good signature", &InvalidLeafSignature{
+		LogKey:    logKey,
+		SubmitKey: submitKey,
+		TreeHead:  log.treeHead(t),
+		Leaf:      log.leaves[2],
+		Inclusion: log.inclusion(t, 2),
+	}, false, false)
+	checkVerify(t, "not included", &InvalidLeafSignature{
+		LogKey:    logKey,
+		SubmitKey: submitKey,
+		TreeHead:  log.treeHead(t),
+		Leaf:      bad,
+		Inclusion: log.inclusion(t, 2),
+	}, false, false)
+}
+
+func TestInvalidInclusionProof(t *testing.T) {
+	submitter := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	log := newTestLog()
+	log.addSigned(t, submitter, 1)
+
+	// Single leaf tree, with empty inclusion proof.
+	e := roundTrip(t, &InvalidInclusionProof{
+		LogKey:    log.signer.Public(),
+		TreeHead:  log.treeHead(t),
+		Leaf:      log.leaves[0],
+		Inclusion: log.inclusion(t, 0),
+	})
+	checkVerify(t, "valid proof", e, false, false)
+
+	log.addSigned(t, submitter, 5)
+	proof := log.inclusion(t, 2)
+	proof.Path[0][0] ^= 1
+	e = roundTrip(t, &InvalidInclusionProof{
+		LogKey:    log.signer.Public(),
+		TreeHead:  log.treeHead(t),
+		Leaf:      log.leaves[2],
+		Inclusion: proof,
+	})
+	checkVerify(t, "invalid proof", e, true, false)
+}
+
+func TestWitnessSplitView(t *testing.T) {
+	logKey := crypto.PublicKey{1}
+	witness := crypto.NewEd25519Signer(&crypto.PrivateKey{4})
+	origin := types.SigsumCheckpointOrigin(&logKey)
+
+	makeEvidence := func(a, b types.TreeHead) *WitnessSplitView {
+		e := WitnessSplitView{LogKey: logKey, WitnessKey: witness.Public(), TreeHeads: [2]types.TreeHead{a, b}}
+		for i, th := range e.TreeHeads {
+			var err error
+			e.Cosignatures[i], err = th.Cosign(witness, origin, uint64(1000+i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &e
+	}
+	e := roundTrip(t, makeEvidence(types.TreeHead{Size: 5, RootHash: crypto.Hash{1}}, types.TreeHead{Size: 5, RootHash: crypto.Hash{2}}))
+	checkVerify(t, "split view", e, true, true)
+	checkVerify(t, "different sizes", makeEvidence(types.TreeHead{Size: 5, RootHash: crypto.Hash{1}}, types.TreeHead{Size: 6, RootHash: crypto.Hash{2}}), false, false)
+
+	e.(*WitnessSplitView).TreeHeads[1].RootHash[0] ^= 1
+	checkVerify(t, "bad cosignature", e, false, false)
+}
+
+func TestFromASCIIInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"version=2\ntype=inconsistent-tree-heads\n",
+		"version=1\ntype=foo\n",
+		"version=1\ntype=inconsistent-tree-heads\nlog_key=00\n",
+	} {
+		if _, err := FromASCII(bytes.NewBufferString(input)); err == nil {
+			t.Errorf("invalid input not rejected: %q", input)
+		}
+	}
+}
@@ -25,7 +25,10 @@ func ParsePublicKeyWithPolicyName(ascii string) (crypto.PublicKey, string, error
 // Supports two formats:
 //   - Openssh private key
 //   - Openssh public key, in which case ssh-agent is used to
-//     access the corresponding private key.
+//     access the corresponding private key. It is an error if the
+//     agent doesn't hold that key. If the agent connection fails,
+//     e.g., because the agent is restarted, the signer reconnects,
+//     which makes it suitable also for long-running servers.
 //   - (Deprecated) Raw hex-encoded private key (RFC 8032)
 //
 // The second output is a resulting policy name, in case a
@@ -42,7 +45,10 @@ func ParsePrivateKey(ascii string) (crypto.Signer, string, error) {
 			return nil, "", fmt.Errorf("only public key available, and no ssh-agent: %v", err)
 		}
 		signer, err := c.NewSigner(&key)
-		return signer, policyName, err
+		if err != nil {
+			return nil, "", err
+		}
+		return signer, policyName, nil
 	}
 	// ParsePublicEd25519 failed, assume private key case
 	_, signer, err := ssh.ParsePrivateKeyFile([]byte(ascii))
@@ -0,0 +1,270 @@
+// The memlog package implements a complete Sigsum log, keeping all
+// state in memory. It is intended for integration tests and local
+// development, where it can be served using server.NewLog, and it
+// is not suitable for production use: nothing is persisted, and
+// submit tokens are ignored.
+package memlog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/api"
+	"sigsum.org/sigsum-go/pkg/cosign"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/log"
+	"sigsum.org/sigsum-go/pkg/merkle"
+	"sigsum.org/sigsum-go/pkg/requests"
+	token "sigsum.org/sigsum-go/pkg/submit-token"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+const (
+	DefaultInterval = 5 * time.Second
+)
+
+type Config struct {
+	// Log's signing key.
+	Signer crypto.Signer
+	// How often pending leaves are sequenced into a new signed
+	// tree head, when using Run. Zero implies a default interval
+	// is used.
+	Interval time.Duration
+	// If non-empty, cosignatures are collected from these
+	// witnesses for each new tree head.
+	Witnesses []cosign.Witness
+}
+
+func (c *Config) getInterval() time.Duration {
+	if c.Interval <= 0 {
+		return DefaultInterval
+	}
+	return c.Interval
+}
+
+// Implements api.Log. Submitted leaves are kept in a pending list
+// until the next call to Sequence, which adds them to the tree and
+// signs a new tree head.
+type Log struct {
+	config    Config
+	collector *cosign.Collector // nil if no witnesses are configured
+
+	// Serializes calls to Sequence.
+	seqM sync.Mutex
+	// Synchronizes access to all fields below.
+	m      sync.RWMutex
+	leaves []types.Leaf
+	tree   merkle.Tree
+	// Pending leaves, in order of submission, and the
+	// corresponding set of leaf hashes, to detect duplicates.
+	pending    []types.Leaf
+	pendingSet map[crypto.Hash]struct{}
+	cth        types.CosignedTreeHead
+}
+
+// Creates a new log, with an initial signed tree head for the empty
+// tree.
+func New(config Config) (*Log, error) {
+	if config.Signer == nil {
+		return nil, fmt.Errorf("no log signer configured")
+	}
+	l := Log{
+		config:     config,
+		tree:       merkle.NewTree(),
+		pendingSet: make(map[crypto.Hash]struct{}),
+	}
+	if len(config.Witnesses) > 0 {
+		var err error
+		l.collector, err = cosign.NewCollector(&cosign.Config{
+			Signer:              config.Signer,
+			Witnesses:           config.Witnesses,
+			GetConsistencyProof: l.proveConsistency,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The initial tree head is only signed, witnesses are queried
+	// on the first call to Sequence.
+	th := types.NewEmptyTreeHead()
+	sth, err := th.Sign(config.Signer)
+	if err != nil {
+		return nil, err
+	}
+	l.cth = types.CosignedTreeHead{SignedTreeHead: sth}
+	return &l, nil
+}
+
+// Adds all pending leaves to the tree, and signs a new tree head. If
+// witnesses are configured, the tree head is cosigned before it is
+// published, and cosigning is repeated even if there are no new
+// leaves. Otherwise, if there are no new leaves, the current tree
+// head is kept.
+func (l *Log) Sequence(ctx context.Context) error {
+	l.seqM.Lock()
+	defer l.seqM.Unlock()
+
+	th, changed := l.sequencePending()
+	if !changed && l.collector == nil {
+		return nil
+	}
+	// If signing fails, the tree may be larger than the signed
+	// tree head. Such leaves are not visible until a later call
+	// succeeds.
+	var cth types.CosignedTreeHead
+	if l.collector != nil {
+		var err error
+		cth, err = l.collector.GetCosignedTreeHead(ctx, &th)
+		if err != nil {
+			return err
+		}
+	} else {
+		sth, err := th.Sign(l.config.Signer)
+		if err != nil {
+			return err
+		}
+		cth = types.CosignedTreeHead{SignedTreeHead: sth}
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.cth = cth
+	return nil
+}
+
+// Moves pending leaves into the tree. Returns the new tree head, and
+// true if it differs from the current signed tree head.
+func (l *Log) sequencePending() (types.TreeHead, bool) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	for _, leaf := range l.pending {
+		h := leaf.ToHash()
+		if l.tree.AddLeafHash(&h) {
+			l.leaves = append(l.leaves, leaf)
+		}
+	}
+	l.pending = nil
+	l.pendingSet = make(map[crypto.Hash]struct{})
+
+	return types.TreeHead{Size: l.tree.Size(), RootHash: l.tree.GetRootHash()}, l.tree.Size() != l.cth.Size
+}
+
+// Like GetConsistencyProof, but not limited by the size of the
+// current signed tree head. Used when cosigning a new tree head.
+func (l *Log) proveConsistency(_ context.Context, req requests.ConsistencyProof) (types.ConsistencyProof, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	path, err := l.tree.ProveConsistency(req.OldSize, req.NewSize)
+	if err != nil {
+		return types.ConsistencyProof{}, err
+	}
+	return types.ConsistencyProof{Path: path}, nil
+}
+
+// Sequences pending leaves once every configured interval, until ctx
+// is cancelled.
+func (l *Log) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.config.getInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Sequence(ctx); err != nil {
+				log.Error("Sequencing leaves failed: %v", err)
+			}
+		}
+	}
+}
+
+func (l *Log) GetTreeHead(_ context.Context) (types.CosignedTreeHead, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.cth, nil
+}
+
+func (l *Log) GetInclusionProof(_ context.Context, req requests.InclusionProof) (types.InclusionProof, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	if req.Size > l.cth.Size {
+		return types.InclusionProof{}, api.ErrBadRequest.WithError(
+			fmt.Errorf("size(%d) larger than current tree size(%d)", req.Size, l.cth.Size))
+	}
+	index, err := l.tree.GetLeafIndex(&req.LeafHash)
+	if err != nil || index >= req.Size {
+		return types.InclusionProof{}, api.ErrNotFound
+	}
+	path, err := l.tree.ProveInclusion(index, req.Size)
+	if err != nil {
+		return types.InclusionProof{}, err
+	}
+	return types.InclusionProof{LeafIndex: index, Path: path}, nil
+}
+
+func (l *Log) GetConsistencyProof(_ context.Context, req requests.ConsistencyProof) (types.ConsistencyProof, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	if req.NewSize > l.cth.Size {
+		return types.ConsistencyProof{}, api.ErrBadRequest.WithError(
+			fmt.Errorf("new_size(%d) larger than current tree size(%d)", req.NewSize, l.cth.Size))
+	}
+	if req.OldSize > req.NewSize {
+		return types.ConsistencyProof{}, api.ErrBadRequest.WithError(
+			fmt.Errorf("old_size(%d) larger than new_size(%d)", req.OldSize, req.NewSize))
+	}
+	path, err := l.tree.ProveConsistency(req.OldSize, req.NewSize)
+	if err != nil {
+		return types.ConsistencyProof{}, err
+	}
+	return types.ConsistencyProof{Path: path}, nil
+}
+
+// Returns the requested leaves, truncated to the current tree size.
+func (l *Log) GetLeaves(_ context.Context, req requests.Leaves) ([]types.Leaf, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	if req.StartIndex >= req.EndIndex {
+		return nil, api.ErrBadRequest.WithError(
+			fmt.Errorf("start_index(%d) must be less than end_index(%d)", req.StartIndex, req.EndIndex))
+	}
+	if req.StartIndex >= l.cth.Size {
+		return nil, api.ErrNotFound.WithError(
+			fmt.Errorf("start_index(%d) outside of current tree size(%d)", req.StartIndex, l.cth.Size))
+	}
+	return l.leaves[req.StartIndex:min(req.EndIndex, l.cth.Size)], nil
+}
+
+// Returns true if the leaf is included in the current signed tree
+// head. Otherwise the leaf is added to the list of pending leaves
+// (unless already pending), and false is returned, which
+// server.NewLog reports as api.ErrAccepted.
+func (l *Log) AddLeaf(_ context.Context, req requests.Leaf, _ *token.SubmitHeader) (bool, error) {
+	leaf, err := req.Verify()
+	if err != nil {
+		return false, api.ErrForbidden.WithError(err)
+	}
+	h := leaf.ToHash()
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if index, err := l.tree.GetLeafIndex(&h); err == nil {
+		if index < l.cth.Size {
+			return true, nil
+		}
+		// Sequenced, but not yet signed.
+		return false, nil
+	}
+	if _, ok := l.pendingSet[h]; !ok {
+		l.pendingSet[h] = struct{}{}
+		l.pending = append(l.pending, leaf)
+	}
+	return false, nil
+}
@@ -0,0 +1,224 @@
+package memlog
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+
+	"sigsum.org/sigsum-go/pkg/api"
+	"sigsum.org/sigsum-go/pkg/checkpoint"
+	"sigsum.org/sigsum-go/pkg/client"
+	"sigsum.org/sigsum-go/pkg/cosign"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/mocks/mockapi"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/server"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func newLeafRequest(t *testing.T, signer crypto.Signer, i uint64) requests.Leaf {
+	var msg crypto.Hash
+	binary.BigEndian.PutUint64(msg[:], i)
+	signature, err := types.SignLeafMessage(signer, msg[:])
+	if err != nil {
+		t.Fatalf("signing leaf failed: %v", err)
+	}
+	return requests.Leaf{Message: msg, Signature: signature, PublicKey: signer.Public()}
+}
+
+func TestLog(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	logPub := logSigner.Public()
+	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+
+	l, err := New(Config{Signer: logSigner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server.NewLog(&server.Config{}, l))
+	defer httpServer.Close()
+
+	cli := client.New(client.Config{URL: httpServer.URL})
+	ctx := context.Background()
+
+	cth, err := cli.GetTreeHead(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cth.TreeHead != types.NewEmptyTreeHead() || !cth.Verify(&logPub) {
+		t.Fatalf("unexpected initial tree head: %v", cth)
+	}
+
+	var reqs []requests.Leaf
+	prev := types.NewEmptyTreeHead()
+	for batch := uint64(0); batch < 5; batch++ {
+		for i := uint64(0); i < 3*batch+1; i++ {
+			req := newLeafRequest(t, leafSigner, uint64(len(reqs)))
+			reqs = append(reqs, req)
+			if persisted, err := cli.AddLeaf(ctx, req, nil); err != nil || persisted {
+				t.Fatalf("unexpected add-leaf result before sequencing: %v, %v", persisted, err)
+			}
+		}
+		// Duplicate of pending leaf.
+		if persisted, err := cli.AddLeaf(ctx, reqs[len(reqs)-1], nil); err != nil || persisted {
+			t.Fatalf("unexpected add-leaf result for duplicate: %v, %v", persisted, err)
+		}
+		if err := l.Sequence(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cth, err := cli.GetTreeHead(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cth.Verify(&logPub) {
+			t.Fatalf("invalid tree head signature")
+		}
+		if got, want := cth.Size, uint64(len(reqs)); got != want {
+			t.Fatalf("unexpected tree size, got %d, want %d", got, want)
+		}
+		proof, err := cli.GetConsistencyProof(ctx, requests.ConsistencyProof{OldSize: prev.Size, NewSize: cth.Size})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := proof.Verify(&prev, &cth.TreeHead); err != nil {
+			t.Fatalf("consistency proof %d -> %d not valid: %v", prev.Size, cth.Size, err)
+		}
+		for i, req := range reqs {
+			if persisted, err := cli.AddLeaf(ctx, req, nil); err != nil || !persisted {
+				t.Fatalf("unexpected add-leaf result after sequencing: %v, %v", persisted, err)
+			}
+			leaf, err := req.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			leafHash := leaf.ToHash()
+			proof, err := cli.GetInclusionProof(ctx, requests.InclusionProof{Size: cth.Size, LeafHash: leafHash})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := proof.LeafIndex, uint64(i); got != want {
+				t.Errorf("unexpected leaf index, got %d, want %d", got, want)
+			}
+			if err := proof.Verify(&leafHash, &cth.TreeHead); err != nil {
+				t.Errorf("inclusion proof for leaf %d not valid: %v", i, err)
+			}
+		}
+		prev = cth.TreeHead
+	}
+
+	leaves, err := cli.GetLeaves(ctx, requests.Leaves{StartIndex: 2, EndIndex: prev.Size + 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := uint64(len(leaves)), prev.Size-2; got != want {
+		t.Fatalf("unexpected number of leaves, got %d, want %d", got, want)
+	}
+	for i, leaf := range leaves {
+		if want, _ := reqs[i+2].Verify(); leaf != want {
+			t.Errorf("unexpected leaf %d: got %v, want %v", i+2, leaf, want)
+		}
+	}
+}
+
+func TestLogTrivialProofs(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	l, err := New(Config{Signer: logSigner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	req := newLeafRequest(t, leafSigner, 0)
+	leaf, err := req.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafHash := leaf.ToHash()
+
+	if _, err := l.GetInclusionProof(ctx, requests.InclusionProof{Size: 0, LeafHash: leafHash}); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("unexpected result for inclusion in empty tree: %v", err)
+	}
+	if proof, err := l.GetConsistencyProof(ctx, requests.ConsistencyProof{OldSize: 0, NewSize: 0}); err != nil || len(proof.Path) > 0 {
+		t.Errorf("unexpected result for empty consistency proof: %v, %v", proof, err)
+	}
+
+	if _, err := l.AddLeaf(ctx, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Sequence(ctx); err != nil {
+		t.Fatal(err)
+	}
+	proof, err := l.GetInclusionProof(ctx, requests.InclusionProof{Size: 1, LeafHash: leafHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.LeafIndex != 0 || len(proof.Path) > 0 {
+		t.Errorf("unexpected trivial inclusion proof: %v", proof)
+	}
+	for _, old := range []uint64{0, 1} {
+		if proof, err := l.GetConsistencyProof(ctx, requests.ConsistencyProof{OldSize: old, NewSize: 1}); err != nil || len(proof.Path) > 0 {
+			t.Errorf("unexpected result for trivial consistency proof %d -> 1: %v, %v", old, proof, err)
+		}
+	}
+	if _, err := l.GetConsistencyProof(ctx, requests.ConsistencyProof{OldSize: 1, NewSize: 2}); !errors.Is(err, api.ErrBadRequest) {
+		t.Errorf("unexpected result for consistency proof beyond tree size: %v", err)
+	}
+}
+
+func TestLogWithWitness(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	logPub := logSigner.Public()
+	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	witnessSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
+	witnessPub := witnessSigner.Public()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	witness := mockapi.NewMockWitness(ctrl)
+	witness.EXPECT().AddCheckpoint(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, req requests.AddCheckpoint) ([]checkpoint.CosignatureLine, error) {
+			cs, err := req.Checkpoint.Cosign(witnessSigner, 17)
+			return []checkpoint.CosignatureLine{checkpoint.CosignatureLine{
+				KeyName:     "example.org/witness",
+				KeyId:       checkpoint.NewWitnessKeyId("example.org/witness", &witnessPub),
+				Cosignature: cs,
+			}}, err
+		})
+
+	l, err := New(Config{
+		Signer:    logSigner,
+		Witnesses: []cosign.Witness{cosign.Witness{PublicKey: witnessPub, Client: witness}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := policy.NewKofNPolicy([]crypto.PublicKey{logPub}, []crypto.PublicKey{witnessPub}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logKeyHash := crypto.HashBytes(logPub[:])
+	ctx := context.Background()
+	for i := uint64(0); i < 2; i++ {
+		if _, err := l.AddLeaf(ctx, newLeafRequest(t, leafSigner, i), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Sequence(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cth, err := l.GetTreeHead(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := cth.Size, i+1; got != want {
+			t.Errorf("unexpected tree size, got %d, want %d", got, want)
+		}
+		if err := p.VerifyCosignedTreeHead(&logKeyHash, &cth); err != nil {
+			t.Errorf("cosigned tree head not valid: %v", err)
+		}
+	}
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: sigsum.org/sigsum-go/pkg/api (interfaces: Log,Secondary,Witness)
+// Source: sigsum.org/sigsum-go/pkg/api (interfaces: Log,Secondary,Witness,Gossip,WitnessHistory)
 
 // Package mockapi is a generated GoMock package.
 package mockapi
@@ -10,6 +10,7 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	checkpoint "sigsum.org/sigsum-go/pkg/checkpoint"
+	crypto "sigsum.org/sigsum-go/pkg/crypto"
 	requests "sigsum.org/sigsum-go/pkg/requests"
 	token "sigsum.org/sigsum-go/pkg/submit-token"
 	types "sigsum.org/sigsum-go/pkg/types"
@@ -188,3 +189,79 @@ func (mr *MockWitnessMockRecorder) AddCheckpoint(arg0, arg1 interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCheckpoint", reflect.TypeOf((*MockWitness)(nil).AddCheckpoint), arg0, arg1)
 }
+
+// MockGossip is a mock of Gossip interface.
+type MockGossip struct {
+	ctrl     *gomock.Controller
+	recorder *MockGossipMockRecorder
+}
+
+// MockGossipMockRecorder is the mock recorder for MockGossip.
+type MockGossipMockRecorder struct {
+	mock *MockGossip
+}
+
+// NewMockGossip creates a new mock instance.
+func NewMockGossip(ctrl *gomock.Controller) *MockGossip {
+	mock := &MockGossip{ctrl: ctrl}
+	mock.recorder = &MockGossipMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGossip) EXPECT() *MockGossipMockRecorder {
+	return m.recorder
+}
+
+// GetGossipTreeHead mocks base method.
+func (m *MockGossip) GetGossipTreeHead(arg0 context.Context, arg1 crypto.Hash) (types.SignedTreeHead, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGossipTreeHead", arg0, arg1)
+	ret0, _ := ret[0].(types.SignedTreeHead)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGossipTreeHead indicates an expected call of GetGossipTreeHead.
+func (mr *MockGossipMockRecorder) GetGossipTreeHead(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGossipTreeHead", reflect.TypeOf((*MockGossip)(nil).GetGossipTreeHead), arg0, arg1)
+}
+
+// MockWitnessHistory is a mock of WitnessHistory interface.
+type MockWitnessHistory struct {
+	ctrl     *gomock.Controller
+	recorder *MockWitnessHistoryMockRecorder
+}
+
+// MockWitnessHistoryMockRecorder is the mock recorder for MockWitnessHistory.
+type MockWitnessHistoryMockRecorder struct {
+	mock *MockWitnessHistory
+}
+
+// NewMockWitnessHistory creates a new mock instance.
+func NewMockWitnessHistory(ctrl *gomock.Controller) *MockWitnessHistory {
+	mock := &MockWitnessHistory{ctrl: ctrl}
+	mock.recorder = &MockWitnessHistoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWitnessHistory) EXPECT() *MockWitnessHistoryMockRecorder {
+	return m.recorder
+}
+
+// GetCosignedCheckpoint mocks base method.
+func (m *MockWitnessHistory) GetCosignedCheckpoint(arg0 context.Context, arg1 requests.CosignedCheckpoint) (checkpoint.GenericCheckpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCosignedCheckpoint", arg0, arg1)
+	ret0, _ := ret[0].(checkpoint.GenericCheckpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCosignedCheckpoint indicates an expected call of GetCosignedCheckpoint.
+func (mr *MockWitnessHistoryMockRecorder) GetCosignedCheckpoint(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCosignedCheckpoint", reflect.TypeOf((*MockWitnessHistory)(nil).GetCosignedCheckpoint), arg0, arg1)
+}
@@ -3,6 +3,8 @@ package monitor
 import (
 	"errors"
 	"fmt"
+
+	"sigsum.org/sigsum-go/pkg/evidence"
 )
 
 type AlertType int
@@ -14,6 +16,17 @@ const (
 	AlertLogError
 	AlertInvalidLogSignature
 	AlertInconsistentTreeHead
+	// Log's tree head is not cosigned according to the policy's
+	// quorum.
+	AlertInsufficientCosignatures
+	// Most recent cosignature on log's tree head is too old.
+	AlertStaleTreeHead
+	// Leaf signed by one of the submit keys of interest, with a
+	// checksum that is not among the expected checksums.
+	AlertUnexpectedChecksum
+	// Witness has cosigned two different tree heads of the same
+	// size.
+	AlertWitnessSplitView
 )
 
 func (t AlertType) String() string {
@@ -28,14 +41,51 @@ func (t AlertType) String() string {
 		return "Invalid log signature"
 	case AlertInconsistentTreeHead:
 		return "Log tree head not consistent"
+	case AlertInsufficientCosignatures:
+		return "Log tree head not sufficiently cosigned"
+	case AlertStaleTreeHead:
+		return "Log tree head cosignatures are stale"
+	case AlertUnexpectedChecksum:
+		return "Unexpected checksum signed by submit key"
+	case AlertWitnessSplitView:
+		return "Witness cosigned inconsistent tree heads"
 	default:
 		return fmt.Sprintf("Unknown alert type %d", t)
 	}
 }
 
+// Returns a short identifier for the alert type, intended to be
+// stable, e.g., for use in json output.
+func (t AlertType) MarshalText() ([]byte, error) {
+	switch t {
+	case AlertOther:
+		return []byte("other"), nil
+	case AlertWarning:
+		return []byte("warning"), nil
+	case AlertLogError:
+		return []byte("log-error"), nil
+	case AlertInvalidLogSignature:
+		return []byte("invalid-log-signature"), nil
+	case AlertInconsistentTreeHead:
+		return []byte("inconsistent-tree-head"), nil
+	case AlertInsufficientCosignatures:
+		return []byte("insufficient-cosignatures"), nil
+	case AlertStaleTreeHead:
+		return []byte("stale-tree-head"), nil
+	case AlertUnexpectedChecksum:
+		return []byte("unexpected-checksum"), nil
+	case AlertWitnessSplitView:
+		return []byte("witness-split-view"), nil
+	default:
+		return nil, fmt.Errorf("unknown alert type %d", t)
+	}
+}
+
 type Alert struct {
 	Type AlertType
 	Err  error
+	// Evidence of misbehavior, if available.
+	Evidence evidence.Evidence
 }
 
 func (a *Alert) Error() string {
@@ -50,6 +100,15 @@ func newAlert(t AlertType, msg string, args ...interface{}) *Alert {
 	return &Alert{Type: t, Err: fmt.Errorf(msg, args...)}
 }
 
+// Returns the evidence attached to the alert, if any.
+func ErrorEvidence(e error) evidence.Evidence {
+	var a *Alert
+	if errors.As(e, &a) {
+		return a.Evidence
+	}
+	return nil
+}
+
 func ErrorAlertType(e error) AlertType {
 	var a *Alert
 	if errors.As(e, &a) {
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+)
+
+// Reads expected checksums, for Config.ExpectedChecksums, and adds
+// them to the checksums map. If name is a directory, each regular
+// file under it is a release artifact, and the checksum is computed
+// the same way as by sigsum-submit, i.e., the leaf checksum is the
+// hash of the hash of the file. Otherwise, name is a file listing
+// the hashes of artifacts, in the format produced by sha256sum: each
+// line starts with a hex hash, and anything after that on the line
+// is ignored, as are empty lines and lines starting with "#".
+func ReadExpectedChecksums(name string, checksums map[crypto.Hash]struct{}) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return filepath.WalkDir(name, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			msg, err := crypto.HashFile(f)
+			if err != nil {
+				return fmt.Errorf("failed to hash %q: %v", path, err)
+			}
+			checksums[crypto.HashBytes(msg[:])] = struct{}{}
+			return nil
+		})
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		// sha256sum prefixes the line with a backslash if the
+		// file name needs escaping.
+		msg, err := crypto.HashFromHex(strings.TrimPrefix(fields[0], "\\"))
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid hash: %v", name, lineno, err)
+		}
+		checksums[crypto.HashBytes(msg[:])] = struct{}{}
+	}
+	return scanner.Err()
+}
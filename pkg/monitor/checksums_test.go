@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestReadExpectedChecksums(t *testing.T) {
+	dir := t.TempDir()
+	artifacts := filepath.Join(dir, "artifacts")
+	if err := os.MkdirAll(filepath.Join(artifacts, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	var sums bytes.Buffer
+	fmt.Fprintf(&sums, "# Release 1.0\n\n")
+	for i, name := range []string{"foo.tar.gz", "sub/bar.tar.gz"} {
+		data := []byte(fmt.Sprintf("artifact %d", i))
+		if err := os.WriteFile(filepath.Join(artifacts, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&sums, "%x  %s\n", crypto.HashBytes(data), name)
+	}
+	sumsFile := filepath.Join(dir, "SHA256SUMS")
+	if err := os.WriteFile(sumsFile, sums.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fromDir := make(map[crypto.Hash]struct{})
+	if err := ReadExpectedChecksums(artifacts, fromDir); err != nil {
+		t.Fatal(err)
+	}
+	fromFile := make(map[crypto.Hash]struct{})
+	if err := ReadExpectedChecksums(sumsFile, fromFile); err != nil {
+		t.Fatal(err)
+	}
+	if len(fromDir) != 2 || len(fromFile) != 2 {
+		t.Fatalf("unexpected number of checksums, dir: %d, file: %d", len(fromDir), len(fromFile))
+	}
+	// Checksums must match what's signed by sigsum-submit.
+	msg := crypto.HashBytes([]byte("artifact 0"))
+	signer := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	leaf, err := types.SignLeafMessage(signer, msg[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := signer.Public()
+	checksum := crypto.HashBytes(msg[:])
+	if !types.VerifyLeafChecksum(&pub, &checksum, &leaf) {
+		t.Fatal("unexpected leaf checksum")
+	}
+	for _, m := range []map[crypto.Hash]struct{}{fromDir, fromFile} {
+		if _, ok := m[checksum]; !ok {
+			t.Errorf("checksum %x missing", checksum)
+		}
+	}
+
+	if err := os.WriteFile(sumsFile, []byte("xyz  foo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadExpectedChecksums(sumsFile, fromFile); err == nil {
+		t.Errorf("invalid hash not rejected")
+	}
+}
+
+func TestCheckChecksums(t *testing.T) {
+	expected := crypto.Hash{1}
+	leaves := []types.Leaf{{Checksum: expected}, {Checksum: crypto.Hash{2}}}
+	indices := []uint64{4, 7}
+
+	config := Config{SubmitKeys: map[crypto.Hash]crypto.PublicKey{}}
+	if alerts := config.checkChecksums(indices, leaves); len(alerts) > 0 {
+		t.Errorf("unexpected alerts without expected checksums: %v", alerts)
+	}
+	config.ExpectedChecksums = map[crypto.Hash]struct{}{expected: struct{}{}}
+	alerts := config.checkChecksums(indices, leaves)
+	if len(alerts) != 1 {
+		t.Fatalf("unexpected number of alerts: %v", alerts)
+	}
+	if alerts[0].Type != AlertUnexpectedChecksum {
+		t.Errorf("unexpected alert: %v", alerts[0])
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"sigsum.org/sigsum-go/pkg/api"
 	"sigsum.org/sigsum-go/pkg/client"
 	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/evidence"
 	"sigsum.org/sigsum-go/pkg/merkle"
+	"sigsum.org/sigsum-go/pkg/policy"
 	"sigsum.org/sigsum-go/pkg/requests"
 	"sigsum.org/sigsum-go/pkg/types"
 )
@@ -16,40 +18,106 @@ import (
 // and it verifies consistency and inclusion of anything it returns.
 type monitoringLogClient struct {
 	logKey crypto.PublicKey // Identifies the log monitored.
-	client api.Log
+	// If non-nil, the policy's witnesses' cosignatures are
+	// verified and kept, and the quorum is checked.
+	policy    *policy.Policy
+	witnesses map[crypto.Hash]crypto.PublicKey
+	client    api.Log
 }
 
-func newMonitoringLogClient(logKey *crypto.PublicKey, URL string) *monitoringLogClient {
+func newMonitoringLogClient(logKey *crypto.PublicKey, URL string, p *policy.Policy) *monitoringLogClient {
+	witnesses := make(map[crypto.Hash]crypto.PublicKey)
+	for _, w := range p.GetWitnesses() {
+		witnesses[crypto.HashBytes(w.PublicKey[:])] = w.PublicKey
+	}
 	return &monitoringLogClient{
-		logKey: *logKey,
-		client: client.New(client.Config{URL: URL, UserAgent: "sigsum-monitor"}),
+		logKey:    *logKey,
+		policy:    p,
+		witnesses: witnesses,
+		client:    client.New(client.Config{URL: URL, UserAgent: "sigsum-monitor"}),
 	}
 }
 
 // Request log's tree head, and check that it is consistent with local
-// state. TODO: Figure out cosignatures should be processed; it would
-// make some sense to return a CosignedTreeHead but where only
-// properly verified cosignatures are kept.
-func (c *monitoringLogClient) getTreeHead(ctx context.Context, treeHead *types.TreeHead) (types.SignedTreeHead, error) {
+// state. In the returned tree head, only properly verified
+// cosignatures from known witnesses are kept; checking the policy's
+// quorum is left to the caller. The signature on the previous tree
+// head is used only for evidence, and may be missing (zero). If the
+// tree heads are inconsistent, the new tree head is returned together
+// with the alert, see witnessSplitViews.
+func (c *monitoringLogClient) getTreeHead(ctx context.Context, prev *types.SignedTreeHead) (types.CosignedTreeHead, error) {
+	treeHead := &prev.TreeHead
 	cth, err := c.client.GetTreeHead(ctx)
 	if err != nil {
-		return types.SignedTreeHead{}, newAlert(AlertLogError, "get-tree-head failed: %w", err)
+		return types.CosignedTreeHead{}, newAlert(AlertLogError, "get-tree-head failed: %w", err)
 	}
-	// For now, only check log's signature. TODO: Also check cosignatures.
 	if !cth.Verify(&c.logKey) {
-		return types.SignedTreeHead{}, newAlert(AlertInvalidLogSignature, "log signature invalid")
+		return types.CosignedTreeHead{}, newAlert(AlertInvalidLogSignature, "log signature invalid")
+	}
+	origin := types.SigsumCheckpointOrigin(&c.logKey)
+	verified := make(map[crypto.Hash]types.Cosignature)
+	for keyHash, cs := range cth.Cosignatures {
+		if key, ok := c.witnesses[keyHash]; ok && cs.Verify(&key, origin, &cth.TreeHead) {
+			verified[keyHash] = cs
+		}
 	}
+	cth.Cosignatures = verified
+
 	if cth.Size < treeHead.Size {
-		return types.SignedTreeHead{}, newAlert(AlertInconsistentTreeHead, "monitored log has shrunk, size %d, previous size %d", cth.Size, treeHead.Size)
+		if !prev.Verify(&c.logKey) {
+			return cth, newAlert(AlertInconsistentTreeHead, "monitored log has shrunk, size %d, previous size %d", cth.Size, treeHead.Size)
+		}
+		// If the log returns a proof that the smaller tree
+		// is a prefix, and it is invalid, that is evidence of
+		// a split view. Failure to get a proof, e.g., since
+		// the log no longer has the larger tree, is not.
+		proof, err := c.client.GetConsistencyProof(ctx, requests.ConsistencyProof{OldSize: cth.Size, NewSize: treeHead.Size})
+		if err != nil {
+			return cth, newAlert(AlertInconsistentTreeHead, "monitored log has shrunk, size %d, previous size %d, get-consistency-proof failed: %w", cth.Size, treeHead.Size, err)
+		}
+		alert := newAlert(AlertInconsistentTreeHead, "monitored log has shrunk, size %d, previous size %d", cth.Size, treeHead.Size)
+		if proof.Verify(&cth.TreeHead, treeHead) != nil {
+			alert.Evidence = evidence.NewInconsistentTreeHeads(&c.logKey, prev, &cth.SignedTreeHead, &proof)
+		}
+		return cth, alert
 	}
 	proof, err := c.client.GetConsistencyProof(ctx, requests.ConsistencyProof{OldSize: treeHead.Size, NewSize: cth.Size})
 	if err != nil {
-		return types.SignedTreeHead{}, newAlert(AlertLogError, "get-consistency-proof failed: %w", err)
+		return types.CosignedTreeHead{}, newAlert(AlertLogError, "get-consistency-proof failed: %w", err)
 	}
 	if err := proof.Verify(treeHead, &cth.TreeHead); err != nil {
-		return types.SignedTreeHead{}, newAlert(AlertInconsistentTreeHead, "consistency proof not valid: %w", err)
+		alert := newAlert(AlertInconsistentTreeHead, "consistency proof not valid: %w", err)
+		if prev.Verify(&c.logKey) {
+			alert.Evidence = evidence.NewInconsistentTreeHeads(&c.logKey, prev, &cth.SignedTreeHead, &proof)
+		}
+		return cth, alert
 	}
-	return cth.SignedTreeHead, nil
+	return cth, nil
+}
+
+// Returns an alert for each witness that has cosigned both tree
+// heads, if they are of the same size but with different root
+// hashes. The cosignatures must already be verified.
+func (c *monitoringLogClient) witnessSplitViews(a, b *types.CosignedTreeHead) []*Alert {
+	if a.Size != b.Size || a.RootHash == b.RootHash {
+		return nil
+	}
+	var alerts []*Alert
+	for keyHash, csA := range a.Cosignatures {
+		csB, ok := b.Cosignatures[keyHash]
+		if !ok {
+			continue
+		}
+		alert := newAlert(AlertWitnessSplitView, "witness %x cosigned different tree heads of size %d", keyHash, a.Size)
+		alert.Evidence = &evidence.WitnessSplitView{
+			LogKey:       c.logKey,
+			WitnessKey:   c.witnesses[keyHash],
+			TreeHeads:    [2]types.TreeHead{a.TreeHead, b.TreeHead},
+			Cosignatures: [2]types.Cosignature{csA, csB},
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
 }
 
 func (c *monitoringLogClient) getInclusionProofAtIndex(ctx context.Context,
@@ -65,6 +133,55 @@ func (c *monitoringLogClient) getInclusionProofAtIndex(ctx context.Context,
 	return proof, nil
 }
 
+// Creates evidence for a range of leaves, starting at startIndex,
+// that failed verification of inclusion in the tree head, by
+// requesting inclusion proofs for the individual leaves, until
+// finding one that is invalid. Returns nil if the tree head isn't
+// signed, or no invalid proof is found.
+func (c *monitoringLogClient) invalidInclusionEvidence(ctx context.Context, sth *types.SignedTreeHead,
+	startIndex uint64, leaves []types.Leaf) evidence.Evidence {
+	if !sth.Verify(&c.logKey) {
+		return nil
+	}
+	for i, leaf := range leaves {
+		leafHash := leaf.ToHash()
+		proof, err := c.getInclusionProofAtIndex(ctx, startIndex+uint64(i),
+			requests.InclusionProof{Size: sth.Size, LeafHash: leafHash})
+		if err != nil {
+			continue
+		}
+		if proof.Verify(&leafHash, &sth.TreeHead) != nil {
+			return &evidence.InvalidInclusionProof{
+				LogKey:    c.logKey,
+				TreeHead:  *sth,
+				Leaf:      leaf,
+				Inclusion: proof,
+			}
+		}
+	}
+	return nil
+}
+
+// Creates evidence for an included leaf with invalid signature. The
+// leaf must be included in the tree head. Returns nil if an inclusion
+// proof can't be retrieved.
+func (c *monitoringLogClient) invalidLeafEvidence(ctx context.Context, sth *types.SignedTreeHead,
+	index uint64, leaf *types.Leaf, submitKey *crypto.PublicKey) evidence.Evidence {
+	leafHash := leaf.ToHash()
+	proof, err := c.getInclusionProofAtIndex(ctx, index,
+		requests.InclusionProof{Size: sth.Size, LeafHash: leafHash})
+	if err != nil || proof.Verify(&leafHash, &sth.TreeHead) != nil {
+		return nil
+	}
+	return &evidence.InvalidLeafSignature{
+		LogKey:    c.logKey,
+		SubmitKey: *submitKey,
+		TreeHead:  *sth,
+		Leaf:      *leaf,
+		Inclusion: proof,
+	}
+}
+
 // Caches previous leaf hash and inclusion proof. Valid only for
 // retrieving the next range starting at LeafIndex + 1, and with the
 // same tree head.
@@ -74,12 +191,19 @@ type getLeavesState struct {
 }
 
 // Retrieves at most count leaves, starting at index, and check that
-// they are included in the latest retrieved tree head.
-func (c *monitoringLogClient) getLeaves(ctx context.Context, state *getLeavesState, treeHead *types.TreeHead, req requests.Leaves) ([]types.Leaf, *getLeavesState, error) {
+// they are included in the latest retrieved tree head. If the tree
+// head is signed, alerts for invalid inclusion proofs include
+// evidence.
+func (c *monitoringLogClient) getLeaves(ctx context.Context, state *getLeavesState, sth *types.SignedTreeHead, req requests.Leaves) ([]types.Leaf, *getLeavesState, error) {
+	treeHead := &sth.TreeHead
 	leaves, err := c.client.GetLeaves(ctx, req)
 	if err != nil {
 		return nil, nil, err
 	}
+	invalidInclusion := func(alert *Alert) *Alert {
+		alert.Evidence = c.invalidInclusionEvidence(ctx, sth, req.StartIndex, leaves)
+		return alert
+	}
 
 	start := req.StartIndex
 	end := req.StartIndex + uint64(len(leaves))
@@ -110,15 +234,15 @@ func (c *monitoringLogClient) getLeaves(ctx context.Context, state *getLeavesSta
 
 	if len(leaves) == 1 {
 		if err := proof.Verify(&leafHashes[0], treeHead); err != nil {
-			return nil, nil, newAlert(AlertLogError, "inclusion proof for leaf %d not valid", proof.LeafIndex)
+			return nil, nil, invalidInclusion(newAlert(AlertLogError, "inclusion proof for leaf %d not valid", proof.LeafIndex))
 		}
 		return leaves, &getLeavesState{leafHash: leafHashes[0], proof: proof}, nil
 	}
 
 	if end == treeHead.Size {
 		if err := merkle.VerifyInclusionTail(leafHashes, start, &treeHead.RootHash, proof.Path); err != nil {
-			return nil, nil, newAlert(AlertLogError, "inclusion proof not valid for tail range %d:%d: %w",
-				start, end, err)
+			return nil, nil, invalidInclusion(newAlert(AlertLogError, "inclusion proof not valid for tail range %d:%d: %w",
+				start, end, err))
 		}
 		return leaves, nil, nil
 	}
@@ -129,7 +253,7 @@ func (c *monitoringLogClient) getLeaves(ctx context.Context, state *getLeavesSta
 		return nil, nil, err
 	}
 	if err := merkle.VerifyInclusionBatch(leafHashes, start, treeHead.Size, &treeHead.RootHash, proof.Path, endProof.Path); err != nil {
-		return nil, nil, newAlert(AlertLogError, "inclusion proof not valid for range %d:%d: %w", start, end, err)
+		return nil, nil, invalidInclusion(newAlert(AlertLogError, "inclusion proof not valid for range %d:%d: %w", start, end, err))
 	}
 
 	return leaves, &getLeavesState{leafHash: leafHashes[len(leafHashes)-1], proof: endProof}, nil
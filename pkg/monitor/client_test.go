@@ -9,72 +9,14 @@ import (
 
 	"github.com/golang/mock/gomock"
 
-	"sigsum.org/sigsum-go/pkg/api"
 	"sigsum.org/sigsum-go/pkg/crypto"
-	"sigsum.org/sigsum-go/pkg/merkle"
+	"sigsum.org/sigsum-go/pkg/evidence"
+	"sigsum.org/sigsum-go/pkg/memlog"
 	"sigsum.org/sigsum-go/pkg/mocks/mockapi"
 	"sigsum.org/sigsum-go/pkg/requests"
-	token "sigsum.org/sigsum-go/pkg/submit-token"
 	"sigsum.org/sigsum-go/pkg/types"
 )
 
-// Implements api.Log.
-// TODO: Move to some public package, and add an RWMutex for
-// synchronization.
-type testLog struct {
-	leaves []types.Leaf
-	tree   merkle.Tree
-	signer crypto.Signer
-}
-
-func (l *testLog) GetTreeHead(_ context.Context) (types.CosignedTreeHead, error) {
-	th := types.TreeHead{
-		Size:     uint64(l.tree.Size()),
-		RootHash: l.tree.GetRootHash(),
-	}
-	sth, err := th.Sign(l.signer)
-	return types.CosignedTreeHead{SignedTreeHead: sth}, err
-}
-
-func (l *testLog) GetInclusionProof(_ context.Context, req requests.InclusionProof) (types.InclusionProof, error) {
-	index, err := l.tree.GetLeafIndex(&req.LeafHash)
-	if err != nil || index >= req.Size {
-		return types.InclusionProof{}, api.ErrNotFound
-	}
-	path, err := l.tree.ProveInclusion(index, req.Size)
-	return types.InclusionProof{
-		LeafIndex: index,
-		Path:      path,
-	}, err
-}
-
-func (l *testLog) GetConsistencyProof(_ context.Context, req requests.ConsistencyProof) (types.ConsistencyProof, error) {
-	path, err := l.tree.ProveConsistency(req.OldSize, req.NewSize)
-	return types.ConsistencyProof{Path: path}, err
-}
-
-func (l *testLog) GetLeaves(_ context.Context, req requests.Leaves) ([]types.Leaf, error) {
-	size := l.tree.Size()
-	if req.StartIndex >= size || req.EndIndex > size || req.StartIndex >= req.EndIndex {
-		return nil, fmt.Errorf("out of range request: start %d, end %d, size %d\n",
-			req.StartIndex, req.EndIndex, size)
-	}
-	return l.leaves[req.StartIndex:req.EndIndex], nil
-}
-
-func (l *testLog) AddLeaf(_ context.Context, req requests.Leaf, _ *token.SubmitHeader) (bool, error) {
-	leaf, err := req.Verify()
-	if err != nil {
-		return false, api.ErrForbidden
-	}
-
-	h := leaf.ToHash()
-	if l.tree.AddLeafHash(&h) {
-		l.leaves = append(l.leaves, leaf)
-	}
-	return true, nil
-}
-
 func makeLeafRequest(t *testing.T, signer crypto.Signer, msg *crypto.Hash) requests.Leaf {
 	signature, err := types.SignLeafMessage(signer, msg[:])
 	if err != nil {
@@ -91,31 +33,31 @@ func makeLeafRequest(t *testing.T, signer crypto.Signer, msg *crypto.Hash) reque
 func TestGetTreeHead(t *testing.T) {
 	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
 	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
-	log := testLog{signer: logSigner, tree: merkle.NewTree()}
+	log := newTestLog(t, logSigner)
 
 	monitorClient := monitoringLogClient{
 		logKey: logSigner.Public(),
-		client: &log,
+		client: log,
 	}
 	r := rand.New(rand.NewSource(10))
 
-	prevTree := types.NewEmptyTreeHead()
+	prev := types.SignedTreeHead{TreeHead: types.NewEmptyTreeHead()}
 
 	for i := 0; i < 100; i++ {
 		// Ensures that batch is of zero size, so that first
 		// GetTreeHead returns an empty tree.
 		c := uint64(r.Intn(i + 1))
-		newSize := log.tree.Size() + c
-		addLeaves(t, &log, leafSigner, uint64(i), c)
+		newSize := logSize(t, log) + c
+		addLeaves(t, log, leafSigner, uint64(i), c)
 
-		sth, err := monitorClient.getTreeHead(context.Background(), &prevTree)
+		sth, err := monitorClient.getTreeHead(context.Background(), &prev)
 		if err != nil {
 			t.Fatalf("GetTreeHead failed: %v", err)
 		}
 		if got, want := sth.Size, newSize; got != want {
 			t.Fatalf("Unexpected log size: got %d, want %d", got, want)
 		}
-		prevTree = sth.TreeHead
+		prev = sth.SignedTreeHead
 	}
 }
 
@@ -123,15 +65,15 @@ func TestGetTreeHead(t *testing.T) {
 func TestGetTreeHeadErrors(t *testing.T) {
 	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
 	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
-	log := testLog{signer: logSigner, tree: merkle.NewTree()}
+	log := newTestLog(t, logSigner)
 
-	addLeaves(t, &log, leafSigner, 0, 20)
+	addLeaves(t, log, leafSigner, 0, 20)
 	oldTh, err := log.GetTreeHead(context.Background())
 	if err != nil {
 		t.Fatalf("GetTreeHead failed: %v", err)
 	}
-	addLeaves(t, &log, leafSigner, 1, 20)
-	oneTest := func(description string, mungeTreeHead func(*types.CosignedTreeHead), mungeConsistency func(*types.ConsistencyProof)) {
+	addLeaves(t, log, leafSigner, 1, 20)
+	oneTest := func(description string, mungeTreeHead func(*types.CosignedTreeHead), mungeConsistency func(*types.ConsistencyProof)) error {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		mockLog := mockapi.NewMockLog(ctrl)
@@ -158,17 +100,18 @@ func TestGetTreeHeadErrors(t *testing.T) {
 			client: mockLog,
 		}
 
-		_, err := monitorClient.getTreeHead(context.Background(), &oldTh.TreeHead)
+		_, err := monitorClient.getTreeHead(context.Background(), &oldTh.SignedTreeHead)
 		if err == nil {
 			if description != "" {
 				t.Errorf("%s: Unexpectedly succeeded", description)
 			}
-			return
+			return nil
 		}
 		if description == "" {
 			t.Fatalf("Unexpected getTreeHead failure: %v", err)
 		}
 		t.Logf("%s: (expected) failure: %v", description, err)
+		return err
 	}
 	oneTest("", nil, nil) // No failure; checks test wireup.
 	oneTest("bad signature", func(cth *types.CosignedTreeHead) {
@@ -177,12 +120,96 @@ func TestGetTreeHeadErrors(t *testing.T) {
 	oneTest("bad signature (hash)", func(cth *types.CosignedTreeHead) {
 		cth.RootHash[5] ^= 1
 	}, nil)
+	err = oneTest("bad consistency proof", nil, func(proof *types.ConsistencyProof) {
+		proof.Path[0][3] ^= 1
+	})
+	if e := ErrorEvidence(err); e == nil {
+		t.Errorf("no evidence for bad consistency proof")
+	} else if _, err := e.Verify(); err != nil {
+		t.Errorf("invalid evidence for bad consistency proof: %v", err)
+	}
 	oldTh.Size++
-	oneTest("bad consistency", nil, nil)
+	if err := oneTest("bad consistency", nil, nil); ErrorEvidence(err) != nil {
+		// Since the old tree head's signature is invalid.
+		t.Errorf("unexpected evidence for modified tree head")
+	}
+}
+
+// Test for log returning a smaller tree head than before.
+func TestGetTreeHeadShrunk(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
+	log := newTestLog(t, logSigner)
+
+	addLeaves(t, log, leafSigner, 0, 20)
+	oldTh, err := log.GetTreeHead(context.Background())
+	if err != nil {
+		t.Fatalf("GetTreeHead failed: %v", err)
+	}
+	addLeaves(t, log, leafSigner, 1, 20)
+	newTh, err := log.GetTreeHead(context.Background())
+	if err != nil {
+		t.Fatalf("GetTreeHead failed: %v", err)
+	}
+	oneTest := func(description string, getProof func(context.Context, requests.ConsistencyProof) (types.ConsistencyProof, error)) error {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockLog := mockapi.NewMockLog(ctrl)
+
+		mockLog.EXPECT().GetTreeHead(gomock.Any()).Return(oldTh, nil)
+		mockLog.EXPECT().GetConsistencyProof(gomock.Any(), gomock.Any()).DoAndReturn(getProof)
+
+		monitorClient := monitoringLogClient{
+			logKey: logSigner.Public(),
+			client: mockLog,
+		}
+		_, err := monitorClient.getTreeHead(context.Background(), &newTh.SignedTreeHead)
+		if got, want := ErrorAlertType(err), AlertInconsistentTreeHead; got != want {
+			t.Fatalf("%s: unexpected alert type %v, want %v: %v", description, got, want, err)
+		}
+		return err
+	}
+	if err := oneTest("valid proof", log.GetConsistencyProof); ErrorEvidence(err) != nil {
+		t.Errorf("unexpected evidence for valid proof")
+	}
+	if err := oneTest("no proof", func(context.Context, requests.ConsistencyProof) (types.ConsistencyProof, error) {
+		return types.ConsistencyProof{}, fmt.Errorf("mock timeout")
+	}); ErrorEvidence(err) != nil {
+		t.Errorf("unexpected evidence for failing get-consistency-proof")
+	}
+	err = oneTest("bad proof", func(ctx context.Context, req requests.ConsistencyProof) (types.ConsistencyProof, error) {
+		proof, err := log.GetConsistencyProof(ctx, req)
+		if err == nil {
+			proof.Path[0][3] ^= 1
+		}
+		return proof, err
+	})
+	if e := ErrorEvidence(err); e == nil {
+		t.Errorf("no evidence for bad consistency proof")
+	} else if _, err := e.Verify(); err != nil {
+		t.Errorf("invalid evidence for bad consistency proof: %v", err)
+	}
+}
+
+func newTestLog(t *testing.T, signer crypto.Signer) *memlog.Log {
+	log, err := memlog.New(memlog.Config{Signer: signer})
+	if err != nil {
+		t.Fatalf("creating log failed: %v", err)
+	}
+	return log
 }
 
-func addLeaves(t *testing.T, log *testLog, signer crypto.Signer, id, count uint64) {
-	oldSize := log.tree.Size()
+func logSize(t *testing.T, log *memlog.Log) uint64 {
+	cth, err := log.GetTreeHead(context.Background())
+	if err != nil {
+		t.Fatalf("GetTreeHead failed: %v", err)
+	}
+	return cth.Size
+}
+
+// Adds leaves, and sequences them into a new tree head.
+func addLeaves(t *testing.T, log *memlog.Log, signer crypto.Signer, id, count uint64) {
+	oldSize := logSize(t, log)
 	for j := uint64(0); j < count; j++ {
 		var msg crypto.Hash
 		binary.BigEndian.PutUint64(msg[:], id)
@@ -192,7 +219,103 @@ func addLeaves(t *testing.T, log *testLog, signer crypto.Signer, id, count uint6
 			t.Fatalf("AddLeaf failed: %v", err)
 		}
 	}
-	if got, want := log.tree.Size(), oldSize+count; got != want {
+	if err := log.Sequence(context.Background()); err != nil {
+		t.Fatalf("Sequence failed: %v", err)
+	}
+	if got, want := logSize(t, log), oldSize+count; got != want {
 		t.Fatalf("Unexpected merkle tree size: got %d, want %d", got, want)
 	}
 }
+
+func TestGetLeavesInvalidInclusion(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
+	log := newTestLog(t, logSigner)
+	addLeaves(t, log, leafSigner, 0, 10)
+	cth, err := log.GetTreeHead(context.Background())
+	if err != nil {
+		t.Fatalf("GetTreeHead failed: %v", err)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLog := mockapi.NewMockLog(ctrl)
+	mockLog.EXPECT().GetLeaves(gomock.Any(), gomock.Any()).DoAndReturn(log.GetLeaves)
+	mockLog.EXPECT().GetInclusionProof(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, req requests.InclusionProof) (types.InclusionProof, error) {
+			proof, err := log.GetInclusionProof(ctx, req)
+			if err == nil && proof.LeafIndex == 3 {
+				proof.Path[0][3] ^= 1
+			}
+			return proof, err
+		})
+	monitorClient := monitoringLogClient{
+		logKey: logSigner.Public(),
+		client: mockLog,
+	}
+	_, _, err = monitorClient.getLeaves(context.Background(), nil, &cth.SignedTreeHead,
+		requests.Leaves{StartIndex: 3, EndIndex: 6})
+	if got, want := ErrorAlertType(err), AlertLogError; got != want {
+		t.Fatalf("unexpected alert type for bad inclusion proof: %v", err)
+	}
+	e, ok := ErrorEvidence(err).(*evidence.InvalidInclusionProof)
+	if !ok {
+		t.Fatalf("no evidence for bad inclusion proof: %v", err)
+	}
+	if e.Inclusion.LeafIndex != 3 {
+		t.Errorf("unexpected leaf index in evidence: %d", e.Inclusion.LeafIndex)
+	}
+	if _, err := e.Verify(); err != nil {
+		t.Errorf("invalid evidence for bad inclusion proof: %v", err)
+	}
+}
+
+func TestWitnessSplitViews(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	logPub := logSigner.Public()
+	origin := types.SigsumCheckpointOrigin(&logPub)
+	monitorClient := monitoringLogClient{
+		logKey:    logPub,
+		witnesses: make(map[crypto.Hash]crypto.PublicKey),
+	}
+	var witnessSigners []crypto.Signer
+	for i := 0; i < 3; i++ {
+		signer := crypto.NewEd25519Signer(&crypto.PrivateKey{byte(10 + i)})
+		pub := signer.Public()
+		monitorClient.witnesses[crypto.HashBytes(pub[:])] = pub
+		witnessSigners = append(witnessSigners, signer)
+	}
+	makeTreeHead := func(rootHash crypto.Hash, witnesses ...int) *types.CosignedTreeHead {
+		cth := types.CosignedTreeHead{
+			SignedTreeHead: types.SignedTreeHead{TreeHead: types.TreeHead{Size: 5, RootHash: rootHash}},
+			Cosignatures:   make(map[crypto.Hash]types.Cosignature),
+		}
+		for _, i := range witnesses {
+			cs, err := cth.TreeHead.Cosign(witnessSigners[i], origin, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub := witnessSigners[i].Public()
+			cth.Cosignatures[crypto.HashBytes(pub[:])] = cs
+		}
+		return &cth
+	}
+	a := makeTreeHead(crypto.Hash{1}, 0, 1)
+	if alerts := monitorClient.witnessSplitViews(a, makeTreeHead(crypto.Hash{1}, 1, 2)); len(alerts) > 0 {
+		t.Errorf("unexpected alerts for identical tree heads: %v", alerts)
+	}
+	alerts := monitorClient.witnessSplitViews(a, makeTreeHead(crypto.Hash{2}, 1, 2))
+	if len(alerts) != 1 || alerts[0].Type != AlertWitnessSplitView {
+		t.Fatalf("unexpected alerts for split view: %v", alerts)
+	}
+	e, ok := alerts[0].Evidence.(*evidence.WitnessSplitView)
+	if !ok {
+		t.Fatalf("no evidence for split view: %v", alerts[0])
+	}
+	if e.WitnessKey != witnessSigners[1].Public() {
+		t.Errorf("unexpected witness in evidence")
+	}
+	if conclusive, err := e.Verify(); err != nil || !conclusive {
+		t.Errorf("invalid evidence for split view: %v, conclusive: %v", err, conclusive)
+	}
+}
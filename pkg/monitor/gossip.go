@@ -0,0 +1,143 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"sigsum.org/sigsum-go/pkg/api"
+	"sigsum.org/sigsum-go/pkg/client"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/evidence"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+// A Gossip keeps track of the latest tree head seen for each log, and
+// implements api.Gossip, so that it can be served to other monitors
+// using server.NewGossip.
+type Gossip struct {
+	m         sync.Mutex
+	treeHeads map[crypto.Hash]types.SignedTreeHead
+}
+
+func NewGossip() *Gossip {
+	return &Gossip{treeHeads: make(map[crypto.Hash]types.SignedTreeHead)}
+}
+
+func (g *Gossip) GetGossipTreeHead(_ context.Context, logKeyHash crypto.Hash) (types.SignedTreeHead, error) {
+	g.m.Lock()
+	defer g.m.Unlock()
+	sth, ok := g.treeHeads[logKeyHash]
+	if !ok {
+		return types.SignedTreeHead{}, api.ErrNotFound
+	}
+	return sth, nil
+}
+
+func (g *Gossip) update(logKeyHash crypto.Hash, sth *types.SignedTreeHead) {
+	g.m.Lock()
+	defer g.m.Unlock()
+	if old, ok := g.treeHeads[logKeyHash]; !ok || sth.Size > old.Size {
+		g.treeHeads[logKeyHash] = *sth
+	}
+}
+
+// Records the tree heads of persisted monitor state, see
+// StateDirectory.Load, so that they can be served to peers before any
+// of the logs grow.
+func (g *Gossip) Restore(state map[crypto.Hash]MonitorState) {
+	for logKeyHash, s := range state {
+		g.update(logKeyHash, &types.SignedTreeHead{TreeHead: s.TreeHead, Signature: s.Signature})
+	}
+}
+
+// Returns a Callbacks that passes all calls on to the given
+// callbacks, and records each new tree head, to be served to peers.
+func (g *Gossip) Callbacks(callbacks Callbacks) Callbacks {
+	return &gossipCallbacks{g: g, callbacks: callbacks}
+}
+
+type gossipCallbacks struct {
+	g         *Gossip
+	callbacks Callbacks
+}
+
+func (c *gossipCallbacks) NewTreeHead(logKeyHash crypto.Hash, cosignedTreeHead types.CosignedTreeHead) {
+	c.g.update(logKeyHash, &cosignedTreeHead.SignedTreeHead)
+	c.callbacks.NewTreeHead(logKeyHash, cosignedTreeHead)
+}
+
+func (c *gossipCallbacks) NewLeaves(logKeyHash crypto.Hash, numberOfProcessedLeaves uint64, indices []uint64, leaves []types.Leaf) {
+	c.callbacks.NewLeaves(logKeyHash, numberOfProcessedLeaves, indices, leaves)
+}
+
+func (c *gossipCallbacks) Alert(logKeyHash crypto.Hash, e error) {
+	c.callbacks.Alert(logKeyHash, e)
+}
+
+// Another monitor, from which tree heads are requested.
+type GossipPeer struct {
+	// Used only for messages.
+	Name   string
+	Client api.Gossip
+}
+
+func NewGossipPeer(url string) GossipPeer {
+	return GossipPeer{
+		Name:   url,
+		Client: client.New(client.Config{URL: url, UserAgent: "sigsum-monitor"}),
+	}
+}
+
+// Requests the peer's latest tree head for the log, and checks that
+// it is consistent with the local tree head, asking the log for a
+// consistency proof if needed. Returns an *Alert if the log
+// misbehaves, and other errors for problems with the peer.
+func (c *monitoringLogClient) checkGossip(ctx context.Context, local *types.SignedTreeHead, peer *GossipPeer) error {
+	keyHash := crypto.HashBytes(c.logKey[:])
+	remote, err := peer.Client.GetGossipTreeHead(ctx, keyHash)
+	if err != nil {
+		if errors.Is(err, api.ErrNotFound) {
+			// Peer doesn't know about this log (yet).
+			return nil
+		}
+		return fmt.Errorf("get-gossip-tree-head from peer %q failed: %w", peer.Name, err)
+	}
+	if !remote.Verify(&c.logKey) {
+		return fmt.Errorf("invalid log signature on tree head from peer %q", peer.Name)
+	}
+	return c.checkConsistentTreeHeads(ctx, local, &remote)
+}
+
+func (c *monitoringLogClient) checkConsistentTreeHeads(ctx context.Context, local, remote *types.SignedTreeHead) error {
+	inconsistent := func(proof *types.ConsistencyProof, msg string, args ...interface{}) error {
+		alert := newAlert(AlertInconsistentTreeHead, "tree head size %d (root hash %x) is not consistent with peer's tree head size %d (root hash %x): %s",
+			local.Size, local.RootHash, remote.Size, remote.RootHash, fmt.Sprintf(msg, args...))
+		alert.Evidence = evidence.NewInconsistentTreeHeads(&c.logKey, local, remote, proof)
+		return alert
+	}
+	oldTh, newTh := &local.TreeHead, &remote.TreeHead
+	if oldTh.Size > newTh.Size {
+		oldTh, newTh = newTh, oldTh
+	}
+	if oldTh.Size == newTh.Size {
+		if oldTh.RootHash != newTh.RootHash {
+			return inconsistent(nil, "different root hashes for the same size")
+		}
+		return nil
+	}
+	proof, err := c.client.GetConsistencyProof(ctx, requests.ConsistencyProof{
+		OldSize: oldTh.Size,
+		NewSize: newTh.Size,
+	})
+	if err != nil {
+		return newAlert(AlertLogError, "get-consistency-proof %d -> %d, for peer's tree head, failed: %w",
+			oldTh.Size, newTh.Size, err)
+	}
+	if err := proof.Verify(oldTh, newTh); err != nil {
+		return inconsistent(&proof, "consistency proof not valid: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/evidence"
+	"sigsum.org/sigsum-go/pkg/server"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestGossip(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	logPub := logSigner.Public()
+	logKeyHash := crypto.HashBytes(logPub[:])
+	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
+
+	// The log's view, as seen by the local monitor.
+	log := newTestLog(t, logSigner)
+	addLeaves(t, log, leafSigner, 0, 5)
+	oldCth, err := log.GetTreeHead(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addLeaves(t, log, leafSigner, 1, 5)
+	localCth, err := log.GetTreeHead(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A different view, with the same signing key.
+	fork := newTestLog(t, logSigner)
+	addLeaves(t, fork, leafSigner, 2, 5)
+	forkCth, err := fork.GetTreeHead(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addLeaves(t, fork, leafSigner, 3, 5)
+	forkSameSizeCth, err := fork.GetTreeHead(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &monitoringLogClient{logKey: logPub, client: log}
+
+	for _, table := range []struct {
+		desc       string
+		remote     types.CosignedTreeHead
+		consistent bool
+	}{
+		{"older", oldCth, true},
+		{"same", localCth, true},
+		{"fork, smaller", forkCth, false},
+		{"fork, same size", forkSameSizeCth, false},
+	} {
+		gossip := NewGossip()
+		gossip.Callbacks(nopCallbacks{}).NewTreeHead(logKeyHash, table.remote)
+
+		// Access the peer over HTTP.
+		httpServer := httptest.NewServer(server.NewGossip(&server.Config{}, gossip))
+		peer := NewGossipPeer(httpServer.URL)
+		err := client.checkGossip(context.Background(), &localCth.SignedTreeHead, &peer)
+		httpServer.Close()
+		if table.consistent {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", table.desc, err)
+			}
+			continue
+		}
+		if got, want := ErrorAlertType(err), AlertInconsistentTreeHead; got != want {
+			t.Errorf("%s: unexpected alert type, got %v, want %v: %v", table.desc, got, want, err)
+			continue
+		}
+		e, ok := ErrorEvidence(err).(*evidence.InconsistentTreeHeads)
+		if !ok {
+			t.Errorf("%s: no evidence in alert: %v", table.desc, err)
+			continue
+		}
+		if !(e.Old == table.remote.SignedTreeHead && e.New == localCth.SignedTreeHead) &&
+			!(e.Old == localCth.SignedTreeHead && e.New == table.remote.SignedTreeHead) {
+			t.Errorf("%s: unexpected evidence: %v", table.desc, e)
+		}
+		if _, err := e.Verify(); err != nil {
+			t.Errorf("%s: invalid evidence: %v", table.desc, err)
+		}
+	}
+
+	gossip := NewGossip()
+	peer := GossipPeer{Name: "test", Client: gossip}
+	// Peer doesn't know the log.
+	if err := client.checkGossip(context.Background(), &localCth.SignedTreeHead, &peer); err != nil {
+		t.Errorf("unexpected error for unknown log: %v", err)
+	}
+	// Peer's tree head not signed by the log.
+	otherSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{4})
+	sth, err := localCth.TreeHead.Sign(otherSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gossip.update(logKeyHash, &sth)
+	err = client.checkGossip(context.Background(), &localCth.SignedTreeHead, &peer)
+	if err == nil {
+		t.Errorf("invalid signature on peer's tree head not detected")
+	} else if ErrorAlertType(err) != AlertOther {
+		t.Errorf("unexpected alert for invalid peer tree head: %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/log"
 	"sigsum.org/sigsum-go/pkg/policy"
 	"sigsum.org/sigsum-go/pkg/requests"
 	"sigsum.org/sigsum-go/pkg/types"
@@ -23,8 +24,10 @@ const (
 type Callbacks interface {
 	// Called when a log (identified by key hash) has a new tree
 	// head; application can use this to persist the tree head.
-	// TODO: Also include cosignatures?
-	NewTreeHead(logKeyHash crypto.Hash, signedTreeHead types.SignedTreeHead)
+	// Only verified cosignatures from the policy's witnesses are
+	// included, and they don't necessarily satisfy the policy's
+	// quorum (if not, an alert is raised first).
+	NewTreeHead(logKeyHash crypto.Hash, cosignedTreeHead types.CosignedTreeHead)
 	// Called when there are new leaves with submit key of
 	// interest. Includes only leaves with a known submit key, and
 	// where signature and inclusion proof are valid.
@@ -41,6 +44,9 @@ type Callbacks interface {
 
 type MonitorState struct {
 	TreeHead types.TreeHead
+	// The log's signature on TreeHead, used as evidence if the
+	// log later misbehaves. Empty for the initial empty tree.
+	Signature crypto.Signature
 	// Index of next leaf to process.
 	NextLeafIndex uint64
 }
@@ -52,7 +58,18 @@ type Config struct {
 	// Keys of interest. If nil, all keys are of interest (but no
 	// signatures are verified).
 	SubmitKeys map[crypto.Hash]crypto.PublicKey
-	Callbacks  Callbacks
+	// If non-zero, an alert is raised when the most recent
+	// verified cosignature on the log's tree head is older than
+	// this, or when there is no verified cosignature at all.
+	MaxCosignatureAge time.Duration
+	// If non-nil, an alert is raised for each leaf signed by one
+	// of the SubmitKeys, with a checksum not in this set. See
+	// ReadExpectedChecksums.
+	ExpectedChecksums map[crypto.Hash]struct{}
+	// Other monitors to compare tree heads with, to detect a log
+	// presenting different views to different parties.
+	GossipPeers []GossipPeer
+	Callbacks   Callbacks
 }
 
 func (c *Config) applyDefaults() Config {
@@ -66,8 +83,10 @@ func (c *Config) applyDefaults() Config {
 	return r
 }
 
+// The invalidLeaf callback is called for leaves with a known submit
+// key, but an invalid signature.
 func (c *Config) filterLeaves(
-	leaves []types.Leaf, startIndex uint64, alertCallback func(*Alert)) ([]uint64, []types.Leaf) {
+	leaves []types.Leaf, startIndex uint64, invalidLeaf func(uint64, *types.Leaf, *crypto.PublicKey)) ([]uint64, []types.Leaf) {
 	if c.SubmitKeys == nil {
 		indices := make([]uint64, len(leaves))
 		for i := range indices {
@@ -88,7 +107,7 @@ func (c *Config) filterLeaves(
 				// verification conditions could
 				// matter, see
 				// https://hdevalence.ca/blog/2020-10-04-its-25519am
-				alertCallback(newAlert(AlertLogError, "invalid signature on leaf %d, keyhash %x", index, leaf.KeyHash))
+				invalidLeaf(index, &leaf, &key)
 			} else {
 				matchedLeaves = append(matchedLeaves, leaf)
 				indices = append(indices, index)
@@ -98,43 +117,133 @@ func (c *Config) filterLeaves(
 	return indices, matchedLeaves
 }
 
+// Checks that the leaves' checksums, for leaves passed by
+// filterLeaves, are expected.
+func (c *Config) checkChecksums(indices []uint64, leaves []types.Leaf) []*Alert {
+	if c.ExpectedChecksums == nil || c.SubmitKeys == nil {
+		return nil
+	}
+	var alerts []*Alert
+	for i, leaf := range leaves {
+		if _, ok := c.ExpectedChecksums[leaf.Checksum]; !ok {
+			alerts = append(alerts, newAlert(AlertUnexpectedChecksum, "leaf %d, keyhash %x, has unexpected checksum %x",
+				indices[i], leaf.KeyHash, leaf.Checksum))
+		}
+	}
+	return alerts
+}
+
+// Checks that the tree head's (already verified) cosignatures
+// satisfy the policy's quorum at the time now, and that the most
+// recent one is fresh.
+func (c *Config) checkCosignatures(client *monitoringLogClient, cth *types.CosignedTreeHead, now time.Time) []*Alert {
+	if client.policy == nil {
+		return nil
+	}
+	var alerts []*Alert
+	keyHash := crypto.HashBytes(client.logKey[:])
+	if err := client.policy.VerifyCosignedTreeHeadAt(&keyHash, cth, now); err != nil {
+		alerts = append(alerts, newAlert(AlertInsufficientCosignatures, "tree head size %d: %v", cth.Size, err))
+	}
+	if c.MaxCosignatureAge > 0 {
+		if len(cth.Cosignatures) == 0 {
+			// No cosignature is as stale as it gets.
+			return append(alerts, newAlert(AlertStaleTreeHead, "tree head size %d: no valid cosignature, max age %v",
+				cth.Size, c.MaxCosignatureAge))
+		}
+		var newest uint64
+		for _, cs := range cth.Cosignatures {
+			newest = max(newest, cs.Timestamp)
+		}
+		if age := now.Sub(time.Unix(int64(newest), 0)); age > c.MaxCosignatureAge {
+			alerts = append(alerts, newAlert(AlertStaleTreeHead, "tree head size %d: most recent cosignature is %v old, max age %v",
+				cth.Size, age.Truncate(time.Second), c.MaxCosignatureAge))
+		}
+	}
+	return alerts
+}
+
 // Monitor a single sigsum log. A monitor program is expected to call
 // this function in one goroutine per log it monitors.
 func MonitorLog(ctx context.Context, client *monitoringLogClient,
 	state MonitorState, c *Config) {
 	config := c.applyDefaults()
 	keyHash := crypto.HashBytes(client.logKey[:])
+	// Most recent tree head from the log, for comparison with
+	// gossip peers, and with verified cosignatures, for detecting
+	// witness split views.
+	sth := types.SignedTreeHead{TreeHead: state.TreeHead, Signature: state.Signature}
+	var latest *types.CosignedTreeHead
+	if sth.Verify(&client.logKey) {
+		latest = &types.CosignedTreeHead{SignedTreeHead: sth}
+	}
 	for ctx.Err() == nil {
 		updateCtx, cancel := context.WithTimeout(ctx, config.QueryInterval)
-		if state.TreeHead.Size == state.NextLeafIndex {
-			cth, err := client.getTreeHead(updateCtx, &state.TreeHead)
+		if sth.Size == state.NextLeafIndex {
+			prev := &sth
+			if latest != nil {
+				prev = &latest.SignedTreeHead
+			}
+			cth, err := client.getTreeHead(updateCtx, prev)
 			if err != nil {
 				config.Callbacks.Alert(keyHash, err)
-			} else if cth.Size > state.TreeHead.Size {
-				config.Callbacks.NewTreeHead(keyHash, cth)
-				state.TreeHead = cth.TreeHead
+				if latest != nil && ErrorAlertType(err) == AlertInconsistentTreeHead {
+					for _, alert := range client.witnessSplitViews(latest, &cth) {
+						config.Callbacks.Alert(keyHash, alert)
+					}
+				}
+			} else {
+				// Leaves are processed even if
+				// cosignatures are insufficient, since
+				// the log has committed to them.
+				for _, alert := range config.checkCosignatures(client, &cth, time.Now()) {
+					config.Callbacks.Alert(keyHash, alert)
+				}
+				if cth.Size > sth.Size {
+					config.Callbacks.NewTreeHead(keyHash, cth)
+					sth = cth.SignedTreeHead
+				}
+				latest = &cth
+			}
+			if latest != nil {
+				for i := range config.GossipPeers {
+					err := client.checkGossip(updateCtx, &latest.SignedTreeHead, &config.GossipPeers[i])
+					var alert *Alert
+					if errors.As(err, &alert) {
+						config.Callbacks.Alert(keyHash, alert)
+					} else if err != nil {
+						log.Warning("Log %x: %v", keyHash, err)
+					}
+				}
 			}
 		}
-		for glState := (*getLeavesState)(nil); state.NextLeafIndex < state.TreeHead.Size; {
-			end := state.TreeHead.Size
+		for glState := (*getLeavesState)(nil); state.NextLeafIndex < sth.Size; {
+			end := sth.Size
 			if end-state.NextLeafIndex > config.BatchSize {
 				end = state.NextLeafIndex + config.BatchSize
 			}
 			var allLeaves []types.Leaf
 			var err error
-			allLeaves, glState, err = client.getLeaves(updateCtx, glState, &state.TreeHead,
+			allLeaves, glState, err = client.getLeaves(updateCtx, glState, &sth,
 				requests.Leaves{StartIndex: state.NextLeafIndex, EndIndex: end})
 			if err != nil {
 				if errors.Is(err, context.DeadlineExceeded) {
 					err = newAlert(AlertWarning, "downloading leaves timed out, backlog: %d (%d / %d)",
-						state.TreeHead.Size-state.NextLeafIndex, state.NextLeafIndex, state.TreeHead.Size)
+						sth.Size-state.NextLeafIndex, state.NextLeafIndex, sth.Size)
 				}
 				config.Callbacks.Alert(keyHash, err)
 				break
 			}
-			indices, leaves := config.filterLeaves(allLeaves, state.NextLeafIndex, func(alert *Alert) {
-				config.Callbacks.Alert(keyHash, err)
+			indices, leaves := config.filterLeaves(allLeaves, state.NextLeafIndex, func(index uint64, leaf *types.Leaf, submitKey *crypto.PublicKey) {
+				alert := newAlert(AlertLogError, "invalid signature on leaf %d, keyhash %x", index, leaf.KeyHash)
+				if latest != nil {
+					alert.Evidence = client.invalidLeafEvidence(updateCtx, &latest.SignedTreeHead, index, leaf, submitKey)
+				}
+				config.Callbacks.Alert(keyHash, alert)
 			})
+			for _, alert := range config.checkChecksums(indices, leaves) {
+				config.Callbacks.Alert(keyHash, alert)
+			}
 			state.NextLeafIndex += uint64(len(allLeaves))
 			config.Callbacks.NewLeaves(keyHash, state.NextLeafIndex, indices, leaves)
 		}
@@ -162,7 +271,7 @@ func StartMonitoring(
 
 		wg.Add(1)
 		go func(l policy.Entity) {
-			MonitorLog(ctx, newMonitoringLogClient(&l.PublicKey, l.URL), initialState, config)
+			MonitorLog(ctx, newMonitoringLogClient(&l.PublicKey, l.URL, p), initialState, config)
 			wg.Done()
 		}(l)
 	}
@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/mocks/mockapi"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestCheckCosignatures(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	logPub := logSigner.Public()
+	origin := types.SigsumCheckpointOrigin(&logPub)
+	var witnessSigners []crypto.Signer
+	var witnessKeys []crypto.PublicKey
+	for i := 0; i < 3; i++ {
+		signer := crypto.NewEd25519Signer(&crypto.PrivateKey{byte(10 + i)})
+		witnessSigners = append(witnessSigners, signer)
+		witnessKeys = append(witnessKeys, signer.Public())
+	}
+	p, err := policy.NewKofNPolicy([]crypto.PublicKey{logPub}, witnessKeys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(10000, 0)
+
+	th := types.TreeHead{Size: 3, RootHash: crypto.Hash{1}}
+	sth, err := th.Sign(logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	makeTreeHead := func(timestamps ...uint64) types.CosignedTreeHead {
+		cth := types.CosignedTreeHead{
+			SignedTreeHead: sth,
+			Cosignatures:   make(map[crypto.Hash]types.Cosignature),
+		}
+		for i, ts := range timestamps {
+			cs, err := th.Cosign(witnessSigners[i], origin, ts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cth.Cosignatures[crypto.HashBytes(witnessKeys[i][:])] = cs
+		}
+		return cth
+	}
+	// Add an invalid cosignature, and one by an unknown witness.
+	munge := func(cth types.CosignedTreeHead) types.CosignedTreeHead {
+		for _, cs := range cth.Cosignatures {
+			cs.Signature[3] ^= 1
+			cth.Cosignatures[crypto.HashBytes(witnessKeys[2][:])] = cs
+			cth.Cosignatures[crypto.Hash{17}] = cs
+			break
+		}
+		return cth
+	}
+	// Make all cosignatures invalid.
+	invalidate := func(cth types.CosignedTreeHead) types.CosignedTreeHead {
+		for keyHash, cs := range cth.Cosignatures {
+			cs.Signature[3] ^= 1
+			cth.Cosignatures[keyHash] = cs
+		}
+		return cth
+	}
+
+	for _, table := range []struct {
+		desc   string
+		cth    types.CosignedTreeHead
+		maxAge time.Duration
+		alerts []AlertType
+	}{
+		{"quorum", makeTreeHead(9990, 9999), time.Minute, nil},
+		{"no quorum", makeTreeHead(9999), time.Minute, []AlertType{AlertInsufficientCosignatures}},
+		{"no quorum, bad cosignatures", munge(makeTreeHead(9999)), time.Minute, []AlertType{AlertInsufficientCosignatures}},
+		{"stale", makeTreeHead(100, 200), time.Minute, []AlertType{AlertStaleTreeHead}},
+		{"stale, no max age", makeTreeHead(100, 200), 0, nil},
+		{"stale, no quorum", makeTreeHead(100), time.Minute, []AlertType{AlertInsufficientCosignatures, AlertStaleTreeHead}},
+		{"no cosignatures", makeTreeHead(), time.Minute, []AlertType{AlertInsufficientCosignatures, AlertStaleTreeHead}},
+		{"no valid cosignatures", invalidate(makeTreeHead(9999)), time.Minute, []AlertType{AlertInsufficientCosignatures, AlertStaleTreeHead}},
+		{"no cosignatures, no max age", makeTreeHead(), 0, []AlertType{AlertInsufficientCosignatures}},
+	} {
+		ctrl := gomock.NewController(t)
+		mockLog := mockapi.NewMockLog(ctrl)
+		mockLog.EXPECT().GetTreeHead(gomock.Any()).Return(table.cth, nil)
+		mockLog.EXPECT().GetConsistencyProof(gomock.Any(), requests.ConsistencyProof{OldSize: 0, NewSize: 3}).Return(types.ConsistencyProof{}, nil)
+
+		client := newMonitoringLogClient(&logPub, "", p)
+		client.client = mockLog
+
+		cth, err := client.getTreeHead(context.Background(), &types.SignedTreeHead{TreeHead: types.NewEmptyTreeHead()})
+		ctrl.Finish()
+		if err != nil {
+			t.Errorf("%s: getTreeHead failed: %v", table.desc, err)
+			continue
+		}
+		for keyHash, cs := range cth.Cosignatures {
+			if _, ok := table.cth.Cosignatures[keyHash]; !ok || keyHash == (crypto.Hash{17}) {
+				t.Errorf("%s: unexpected cosignature %x", table.desc, keyHash)
+			} else if !cs.Verify(&witnessKeys[0], origin, &th) && !cs.Verify(&witnessKeys[1], origin, &th) {
+				t.Errorf("%s: invalid cosignature %x not discarded", table.desc, keyHash)
+			}
+		}
+		config := Config{MaxCosignatureAge: table.maxAge}
+		alerts := config.checkCosignatures(client, &cth, now)
+		if got, want := len(alerts), len(table.alerts); got != want {
+			t.Errorf("%s: unexpected number of alerts, got %d, want %d: %v", table.desc, got, want, alerts)
+			continue
+		}
+		for i, alert := range alerts {
+			if got, want := alert.Type, table.alerts[i]; got != want {
+				t.Errorf("%s: unexpected alert type, got %v, want %v", table.desc, got, want)
+			}
+		}
+	}
+}
+
+func TestAlertTypeMarshalText(t *testing.T) {
+	seen := make(map[string]bool)
+	for alertType := AlertOther; alertType <= AlertWitnessSplitView; alertType++ {
+		text, err := alertType.MarshalText()
+		if err != nil {
+			t.Errorf("no name for alert type %d (%v)", alertType, alertType)
+			continue
+		}
+		if seen[string(text)] {
+			t.Errorf("duplicate alert type name %q", text)
+		}
+		seen[string(text)] = true
+	}
+	if _, err := (AlertWitnessSplitView + 1).MarshalText(); err == nil {
+		t.Errorf("unknown alert type not rejected")
+	}
+}
@@ -0,0 +1,316 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/evidence"
+	"sigsum.org/sigsum-go/pkg/log"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+const (
+	DefaultRepeatInterval = time.Hour
+	defaultSinkTimeout    = 30 * time.Second
+)
+
+// An alert, as delivered to an AlertSink.
+type AlertEvent struct {
+	LogKeyHash crypto.Hash
+	Type       AlertType
+	Message    string
+	Time       time.Time
+	// Number of identical alerts (same log, type and message) that
+	// were not delivered since the previous delivery.
+	Suppressed int
+	// Evidence of misbehavior, if available.
+	Evidence evidence.Evidence
+}
+
+func (e *AlertEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Event      string    `json:"event"`
+		LogKeyHash string    `json:"log_key_hash"`
+		AlertType  AlertType `json:"alert_type"`
+		Message    string    `json:"message"`
+		Time       int64     `json:"time"`
+		Suppressed int       `json:"suppressed,omitempty"`
+		Evidence   string    `json:"evidence,omitempty"`
+	}{
+		Event:      "alert",
+		LogKeyHash: hex.EncodeToString(e.LogKeyHash[:]),
+		AlertType:  e.Type,
+		Message:    e.Message,
+		Time:       e.Time.Unix(),
+		Suppressed: e.Suppressed,
+		Evidence:   e.evidenceASCII(),
+	})
+}
+
+// Returns the evidence in ASCII format, or the empty string if there
+// is no evidence.
+func (e *AlertEvent) evidenceASCII() string {
+	if e.Evidence == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := e.Evidence.ToASCII(&buf); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// An AlertSink delivers alerts to some external notification
+// mechanism.
+type AlertSink interface {
+	Notify(ctx context.Context, event *AlertEvent) error
+}
+
+// Posts each alert, in json format, to an HTTP(S) URL. Any response
+// status other than 2xx is considered a failure.
+type WebhookSink struct {
+	URL string
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+func (s *WebhookSink) Notify(ctx context.Context, event *AlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	rsp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	io.Copy(io.Discard, rsp.Body)
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("webhook %q failed: %s", s.URL, rsp.Status)
+	}
+	return nil
+}
+
+// Runs a command for each alert, with the alert in json format on
+// stdin. A non-zero exit status is considered a failure.
+type CommandSink struct {
+	// Command and arguments. No shell is involved.
+	Command []string
+}
+
+func (s *CommandSink) Notify(ctx context.Context, event *AlertEvent) error {
+	if len(s.Command) == 0 {
+		return fmt.Errorf("no command configured")
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(append(body, '\n'))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command %q failed: %v, stderr: %q", s.Command[0], err, stderr.String())
+	}
+	return nil
+}
+
+// Appends each alert as an email message to a spool file in mbox
+// format, e.g., a local user's mail spool. Messages are not
+// delivered any further.
+type MailSpoolSink struct {
+	File string
+	// Sender address, if empty, "sigsum-monitor" is used.
+	From string
+	// Optional recipient address.
+	To string
+}
+
+func (s *MailSpoolSink) Notify(_ context.Context, event *AlertEvent) error {
+	var msg bytes.Buffer
+	date := event.Time.UTC()
+	from := s.From
+	if len(from) == 0 {
+		from = "sigsum-monitor"
+	}
+	fmt.Fprintf(&msg, "From %s %s\n", from, date.Format(time.ANSIC))
+	fmt.Fprintf(&msg, "From: %s\n", from)
+	if len(s.To) > 0 {
+		fmt.Fprintf(&msg, "To: %s\n", s.To)
+	}
+	fmt.Fprintf(&msg, "Date: %s\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Subject: sigsum monitor alert: %s, log %x\n", event.Type, event.LogKeyHash)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\n\n")
+	fmt.Fprintf(&msg, "Log: %x\nAlert: %s\n\n", event.LogKeyHash, event.Type)
+	for _, line := range strings.Split(event.Message, "\n") {
+		// Escape lines that would be mistaken for a message
+		// separator.
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			line = ">" + line
+		}
+		fmt.Fprintf(&msg, "%s\n", line)
+	}
+	if event.Suppressed > 0 {
+		fmt.Fprintf(&msg, "\n(%d identical alerts suppressed)\n", event.Suppressed)
+	}
+	if evidence := event.evidenceASCII(); len(evidence) > 0 {
+		fmt.Fprintf(&msg, "\nEvidence:\n\n%s", evidence)
+	}
+	msg.WriteString("\n")
+
+	f, err := os.OpenFile(s.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// Single write, so that concurrent appends are not
+	// interleaved.
+	if _, err := f.Write(msg.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type AlertSinkConfig struct {
+	Sinks []AlertSink
+	// Identical alerts for the same log are delivered at most
+	// once per interval. Alerts with evidence are always
+	// delivered. Zero implies DefaultRepeatInterval.
+	RepeatInterval time.Duration
+	// Timeout for each delivery. Zero implies a default timeout.
+	Timeout time.Duration
+	// If set, alerts of type AlertWarning are not delivered.
+	SkipWarnings bool
+}
+
+// Returns a Callbacks that passes all calls on to the given
+// callbacks, and additionally delivers alerts to the configured
+// sinks. Alerts are delivered before they are passed on, since the
+// application may exit on alerts. Delivery failures are logged, but
+// not reported as alerts.
+func (config *AlertSinkConfig) Callbacks(callbacks Callbacks) Callbacks {
+	c := alertSinkCallbacks{
+		config:    *config,
+		callbacks: callbacks,
+		now:       time.Now,
+		seen:      make(map[alertKey]*alertHistory),
+	}
+	if c.config.RepeatInterval <= 0 {
+		c.config.RepeatInterval = DefaultRepeatInterval
+	}
+	if c.config.Timeout <= 0 {
+		c.config.Timeout = defaultSinkTimeout
+	}
+	return &c
+}
+
+type alertKey struct {
+	logKeyHash crypto.Hash
+	alertType  AlertType
+	message    string
+}
+
+type alertHistory struct {
+	delivered  time.Time
+	suppressed int
+}
+
+type alertSinkCallbacks struct {
+	config    AlertSinkConfig
+	callbacks Callbacks
+	now       func() time.Time
+
+	m    sync.Mutex
+	seen map[alertKey]*alertHistory
+}
+
+func (c *alertSinkCallbacks) NewTreeHead(logKeyHash crypto.Hash, cosignedTreeHead types.CosignedTreeHead) {
+	c.callbacks.NewTreeHead(logKeyHash, cosignedTreeHead)
+}
+
+func (c *alertSinkCallbacks) NewLeaves(logKeyHash crypto.Hash, numberOfProcessedLeaves uint64, indices []uint64, leaves []types.Leaf) {
+	c.callbacks.NewLeaves(logKeyHash, numberOfProcessedLeaves, indices, leaves)
+}
+
+func (c *alertSinkCallbacks) Alert(logKeyHash crypto.Hash, e error) {
+	c.notify(logKeyHash, e)
+	c.callbacks.Alert(logKeyHash, e)
+}
+
+func (c *alertSinkCallbacks) notify(logKeyHash crypto.Hash, e error) {
+	event := AlertEvent{
+		LogKeyHash: logKeyHash,
+		Type:       ErrorAlertType(e),
+		Message:    e.Error(),
+		Time:       c.now(),
+		Evidence:   ErrorEvidence(e),
+	}
+	if c.config.SkipWarnings && event.Type == AlertWarning {
+		return
+	}
+	if !c.dedup(&event) {
+		return
+	}
+	for _, sink := range c.config.Sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+		if err := sink.Notify(ctx, &event); err != nil {
+			log.Error("Delivering alert for log %x failed: %v", logKeyHash, err)
+		}
+		cancel()
+	}
+}
+
+// Returns true if the event should be delivered, and if so, sets
+// the number of previously suppressed events.
+func (c *alertSinkCallbacks) dedup(event *AlertEvent) bool {
+	if event.Type == AlertUnexpectedChecksum || event.Evidence != nil {
+		// Each such alert is about a different leaf, or
+		// carries evidence that must not be lost.
+		return true
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	key := alertKey{logKeyHash: event.LogKeyHash, alertType: event.Type, message: event.Message}
+	h, ok := c.seen[key]
+	if !ok {
+		// Forget alerts that are no longer repeated, to not
+		// accumulate alerts with varying messages.
+		for k, h := range c.seen {
+			if h.suppressed == 0 && event.Time.Sub(h.delivered) >= c.config.RepeatInterval {
+				delete(c.seen, k)
+			}
+		}
+		c.seen[key] = &alertHistory{delivered: event.Time}
+		return true
+	}
+	if event.Time.Sub(h.delivered) < c.config.RepeatInterval {
+		h.suppressed++
+		return false
+	}
+	event.Suppressed = h.suppressed
+	h.delivered = event.Time
+	h.suppressed = 0
+	return true
+}
@@ -0,0 +1,183 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/evidence"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+type testSink struct {
+	events []AlertEvent
+}
+
+func (s *testSink) Notify(_ context.Context, event *AlertEvent) error {
+	s.events = append(s.events, *event)
+	return nil
+}
+
+type nopCallbacks struct{}
+
+func (_ nopCallbacks) NewTreeHead(_ crypto.Hash, _ types.CosignedTreeHead)           {}
+func (_ nopCallbacks) NewLeaves(_ crypto.Hash, _ uint64, _ []uint64, _ []types.Leaf) {}
+func (_ nopCallbacks) Alert(_ crypto.Hash, _ error)                                  {}
+
+func TestAlertSinkDedup(t *testing.T) {
+	sink := testSink{}
+	config := AlertSinkConfig{Sinks: []AlertSink{&sink}, RepeatInterval: time.Hour}
+	c := config.Callbacks(nopCallbacks{}).(*alertSinkCallbacks)
+	now := time.Unix(10000, 0)
+	c.now = func() time.Time { return now }
+
+	log1, log2 := crypto.Hash{1}, crypto.Hash{2}
+	logError := newAlert(AlertLogError, "get-tree-head failed")
+	for i := 0; i < 5; i++ {
+		c.Alert(log1, logError)
+		now = now.Add(10 * time.Minute)
+	}
+	// Different log, different type, different message, and
+	// alerts with evidence, are not suppressed.
+	c.Alert(log2, logError)
+	c.Alert(log1, newAlert(AlertInconsistentTreeHead, "consistency proof not valid"))
+	c.Alert(log1, newAlert(AlertLogError, "invalid signature on leaf 7"))
+	for i := 0; i < 2; i++ {
+		alert := newAlert(AlertLogError, "invalid signature on leaf 8")
+		alert.Evidence = &evidence.InvalidLeafSignature{}
+		c.Alert(log1, alert)
+	}
+
+	now = now.Add(time.Hour)
+	c.Alert(log1, logError)
+
+	var summary []string
+	for _, e := range sink.events {
+		text, _ := e.Type.MarshalText()
+		summary = append(summary, string(text))
+	}
+	if got, want := strings.Join(summary, ","),
+		"log-error,log-error,inconsistent-tree-head,log-error,log-error,log-error,log-error"; got != want {
+		t.Fatalf("unexpected events, got %q, want %q", got, want)
+	}
+	if got, want := sink.events[6].Suppressed, 4; got != want {
+		t.Errorf("unexpected suppressed count, got %d, want %d", got, want)
+	}
+}
+
+// Simulates an application that exits on alerts.
+type exitCallbacks struct {
+	nopCallbacks
+}
+
+func (_ exitCallbacks) Alert(_ crypto.Hash, _ error) {
+	panic("exit")
+}
+
+func TestAlertSinkBeforeExit(t *testing.T) {
+	sink := testSink{}
+	config := AlertSinkConfig{Sinks: []AlertSink{&sink}}
+	c := config.Callbacks(exitCallbacks{})
+	func() {
+		defer func() {
+			if r := recover(); r != "exit" {
+				t.Fatalf("unexpected recover value: %v", r)
+			}
+		}()
+		c.Alert(crypto.Hash{1}, newAlert(AlertInconsistentTreeHead, "consistency proof not valid"))
+	}()
+	if len(sink.events) != 1 {
+		t.Errorf("alert not delivered before exit, got %d events", len(sink.events))
+	}
+}
+
+func testEvent() *AlertEvent {
+	return &AlertEvent{
+		LogKeyHash: crypto.Hash{1},
+		Type:       AlertLogError,
+		Message:    "get-tree-head failed\nFrom here",
+		Time:       time.Unix(10000, 0),
+	}
+}
+
+func checkEventJSON(t *testing.T, data []byte) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		t.Fatalf("invalid json %q: %v", data, err)
+	}
+	if got, want := obj["alert_type"], "log-error"; got != want {
+		t.Errorf("unexpected alert type, got %v, want %v", got, want)
+	}
+	if got, want := obj["log_key_hash"], "01"+strings.Repeat("0", 62); got != want {
+		t.Errorf("unexpected log key hash, got %v, want %v", got, want)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		if r.URL.Path != "/ok" {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if err := (&WebhookSink{URL: server.URL + "/ok"}).Notify(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	checkEventJSON(t, body)
+	if err := (&WebhookSink{URL: server.URL + "/bad"}).Notify(context.Background(), testEvent()); err == nil {
+		t.Errorf("expected failure for error response")
+	}
+}
+
+func TestCommandSink(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out")
+	sink := CommandSink{Command: []string{"sh", "-c", "cat > " + file}}
+	if err := sink.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEventJSON(t, data)
+	if err := (&CommandSink{Command: []string{"false"}}).Notify(context.Background(), testEvent()); err == nil {
+		t.Errorf("expected failure for failing command")
+	}
+}
+
+func TestMailSpoolSink(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spool")
+	sink := MailSpoolSink{File: file, To: "root"}
+	for i := 0; i < 2; i++ {
+		if err := sink.Notify(context.Background(), testEvent()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "From ") {
+			count++
+		}
+	}
+	if count != 2 {
+		t.Errorf("unexpected number of messages in spool file, got %d, want 2:\n%s", count, data)
+	}
+	if !strings.Contains(string(data), "\n>From here\n") {
+		t.Errorf("From line in message not escaped:\n%s", data)
+	}
+}
@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dchest/safefile"
+
+	"sigsum.org/sigsum-go/pkg/ascii"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+// Persisted state for a single log, see doc/monitor.md for the file
+// format.
+type persistedState struct {
+	sth           types.SignedTreeHead
+	nextLeafIndex uint64
+}
+
+func (s *persistedState) ToASCII(w io.Writer) error {
+	if err := s.sth.ToASCII(w); err != nil {
+		return err
+	}
+	// Empty line as separator.
+	if _, err := fmt.Fprint(w, "\n"); err != nil {
+		return err
+	}
+	return ascii.WriteInt(w, "next_leaf_index", s.nextLeafIndex)
+}
+
+func (s *persistedState) FromASCII(r io.Reader) error {
+	p := ascii.NewParser(r)
+	if err := s.sth.Parse(&p); err != nil {
+		return err
+	}
+	if err := p.GetEmptyLine(); err != nil {
+		return err
+	}
+	var err error
+	s.nextLeafIndex, err = p.GetInt("next_leaf_index")
+	if err != nil {
+		return err
+	}
+	if s.nextLeafIndex > s.sth.Size {
+		return fmt.Errorf("invalid state, next_leaf_index (%d) larger than tree size (%d)",
+			s.nextLeafIndex, s.sth.Size)
+	}
+	return p.GetEOF()
+}
+
+// A StateDirectory persists the monitor state, with one file per log
+// in the given directory. Each file is updated atomically.
+type StateDirectory struct {
+	directory string
+
+	// Synchronizes all updates to both the map and the
+	// underlying files.
+	m      sync.Mutex
+	states map[crypto.Hash]persistedState
+}
+
+func NewStateDirectory(directory string) *StateDirectory {
+	return &StateDirectory{
+		directory: directory,
+		states:    make(map[crypto.Hash]persistedState),
+	}
+}
+
+func (d *StateDirectory) fileName(logKeyHash *crypto.Hash) string {
+	return filepath.Join(d.directory, fmt.Sprintf("%x", logKeyHash[:]))
+}
+
+// Reads the state for each log in the policy, for use with
+// StartMonitoring. Logs without any state file are omitted. Fails if
+// any state file is invalid, or has a tree head not signed by the
+// log.
+func (d *StateDirectory) Load(p *policy.Policy) (map[crypto.Hash]MonitorState, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	result := make(map[crypto.Hash]MonitorState)
+	for _, l := range p.GetLogs() {
+		keyHash := crypto.HashBytes(l.PublicKey[:])
+		fileName := d.fileName(&keyHash)
+		f, err := os.Open(fileName)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		var state persistedState
+		err = state.FromASCII(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid state file %q: %v", fileName, err)
+		}
+		if !state.sth.Verify(&l.PublicKey) {
+			return nil, fmt.Errorf("invalid log signature on tree head in state file %q", fileName)
+		}
+		d.states[keyHash] = state
+		result[keyHash] = MonitorState{TreeHead: state.sth.TreeHead, Signature: state.sth.Signature, NextLeafIndex: state.nextLeafIndex}
+	}
+	return result, nil
+}
+
+// Must be called with lock held.
+func (d *StateDirectory) store(logKeyHash *crypto.Hash, state *persistedState) error {
+	f, err := safefile.Create(d.fileName(logKeyHash), 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := state.ToASCII(f); err != nil {
+		return err
+	}
+	// Atomically replace old file with new.
+	if err := f.Commit(); err != nil {
+		return err
+	}
+	d.states[*logKeyHash] = *state
+	return nil
+}
+
+// Records a new tree head for the log, keeping the index of the next
+// leaf to process.
+func (d *StateDirectory) UpdateTreeHead(logKeyHash crypto.Hash, sth *types.SignedTreeHead) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	state := d.states[logKeyHash]
+	if sth.Size < state.sth.Size {
+		return fmt.Errorf("tree head size %d smaller than stored size %d", sth.Size, state.sth.Size)
+	}
+	state.sth = *sth
+	return d.store(&logKeyHash, &state)
+}
+
+// Records the index of the next leaf to process. Must not exceed the
+// size of the stored tree head.
+func (d *StateDirectory) UpdateNextLeafIndex(logKeyHash crypto.Hash, nextLeafIndex uint64) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	state, ok := d.states[logKeyHash]
+	if !ok || nextLeafIndex > state.sth.Size {
+		return fmt.Errorf("next leaf index %d is outside of stored tree", nextLeafIndex)
+	}
+	state.nextLeafIndex = nextLeafIndex
+	return d.store(&logKeyHash, &state)
+}
+
+// Returns a Callbacks that passes all calls on to the given
+// callbacks, and then persists new tree heads and the monitoring
+// progress. Persisting after the application's callback means that
+// after a restart, leaves are reported at least once. Failures to
+// persist state are reported as alerts.
+func (d *StateDirectory) Callbacks(callbacks Callbacks) Callbacks {
+	return &persistingCallbacks{d: d, callbacks: callbacks}
+}
+
+type persistingCallbacks struct {
+	d         *StateDirectory
+	callbacks Callbacks
+}
+
+func (c *persistingCallbacks) NewTreeHead(logKeyHash crypto.Hash, cosignedTreeHead types.CosignedTreeHead) {
+	c.callbacks.NewTreeHead(logKeyHash, cosignedTreeHead)
+	if err := c.d.UpdateTreeHead(logKeyHash, &cosignedTreeHead.SignedTreeHead); err != nil {
+		c.callbacks.Alert(logKeyHash, newAlert(AlertOther, "storing tree head failed: %v", err))
+	}
+}
+
+func (c *persistingCallbacks) NewLeaves(logKeyHash crypto.Hash, numberOfProcessedLeaves uint64, indices []uint64, leaves []types.Leaf) {
+	c.callbacks.NewLeaves(logKeyHash, numberOfProcessedLeaves, indices, leaves)
+	if err := c.d.UpdateNextLeafIndex(logKeyHash, numberOfProcessedLeaves); err != nil {
+		c.callbacks.Alert(logKeyHash, newAlert(AlertOther, "storing next leaf index failed: %v", err))
+	}
+}
+
+func (c *persistingCallbacks) Alert(logKeyHash crypto.Hash, e error) {
+	c.callbacks.Alert(logKeyHash, e)
+}
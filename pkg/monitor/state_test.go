@@ -0,0 +1,158 @@
+package monitor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/evidence"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestStateDirectory(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	logPub := logSigner.Public()
+	logKeyHash := crypto.HashBytes(logPub[:])
+	p, err := policy.NewKofNPolicy([]crypto.PublicKey{logPub}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	d := NewStateDirectory(dir)
+	if state, err := d.Load(p); err != nil || len(state) > 0 {
+		t.Fatalf("unexpected state for empty directory: %v, %v", state, err)
+	}
+	if err := d.UpdateNextLeafIndex(logKeyHash, 1); err == nil {
+		t.Errorf("next leaf index beyond tree size not rejected")
+	}
+	th := types.TreeHead{Size: 5, RootHash: crypto.Hash{1}}
+	sth, err := th.Sign(logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UpdateTreeHead(logKeyHash, &sth); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UpdateNextLeafIndex(logKeyHash, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := NewStateDirectory(dir).Load(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := state[logKeyHash], (MonitorState{TreeHead: th, Signature: sth.Signature, NextLeafIndex: 3}); got != want {
+		t.Errorf("unexpected state, got %v, want %v", got, want)
+	}
+
+	// Tree heads must not be signed by some other key.
+	otherSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
+	if sth, err = th.Sign(otherSigner); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UpdateTreeHead(logKeyHash, &sth); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStateDirectory(dir).Load(p); err == nil {
+		t.Errorf("invalid signature on stored tree head not detected")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "foo"), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Unrelated files are ignored, but a bad state file is an error.
+	if err := os.WriteFile(d.fileName(&logKeyHash), []byte("size=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStateDirectory(dir).Load(p); err == nil {
+		t.Errorf("invalid state file not detected")
+	}
+}
+
+type testCallbacks struct {
+	cancel    func()
+	size      uint64
+	alerts    []error
+	numLeaves int
+}
+
+func (c *testCallbacks) NewTreeHead(_ crypto.Hash, _ types.CosignedTreeHead) {}
+
+func (c *testCallbacks) NewLeaves(_ crypto.Hash, numberOfProcessedLeaves uint64, _ []uint64, leaves []types.Leaf) {
+	c.numLeaves += len(leaves)
+	if numberOfProcessedLeaves == c.size {
+		c.cancel()
+	}
+}
+
+func (c *testCallbacks) Alert(_ crypto.Hash, e error) {
+	c.alerts = append(c.alerts, e)
+	c.cancel()
+}
+
+func TestMonitorResume(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	logPub := logSigner.Public()
+	logKeyHash := crypto.HashBytes(logPub[:])
+	leafSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
+	p, err := policy.NewKofNPolicy([]crypto.PublicKey{logPub}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	// The id selects the leaf contents.
+	run := func(id, size uint64) *testCallbacks {
+		log := newTestLog(t, logSigner)
+		addLeaves(t, log, leafSigner, id, size)
+
+		d := NewStateDirectory(dir)
+		state, err := d.Load(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		initial, ok := state[logKeyHash]
+		if !ok {
+			initial = MonitorState{TreeHead: types.NewEmptyTreeHead()}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		callbacks := testCallbacks{cancel: cancel, size: size}
+		MonitorLog(ctx, &monitoringLogClient{logKey: logPub, client: log}, initial,
+			&Config{Callbacks: d.Callbacks(&callbacks)})
+		return &callbacks
+	}
+	if c := run(0, 10); len(c.alerts) > 0 || c.numLeaves != 10 {
+		t.Fatalf("unexpected first run, leaves %d, alerts: %v", c.numLeaves, c.alerts)
+	}
+	// Should report only the new leaves.
+	if c := run(0, 15); len(c.alerts) > 0 || c.numLeaves != 5 {
+		t.Fatalf("unexpected second run, leaves %d, alerts: %v", c.numLeaves, c.alerts)
+	}
+	// Evidence must include the tree head signed before the restart.
+	checkEvidence := func(desc string, c *testCallbacks) {
+		if len(c.alerts) != 1 || ErrorAlertType(c.alerts[0]) != AlertInconsistentTreeHead {
+			t.Fatalf("unexpected alerts for %s: %v", desc, c.alerts)
+		}
+		e, ok := ErrorEvidence(c.alerts[0]).(*evidence.InconsistentTreeHeads)
+		if !ok {
+			t.Fatalf("missing evidence for %s: %v", desc, c.alerts[0])
+		}
+		if _, err := e.Verify(); err != nil {
+			t.Errorf("invalid evidence for %s: %v", desc, err)
+		}
+	}
+	// Log has been rolled back. It can't provide any consistency
+	// proof, so there's no evidence.
+	if c := run(0, 12); len(c.alerts) != 1 || ErrorAlertType(c.alerts[0]) != AlertInconsistentTreeHead {
+		t.Fatalf("unexpected alerts for rolled back log: %v", c.alerts)
+	} else if ErrorEvidence(c.alerts[0]) != nil {
+		t.Errorf("unexpected evidence for rolled back log: %v", c.alerts[0])
+	}
+	// Log with different leaves.
+	checkEvidence("inconsistent log", run(1, 20))
+}
@@ -0,0 +1,299 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+)
+
+// Limit on the number of witness sets computed by Analyze, for each
+// node of the quorum tree, to bound the work for large policies. The
+// number is computed before enumerating any sets, see countSets.
+const maxMinimalSets = 1000
+
+// Analysis describes the properties of a policy's quorum.
+type Analysis struct {
+	// Key hashes of all witnesses that are part of the quorum,
+	// sorted.
+	Witnesses []crypto.Hash
+	// The minimal sets of witnesses whose cosignatures satisfy the
+	// quorum, each sorted by key hash. Nil if there are too many
+	// sets to list, in which case TooManySets is true.
+	MinimalSets [][]crypto.Hash
+	TooManySets bool
+	// Smallest number of cosignatures that satisfy the quorum.
+	MinCosignatures int
+	// Largest number of witnesses that can be unavailable, or
+	// refuse to cosign, with the quorum still being satisfiable
+	// by the remaining witnesses.
+	FaultTolerance int
+}
+
+// This processor collects the witnesses in the quorum tree.
+type witnessesProcessor map[crypto.Hash]struct{}
+
+func (wp witnessesProcessor) ProcessWitness(kh crypto.Hash) any {
+	wp[kh] = struct{}{}
+	return nil
+}
+
+func (_ witnessesProcessor) ProcessGroup(_ int, _ []any) any {
+	return nil
+}
+
+// This processor computes the minimal number of witnesses that must
+// fail for the quorum to be unsatisfiable, using int values
+// everywhere the Processor interface uses any, with -1 meaning that
+// the quorum can't be made unsatisfiable.
+type breakProcessor struct{}
+
+func (_ breakProcessor) ProcessWitness(_ crypto.Hash) any {
+	return 1
+}
+
+func (_ breakProcessor) ProcessGroup(k int, members []any) any {
+	// Need to break n-k+1 members.
+	n := len(members) - k + 1
+	if n > len(members) {
+		return -1
+	}
+	costs := make([]int, 0, len(members))
+	for _, m := range members {
+		if c := m.(int); c >= 0 {
+			costs = append(costs, c)
+		}
+	}
+	if n > len(costs) {
+		return -1
+	}
+	slices.Sort(costs)
+	sum := 0
+	for _, c := range costs[:n] {
+		sum += c
+	}
+	return sum
+}
+
+// This processor computes the witnesses that alone satisfy each
+// node, using witnessSet values everywhere the Processor interface
+// uses any. Unlike minimalSetsProcessor, it is cheap for any policy.
+type singleWitnessProcessor struct{}
+
+type witnessSet map[crypto.Hash]struct{}
+
+func (_ singleWitnessProcessor) ProcessWitness(kh crypto.Hash) any {
+	return witnessSet{kh: {}}
+}
+
+func (_ singleWitnessProcessor) ProcessGroup(k int, members []any) any {
+	set := make(witnessSet)
+	// Members are disjoint, so a single witness can satisfy
+	// the group only if one member is enough.
+	if k == 1 {
+		for _, m := range members {
+			for kh := range m.(witnessSet) {
+				set[kh] = struct{}{}
+			}
+		}
+	}
+	return set
+}
+
+// Sets of witnesses, nil if there are too many.
+type witnessSets [][]crypto.Hash
+
+// This processor computes the minimal sets of witnesses satisfying
+// each node, using witnessSets values everywhere the Processor
+// interface uses any.
+type minimalSetsProcessor struct{}
+
+func (_ minimalSetsProcessor) ProcessWitness(kh crypto.Hash) any {
+	return witnessSets{{kh}}
+}
+
+func (_ minimalSetsProcessor) ProcessGroup(k int, members []any) any {
+	families := make([]witnessSets, len(members))
+	for i, m := range members {
+		families[i] = m.(witnessSets)
+		if families[i] == nil {
+			return witnessSets(nil)
+		}
+	}
+	if countSets(families, k) > maxMinimalSets {
+		return witnessSets(nil)
+	}
+	var sets witnessSets
+	forEachCombination(len(members), k, func(indices []int) bool {
+		combined := witnessSets{{}}
+		for _, i := range indices {
+			var product witnessSets
+			for _, a := range combined {
+				for _, b := range families[i] {
+					product = append(product, unionSorted(a, b))
+				}
+			}
+			combined = product
+		}
+		sets = append(sets, combined...)
+		return true
+	})
+	return minimizeSets(sets)
+}
+
+// Returns the number of sets that ProcessGroup enumerates before
+// minimization, i.e., the sum over all k-subsets of the families of
+// the product of their sizes, saturating at maxMinimalSets + 1.
+func countSets(families []witnessSets, k int) int {
+	// count[j] is the number for j-subsets of the families
+	// processed so far.
+	count := make([]int, k+1)
+	count[0] = 1
+	for _, f := range families {
+		for j := k; j > 0; j-- {
+			count[j] = min(count[j]+count[j-1]*len(f), maxMinimalSets+1)
+		}
+	}
+	return count[k]
+}
+
+// Calls f for each k-element subset of {0, ..., n-1}, in
+// lexicographic order, until f returns false.
+func forEachCombination(n, k int, f func(indices []int) bool) {
+	indices := make([]int, 0, k)
+	var recurse func(start int) bool
+	recurse = func(start int) bool {
+		if len(indices) == k {
+			return f(indices)
+		}
+		for i := start; i <= n-(k-len(indices)); i++ {
+			indices = append(indices, i)
+			if !recurse(i + 1) {
+				return false
+			}
+			indices = indices[:len(indices)-1]
+		}
+		return true
+	}
+	recurse(0)
+}
+
+func compareHashes(a, b crypto.Hash) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+// Returns the union of two sorted sets, as a new sorted set.
+func unionSorted(a, b []crypto.Hash) []crypto.Hash {
+	union := make([]crypto.Hash, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch c := compareHashes(a[0], b[0]); {
+		case c < 0:
+			union, a = append(union, a[0]), a[1:]
+		case c > 0:
+			union, b = append(union, b[0]), b[1:]
+		default:
+			union, a, b = append(union, a[0]), a[1:], b[1:]
+		}
+	}
+	return append(append(union, a...), b...)
+}
+
+// Reports whether sorted set a is a subset of sorted set b.
+func isSubsetSorted(a, b []crypto.Hash) bool {
+	for _, h := range a {
+		if _, found := slices.BinarySearchFunc(b, h, compareHashes); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes duplicates and sets that are supersets of other sets.
+func minimizeSets(sets witnessSets) witnessSets {
+	slices.SortStableFunc(sets, func(a, b []crypto.Hash) int { return len(a) - len(b) })
+	var minimal witnessSets
+	for _, s := range sets {
+		if !slices.ContainsFunc(minimal, func(m []crypto.Hash) bool { return isSubsetSorted(m, s) }) {
+			minimal = append(minimal, s)
+		}
+	}
+	return minimal
+}
+
+// Returns the smallest number of cosignatures that satisfy the quorum.
+func (p *Policy) minCosignatures() int {
+	return p.ProcessQuorum(reportProcessor{
+		witnesses: p.witnesses,
+		status:    make(map[crypto.Hash]CosignatureStatus),
+	}).(*QuorumReport).Missing
+}
+
+// Analyze computes properties of the policy's quorum.
+func (p *Policy) Analyze() *Analysis {
+	var a Analysis
+
+	witnesses := make(witnessesProcessor)
+	p.ProcessQuorum(witnesses)
+	for kh := range witnesses {
+		a.Witnesses = append(a.Witnesses, kh)
+	}
+	slices.SortFunc(a.Witnesses, compareHashes)
+
+	a.MinCosignatures = p.minCosignatures()
+
+	if n := p.ProcessQuorum(breakProcessor{}).(int); n < 0 {
+		a.FaultTolerance = len(a.Witnesses)
+	} else {
+		a.FaultTolerance = n - 1
+	}
+
+	a.MinimalSets = p.ProcessQuorum(minimalSetsProcessor{}).(witnessSets)
+	a.TooManySets = a.MinimalSets == nil
+	return &a
+}
+
+// Check looks for likely mistakes in the policy, and returns a
+// description of each problem found. Since the policy doesn't say
+// which witnesses are run by the same operator, a quorum that can
+// be satisfied by a single operator is detected only if it can be
+// satisfied by a single witness.
+func (p *Policy) Check() []string {
+	var problems []string
+	witnesses := make(witnessesProcessor)
+	p.ProcessQuorum(witnesses)
+	var unused []string
+	for kh := range p.witnesses {
+		if _, ok := witnesses[kh]; !ok {
+			unused = append(unused, p.witnessNames[kh])
+		}
+	}
+	slices.Sort(unused)
+	for _, name := range unused {
+		problems = append(problems, fmt.Sprintf("witness %q is not part of the quorum", name))
+	}
+	var logs []string
+	for kh, log := range p.logs {
+		if name, ok := p.witnessNames[kh]; ok {
+			problems = append(problems, fmt.Sprintf("log key %x is also used for witness %q", log.PublicKey, name))
+		}
+		if len(log.URL) == 0 {
+			logs = append(logs, fmt.Sprintf("%x", log.PublicKey))
+		}
+	}
+	slices.Sort(logs)
+	for _, key := range logs {
+		problems = append(problems, fmt.Sprintf("log %s has no url, it can't be used for submission", key))
+	}
+	if p.minCosignatures() == 1 {
+		var single []string
+		for kh := range p.ProcessQuorum(singleWitnessProcessor{}).(witnessSet) {
+			single = append(single, p.witnessNames[kh])
+		}
+		slices.Sort(single)
+		for _, name := range single {
+			problems = append(problems, fmt.Sprintf("quorum is satisfied by witness %q alone", name))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+)
+
+func TestAnalyze(t *testing.T) {
+	var keys []string
+	var hashes []crypto.Hash
+	for i := 0; i < 6; i++ {
+		key := crypto.PublicKey{byte(i + 1)}
+		keys = append(keys, fmt.Sprintf("%x", key))
+		hashes = append(hashes, crypto.HashBytes(key[:]))
+	}
+	sorted := func(hs ...crypto.Hash) []crypto.Hash {
+		hs = slices.Clone(hs)
+		slices.SortFunc(hs, compareHashes)
+		return hs
+	}
+	witnesses := fmt.Sprintf("witness w0 %s\nwitness w1 %s\nwitness w2 %s\nwitness w3 %s\n", keys[0], keys[1], keys[2], keys[3])
+	for _, table := range []struct {
+		desc            string
+		config          string
+		witnesses       int
+		minCosignatures int
+		faultTolerance  int
+		// Indices of witnesses in minimal sets.
+		sets [][]int
+	}{
+		{"none", "quorum none\n", 0, 0, 0, [][]int{{}}},
+		{"single", witnesses + "quorum w0\n", 1, 1, 0, [][]int{{0}}},
+		{"2 of 3", witnesses + "group g 2 w0 w1 w2\nquorum g\n", 3, 2, 1,
+			[][]int{{0, 1}, {0, 2}, {1, 2}}},
+		{"all", witnesses + "group g all w0 w1 w2\nquorum g\n", 3, 3, 0,
+			[][]int{{0, 1, 2}}},
+		{"nested", witnesses + "group a any w0 w1\ngroup b all w2 w3\ngroup g any a b\nquorum g\n", 4, 1, 2,
+			[][]int{{0}, {1}, {2, 3}}},
+	} {
+		p, err := ParseConfig(strings.NewReader(table.config))
+		if err != nil {
+			t.Fatalf("%s: parsing failed: %v", table.desc, err)
+		}
+		a := p.Analyze()
+		if len(a.Witnesses) != table.witnesses || a.MinCosignatures != table.minCosignatures || a.FaultTolerance != table.faultTolerance {
+			t.Errorf("%s: unexpected analysis: witnesses %d, min cosignatures %d, fault tolerance %d",
+				table.desc, len(a.Witnesses), a.MinCosignatures, a.FaultTolerance)
+		}
+		var sets [][]crypto.Hash
+		for _, indices := range table.sets {
+			set := []crypto.Hash{}
+			for _, i := range indices {
+				set = append(set, hashes[i])
+			}
+			sets = append(sets, sorted(set...))
+		}
+		if a.TooManySets || !slices.EqualFunc(a.MinimalSets, sets, slices.Equal) {
+			t.Errorf("%s: unexpected minimal sets: %x, expected %x", table.desc, a.MinimalSets, sets)
+		}
+	}
+}
+
+func TestAnalyzeLarge(t *testing.T) {
+	var config, names string
+	for i := 0; i < 40; i++ {
+		config += fmt.Sprintf("witness w%d %x\n", i, crypto.PublicKey{10, byte(i)})
+		names += fmt.Sprintf(" w%d", i)
+	}
+	p, err := ParseConfig(strings.NewReader(config + "group g 20" + names + "\nquorum g\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Must give up without enumerating the sets.
+	a := p.Analyze()
+	if !a.TooManySets || a.MinimalSets != nil {
+		t.Errorf("expected too many sets, got %d", len(a.MinimalSets))
+	}
+	if a.MinCosignatures != 20 || a.FaultTolerance != 20 {
+		t.Errorf("unexpected analysis: min cosignatures %d, fault tolerance %d", a.MinCosignatures, a.FaultTolerance)
+	}
+}
+
+func TestCountSets(t *testing.T) {
+	sizes := func(ns ...int) []witnessSets {
+		var families []witnessSets
+		for _, n := range ns {
+			families = append(families, make(witnessSets, n))
+		}
+		return families
+	}
+	for _, table := range []struct {
+		families []witnessSets
+		k        int
+		want     int
+	}{
+		{sizes(1, 1, 1), 2, 3},
+		{sizes(1, 2, 3), 2, 2 + 3 + 6},
+		{sizes(1, 2, 3), 3, 6},
+		{sizes(1, 2, 3), 0, 1},
+		{sizes(maxMinimalSets, 2), 2, maxMinimalSets + 1},
+	} {
+		if got := countSets(table.families, table.k); got != table.want {
+			t.Errorf("countSets(%d families, %d): got %d, want %d", len(table.families), table.k, got, table.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	logKey := crypto.PublicKey{1}
+	w1 := crypto.PublicKey{2}
+	w2 := crypto.PublicKey{3}
+	w3 := crypto.PublicKey{4}
+	var manyWitnesses, manyNames string
+	for i := 0; i <= 20; i++ {
+		manyWitnesses += fmt.Sprintf("witness w%d %x\n", i, crypto.PublicKey{10, byte(i)})
+		if i > 0 {
+			manyNames += fmt.Sprintf(" w%d", i)
+		}
+	}
+	for _, table := range []struct {
+		desc     string
+		config   string
+		problems []string
+	}{
+		{"ok", fmt.Sprintf("log %x http://example.org\nwitness w1 %x\nwitness w2 %x\ngroup g all w1 w2\nquorum g\n", logKey, w1, w2), nil},
+		{"unused", fmt.Sprintf("log %x http://example.org\nwitness w1 %x\nwitness w2 %x\nwitness w3 %x\ngroup g all w1 w2\nquorum g\n", logKey, w1, w2, w3),
+			[]string{`witness "w3" is not part of the quorum`}},
+		{"no url", fmt.Sprintf("log %x\nquorum none\n", logKey),
+			[]string{fmt.Sprintf("log %x has no url, it can't be used for submission", logKey)}},
+		{"log key as witness", fmt.Sprintf("log %x http://example.org\nwitness w1 %x\nwitness w2 %x\ngroup g all w1 w2\nquorum g\n", logKey, logKey, w2),
+			[]string{fmt.Sprintf(`log key %x is also used for witness "w1"`, logKey)}},
+		{"single witness", fmt.Sprintf("log %x http://example.org\nwitness w1 %x\nwitness w2 %x\nwitness w3 %x\ngroup a all w2 w3\ngroup g any w1 a\nquorum g\n", logKey, w1, w2, w3),
+			[]string{`quorum is satisfied by witness "w1" alone`}},
+		// Too many minimal sets to list.
+		{"single witness, large", fmt.Sprintf("log %x http://example.org\n%sgroup big 10 %s\ngroup g any w0 big\nquorum g\n", logKey, manyWitnesses, manyNames),
+			[]string{`quorum is satisfied by witness "w0" alone`}},
+	} {
+		p, err := ParseConfig(strings.NewReader(table.config))
+		if err != nil {
+			t.Fatalf("%s: parsing failed: %v", table.desc, err)
+		}
+		if got := p.Check(); !slices.Equal(got, table.problems) {
+			t.Errorf("%s: unexpected problems: %q, expected %q", table.desc, got, table.problems)
+		}
+	}
+}
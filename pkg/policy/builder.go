@@ -2,6 +2,7 @@ package policy
 
 import (
 	"fmt"
+	"time"
 
 	"sigsum.org/sigsum-go/pkg/crypto"
 )
@@ -15,6 +16,7 @@ func (w *leafWitness) depthFirst(processor Processor) any {
 }
 
 type groupKofN struct {
+	name    string
 	members []tree
 	k       int
 }
@@ -36,15 +38,21 @@ type builder struct {
 	usedNames map[string]string
 	logs      map[crypto.Hash]Entity
 	witnesses map[crypto.Hash]Entity
-	quorum    tree
+	// Witness names, by key hash.
+	witnessNames      map[crypto.Hash]string
+	witnessValidity   map[crypto.Hash]Validity
+	maxCosignatureAge time.Duration
+	quorum            tree
 }
 
 func newBuilder() *builder {
 	return &builder{
-		names:     map[string]tree{ConfigNone: &groupKofN{}},
-		usedNames: map[string]string{ConfigNone: ""},
-		logs:      make(map[crypto.Hash]Entity),
-		witnesses: make(map[crypto.Hash]Entity),
+		names:           map[string]tree{ConfigNone: &groupKofN{name: ConfigNone}},
+		usedNames:       map[string]string{ConfigNone: ""},
+		logs:            make(map[crypto.Hash]Entity),
+		witnesses:       make(map[crypto.Hash]Entity),
+		witnessNames:    make(map[crypto.Hash]string),
+		witnessValidity: make(map[crypto.Hash]Validity),
 	}
 }
 
@@ -53,9 +61,12 @@ func (b *builder) finish() (*Policy, error) {
 		return nil, fmt.Errorf("no quorum defined")
 	}
 	return &Policy{
-		logs:      b.logs,
-		witnesses: b.witnesses,
-		quorum:    b.quorum,
+		logs:              b.logs,
+		witnesses:         b.witnesses,
+		witnessNames:      b.witnessNames,
+		witnessValidity:   b.witnessValidity,
+		maxCosignatureAge: b.maxCosignatureAge,
+		quorum:            b.quorum,
 	}, nil
 }
 
@@ -115,6 +126,7 @@ func (w *addWitness) apply(b *builder) error {
 		return fmt.Errorf("duplicate witness: %x\n", w.entity.PublicKey)
 	}
 	b.witnesses[h] = w.entity
+	b.witnessNames[h] = w.name
 	b.names[w.name] = &leafWitness{h}
 	return nil
 }
@@ -148,7 +160,7 @@ func (g *addGroup) apply(b *builder) error {
 		}
 		members = append(members, q)
 	}
-	b.names[g.name] = &groupKofN{members: members, k: g.threshold}
+	b.names[g.name] = &groupKofN{name: g.name, members: members, k: g.threshold}
 	return nil
 }
 
@@ -180,3 +192,64 @@ func (s *setQuorum) apply(b *builder) error {
 func SetQuorum(name string) Setting {
 	return &setQuorum{name}
 }
+
+type setWitnessValidity struct {
+	name     string
+	validity Validity
+}
+
+func (v *setWitnessValidity) apply(b *builder) error {
+	w, ok := b.names[v.name].(*leafWitness)
+	if !ok {
+		return fmt.Errorf("undefined witness %q", v.name)
+	}
+	if !v.validity.NotBefore.IsZero() && !v.validity.NotAfter.IsZero() &&
+		!v.validity.NotBefore.Before(v.validity.NotAfter) {
+		return fmt.Errorf("witness %q: empty validity period", v.name)
+	}
+	if _, dup := b.witnessValidity[w.kh]; dup {
+		return fmt.Errorf("witness %q: validity can only be set once", v.name)
+	}
+	b.witnessValidity[w.kh] = v.validity
+	return nil
+}
+
+// Restricts the cosignatures accepted from a previously added
+// witness, to those with timestamps within the validity period.
+func SetWitnessValidity(name string, validity Validity) Setting {
+	return &setWitnessValidity{name, validity}
+}
+
+type setMaxCosignatureAge struct {
+	age time.Duration
+}
+
+func (a *setMaxCosignatureAge) apply(b *builder) error {
+	if a.age <= 0 {
+		return fmt.Errorf("invalid max cosignature age %v", a.age)
+	}
+	if b.maxCosignatureAge > 0 {
+		return fmt.Errorf("max cosignature age can only be set once")
+	}
+	b.maxCosignatureAge = a.age
+	return nil
+}
+
+// Sets the maximum age of cosignatures, enforced only when
+// verification is done at a given reference time.
+func SetMaxCosignatureAge(age time.Duration) Setting {
+	return &setMaxCosignatureAge{age}
+}
+
+// Applies several settings in order, e.g., when a single config line
+// corresponds to more than one setting.
+type settingList []Setting
+
+func (l settingList) apply(b *builder) error {
+	for _, s := range l {
+		if err := s.apply(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
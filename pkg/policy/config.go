@@ -5,23 +5,31 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"sigsum.org/sigsum-go/pkg/crypto"
 )
 
 // Config file syntax is
 //   log <pubkey> [<url>]
-//   witness <name> <pubkey> [<url>]
+//   witness <name> <pubkey> [<url>] [not-before=<time>] [not-after=<time>]
 //   group <name> <threshold> <name>...
 //   quorum <name>
-// with # used for comments.
+//   max-cosignature-age <duration>
+// with # used for comments. Times are RFC 3339, or dates YYYY-MM-DD
+// meaning midnight UTC, and durations use the syntax of Go's
+// time.ParseDuration, e.g., "36h".
 
 const (
 	// Predefined name representing an empty group. Using "quorum
 	// none" defines a policy that doesn't require any cosignatures.
 	ConfigNone = "none"
+
+	configNotBefore = "not-before="
+	configNotAfter  = "not-after="
 )
 
 func parseLog(args []string) (Setting, error) {
@@ -39,7 +47,37 @@ func parseLog(args []string) (Setting, error) {
 	return AddLog(&Entity{PublicKey: key, URL: url}), nil
 }
 
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
 func parseWitness(args []string) (Setting, error) {
+	// Options, if any, follow the positional arguments.
+	var validity Validity
+	for len(args) > 0 {
+		arg := args[len(args)-1]
+		var t *time.Time
+		switch {
+		case strings.HasPrefix(arg, configNotBefore):
+			t, arg = &validity.NotBefore, arg[len(configNotBefore):]
+		case strings.HasPrefix(arg, configNotAfter):
+			t, arg = &validity.NotAfter, arg[len(configNotAfter):]
+		}
+		if t == nil {
+			break
+		}
+		if !t.IsZero() {
+			return nil, fmt.Errorf("duplicate witness option %q", args[len(args)-1])
+		}
+		var err error
+		if *t, err = parseTime(arg); err != nil {
+			return nil, fmt.Errorf("invalid time: %v", err)
+		}
+		args = args[:len(args)-1]
+	}
 	if len(args) < 2 || len(args) > 3 {
 		return nil, fmt.Errorf("invalid witness policy line, public key and name required, url optional")
 	}
@@ -52,7 +90,11 @@ func parseWitness(args []string) (Setting, error) {
 	if len(args) > 2 {
 		url = args[2]
 	}
-	return AddWitness(name, &Entity{PublicKey: key, URL: url}), nil
+	witness := AddWitness(name, &Entity{PublicKey: key, URL: url})
+	if validity.IsZero() {
+		return witness, nil
+	}
+	return settingList{witness, SetWitnessValidity(name, validity)}, nil
 }
 
 func parseGroup(args []string) (Setting, error) {
@@ -89,6 +131,17 @@ func parseQuorum(args []string) (Setting, error) {
 	return SetQuorum(args[0]), nil
 }
 
+func parseMaxCosignatureAge(args []string) (Setting, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("incorrect number of arguments: duration required")
+	}
+	age, err := time.ParseDuration(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return SetMaxCosignatureAge(age), nil
+}
+
 func parseLine(fields []string) (Setting, error) {
 	keyword, args := fields[0], fields[1:]
 	switch keyword {
@@ -100,6 +153,8 @@ func parseLine(fields []string) (Setting, error) {
 		return parseGroup(args)
 	case "quorum":
 		return parseQuorum(args)
+	case "max-cosignature-age":
+		return parseMaxCosignatureAge(args)
 	default:
 		return nil, fmt.Errorf("unknown keyword: %q", keyword)
 	}
@@ -163,3 +218,114 @@ func ReadPolicyFile(name string) (*Policy, error) {
 	}
 	return policy, nil
 }
+
+// Checks that a name or url can be written as a single field of a
+// config line.
+func checkField(field string) error {
+	if len(field) == 0 {
+		return fmt.Errorf("empty name")
+	}
+	if strings.IndexFunc(field, isSpace) >= 0 || strings.HasPrefix(field, "#") {
+		return fmt.Errorf("invalid name or url %q", field)
+	}
+	return checkLine(field)
+}
+
+func writeEntity(w io.Writer, keyword, name string, e *Entity, validity *Validity) error {
+	fields := []string{keyword}
+	if len(name) > 0 {
+		if err := checkField(name); err != nil {
+			return err
+		}
+		fields = append(fields, name)
+	}
+	fields = append(fields, fmt.Sprintf("%x", e.PublicKey))
+	if len(e.URL) > 0 {
+		if err := checkField(e.URL); err != nil {
+			return err
+		}
+		fields = append(fields, e.URL)
+	}
+	if !validity.NotBefore.IsZero() {
+		fields = append(fields, configNotBefore+validity.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if !validity.NotAfter.IsZero() {
+		fields = append(fields, configNotAfter+validity.NotAfter.UTC().Format(time.RFC3339))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(fields, " "))
+	return err
+}
+
+// Writes group lines for the tree, members before the groups they
+// belong to, and returns the name of the tree's root.
+func (p *Policy) writeGroups(w io.Writer, t tree) (string, error) {
+	switch t := t.(type) {
+	case *leafWitness:
+		return p.witnessNames[t.kh], nil
+	case *groupKofN:
+		if t.name == ConfigNone {
+			return ConfigNone, nil
+		}
+		if err := checkField(t.name); err != nil {
+			return "", err
+		}
+		fields := []string{"group", t.name, strconv.Itoa(t.k)}
+		for _, m := range t.members {
+			name, err := p.writeGroups(w, m)
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, name)
+		}
+		_, err := fmt.Fprintln(w, strings.Join(fields, " "))
+		return t.name, err
+	default:
+		panic(fmt.Sprintf("unexpected quorum tree type %T", t))
+	}
+}
+
+// WriteConfig writes the policy in config file syntax, in a
+// canonical form: logs sorted by public key, witnesses sorted by
+// name, and each group defined before the group it's a member of.
+// Groups that aren't part of the quorum are not preserved.
+func (p *Policy) WriteConfig(w io.Writer) error {
+	for _, kh := range sortedKeys(p.logs) {
+		log := p.logs[kh]
+		if err := writeEntity(w, "log", "", &log, &Validity{}); err != nil {
+			return err
+		}
+	}
+	keys := sortedKeys(p.witnesses)
+	slices.SortStableFunc(keys, func(a, b crypto.Hash) int {
+		return strings.Compare(p.witnessNames[a], p.witnessNames[b])
+	})
+	if len(keys) > 0 {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	for _, kh := range keys {
+		// Unlike logs, witnesses must be named.
+		if err := checkField(p.witnessNames[kh]); err != nil {
+			return fmt.Errorf("witness %x: %v", kh, err)
+		}
+		witness, validity := p.witnesses[kh], p.witnessValidity[kh]
+		if err := writeEntity(w, "witness", p.witnessNames[kh], &witness, &validity); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+	quorum, err := p.writeGroups(w, p.quorum)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "quorum %s\n", quorum); err != nil {
+		return err
+	}
+	if p.maxCosignatureAge > 0 {
+		_, err = fmt.Fprintf(w, "max-cosignature-age %v\n", p.maxCosignatureAge)
+	}
+	return err
+}
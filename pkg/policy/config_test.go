@@ -2,6 +2,7 @@ package policy
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"testing"
 
@@ -263,6 +264,22 @@ log aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
 witness W bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb
 group G all W none
 quorum G
+`},
+		{"invalid validity time", "invalid time", `
+witness W bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb not-after=2025-13-01
+`},
+		{"duplicate validity option", "duplicate witness option", `
+witness W bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb not-after=2025-01-01 not-after=2026-01-01
+`},
+		{"empty validity", "empty validity period", `
+witness W bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb not-before=2025-01-01 not-after=2025-01-01
+`},
+		{"invalid max age", "invalid max cosignature age", `
+max-cosignature-age -1h
+`},
+		{"repeated max age", "can only be set once", `
+max-cosignature-age 1h
+max-cosignature-age 2h
 `},
 	} {
 		policy, err := ParseConfig(bytes.NewBufferString(table.config))
@@ -279,3 +296,115 @@ quorum G
 		}
 	}
 }
+
+func TestWriteConfig(t *testing.T) {
+	roundTrip := func(desc string, policy *Policy) {
+		var buf bytes.Buffer
+		if err := policy.WriteConfig(&buf); err != nil {
+			t.Fatalf("%s: writing failed: %v", desc, err)
+		}
+		text := buf.String()
+		parsed, err := ParseConfig(strings.NewReader(text))
+		if err != nil {
+			t.Fatalf("%s: parsing written config failed: %v\n%s", desc, err, text)
+		}
+		if d := Diff(policy, parsed); !d.Empty() {
+			t.Errorf("%s: round trip not equivalent:\n%s", desc, d)
+		}
+		buf.Reset()
+		if err := parsed.WriteConfig(&buf); err != nil {
+			t.Fatalf("%s: writing failed: %v", desc, err)
+		}
+		if buf.String() != text {
+			t.Errorf("%s: written config not canonical, got:\n%s\nexpected:\n%s", desc, buf.String(), text)
+		}
+	}
+	for _, name := range List() {
+		policy, err := ByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roundTrip(name, policy)
+	}
+
+	policy, err := NewKofNPolicy([]crypto.PublicKey{crypto.PublicKey{1}},
+		[]crypto.PublicKey{crypto.PublicKey{2}, crypto.PublicKey{3}, crypto.PublicKey{4}}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip("k of n", policy)
+
+	var buf bytes.Buffer
+	if err := policy.WriteConfig(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), `log 0100000000000000000000000000000000000000000000000000000000000000
+
+witness w0 0200000000000000000000000000000000000000000000000000000000000000
+witness w1 0300000000000000000000000000000000000000000000000000000000000000
+witness w2 0400000000000000000000000000000000000000000000000000000000000000
+
+group g 2 w0 w1 w2
+quorum g
+`; got != want {
+		t.Errorf("unexpected config, got:\n%s\nexpected:\n%s", got, want)
+	}
+
+	policy, err = NewPolicy(AddLog(&Entity{PublicKey: crypto.PublicKey{1}}),
+		AddWitness("bad name", &Entity{PublicKey: crypto.PublicKey{2}}), SetQuorum("bad name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.WriteConfig(&buf); err == nil {
+		t.Errorf("writing witness name with space succeeded")
+	}
+
+	policy, err = NewPolicy(AddLog(&Entity{PublicKey: crypto.PublicKey{1}}),
+		AddWitness("", &Entity{PublicKey: crypto.PublicKey{2}}), SetQuorum(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.WriteConfig(&buf); err == nil {
+		t.Errorf("writing empty witness name succeeded")
+	}
+
+	policy, err = ParseConfig(strings.NewReader(`
+log 0100000000000000000000000000000000000000000000000000000000000000
+witness old 0200000000000000000000000000000000000000000000000000000000000000 https://w.example.org not-after=2025-06-01
+witness new 0300000000000000000000000000000000000000000000000000000000000000 not-before=2025-06-01T02:00:00+02:00
+group g any old new
+quorum g
+max-cosignature-age 36h
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip("validity", policy)
+
+	buf.Reset()
+	if err := policy.WriteConfig(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Every write error must be reported, including for the empty
+	// separator lines.
+	for n := 0; n < buf.Len(); n++ {
+		if err := policy.WriteConfig(&failingWriter{offset: n}); err == nil {
+			t.Errorf("write error after %d bytes not reported", n)
+		}
+	}
+}
+
+// Fails only the write that includes the byte at the given offset,
+// so that an ignored error isn't caught by a later write.
+type failingWriter struct {
+	offset int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.offset >= 0 && w.offset < len(p) {
+		w.offset = -1
+		return 0, errors.New("write failed")
+	}
+	w.offset -= len(p)
+	return len(p), nil
+}
@@ -0,0 +1,256 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+)
+
+// EntityChange describes a log or witness whose public key is the
+// same in both policies, but whose url, name or validity period
+// differs. Names and validity periods are empty for logs.
+type EntityChange struct {
+	OldName, NewName         string
+	Old, New                 Entity
+	OldValidity, NewValidity Validity
+}
+
+// NamedEntity is a witness together with its name.
+type NamedEntity struct {
+	Name string
+	Entity
+}
+
+// PolicyDiff describes the differences between an old and a new
+// policy. Logs and witnesses are identified by public key.
+type PolicyDiff struct {
+	LogsAdded   []Entity
+	LogsRemoved []Entity
+	LogsChanged []EntityChange
+
+	WitnessesAdded   []NamedEntity
+	WitnessesRemoved []NamedEntity
+	WitnessesChanged []EntityChange
+
+	// Descriptions of the quorums, e.g., "2 of {a, b, c}", using
+	// witness names. QuorumChanged is based on the witness keys
+	// rather than names, so renaming a witness alone doesn't
+	// change the quorum.
+	OldQuorum, NewQuorum string
+	QuorumChanged        bool
+
+	// Zero means no limit.
+	OldMaxCosignatureAge, NewMaxCosignatureAge time.Duration
+
+	// Reasons why a proof valid under the old policy may be
+	// invalid under the new policy; empty if all proofs remain
+	// valid.
+	Incompatible []string
+}
+
+// Compatible reports whether every proof valid under the old policy
+// is also valid under the new policy.
+func (d *PolicyDiff) Compatible() bool {
+	return len(d.Incompatible) == 0
+}
+
+// Empty reports whether the policies are equivalent, up to
+// formatting and ordering.
+func (d *PolicyDiff) Empty() bool {
+	return len(d.LogsAdded) == 0 && len(d.LogsRemoved) == 0 && len(d.LogsChanged) == 0 &&
+		len(d.WitnessesAdded) == 0 && len(d.WitnessesRemoved) == 0 && len(d.WitnessesChanged) == 0 &&
+		!d.QuorumChanged && d.OldMaxCosignatureAge == d.NewMaxCosignatureAge
+}
+
+// This processor produces a canonical description of the quorum
+// tree, using string values everywhere the Processor interface uses
+// any. Group members are sorted, so that the description doesn't
+// depend on the order of members.
+type describeProcessor func(kh crypto.Hash) string
+
+func (dp describeProcessor) ProcessWitness(kh crypto.Hash) any {
+	return dp(kh)
+}
+
+func (_ describeProcessor) ProcessGroup(k int, members []any) any {
+	if len(members) == 0 {
+		return ConfigNone
+	}
+	names := make([]string, len(members))
+	for i, m := range members {
+		names[i] = m.(string)
+	}
+	slices.Sort(names)
+	return fmt.Sprintf("%d of {%s}", k, strings.Join(names, ", "))
+}
+
+// Returns a description of the policy's quorum, using witness
+// names.
+func (p *Policy) describeQuorum() string {
+	return p.ProcessQuorum(describeProcessor(func(kh crypto.Hash) string {
+		return p.witnessNames[kh]
+	})).(string)
+}
+
+func (p *Policy) satisfiedBy(witnesses []crypto.Hash) bool {
+	processor := newQuorumProcessor()
+	for _, kh := range witnesses {
+		processor.addVerifiedWitness(kh)
+	}
+	return p.ProcessQuorum(processor).(bool)
+}
+
+func sortedKeys(m map[crypto.Hash]Entity) []crypto.Hash {
+	keys := make([]crypto.Hash, 0, len(m))
+	for kh := range m {
+		keys = append(keys, kh)
+	}
+	slices.SortFunc(keys, compareHashes)
+	return keys
+}
+
+// Diff compares two policies semantically.
+func Diff(oldPolicy, newPolicy *Policy) *PolicyDiff {
+	var d PolicyDiff
+	for _, kh := range sortedKeys(oldPolicy.logs) {
+		old := oldPolicy.logs[kh]
+		if updated, ok := newPolicy.logs[kh]; !ok {
+			d.LogsRemoved = append(d.LogsRemoved, old)
+			d.Incompatible = append(d.Incompatible, fmt.Sprintf("log %x removed", old.PublicKey))
+		} else if updated.URL != old.URL {
+			d.LogsChanged = append(d.LogsChanged, EntityChange{Old: old, New: updated})
+		}
+	}
+	for _, kh := range sortedKeys(newPolicy.logs) {
+		if _, ok := oldPolicy.logs[kh]; !ok {
+			d.LogsAdded = append(d.LogsAdded, newPolicy.logs[kh])
+		}
+	}
+	for _, kh := range sortedKeys(oldPolicy.witnesses) {
+		old := NamedEntity{oldPolicy.witnessNames[kh], oldPolicy.witnesses[kh]}
+		oldValidity := oldPolicy.witnessValidity[kh]
+		entity, ok := newPolicy.witnesses[kh]
+		if !ok {
+			d.WitnessesRemoved = append(d.WitnessesRemoved, old)
+			continue
+		}
+		updated := NamedEntity{newPolicy.witnessNames[kh], entity}
+		newValidity := newPolicy.witnessValidity[kh]
+		if updated != old || !newValidity.Equal(&oldValidity) {
+			d.WitnessesChanged = append(d.WitnessesChanged, EntityChange{
+				OldName: old.Name, NewName: updated.Name, Old: old.Entity, New: updated.Entity,
+				OldValidity: oldValidity, NewValidity: newValidity,
+			})
+		}
+		if !newValidity.covers(&oldValidity) {
+			d.Incompatible = append(d.Incompatible, fmt.Sprintf(
+				"validity of witness %s reduced from %v to %v", updated.Name, oldValidity, newValidity))
+		}
+	}
+	for _, kh := range sortedKeys(newPolicy.witnesses) {
+		if _, ok := oldPolicy.witnesses[kh]; !ok {
+			d.WitnessesAdded = append(d.WitnessesAdded, NamedEntity{newPolicy.witnessNames[kh], newPolicy.witnesses[kh]})
+		}
+	}
+
+	d.OldMaxCosignatureAge, d.NewMaxCosignatureAge = oldPolicy.maxCosignatureAge, newPolicy.maxCosignatureAge
+	if d.NewMaxCosignatureAge > 0 && (d.OldMaxCosignatureAge == 0 || d.NewMaxCosignatureAge < d.OldMaxCosignatureAge) {
+		d.Incompatible = append(d.Incompatible, fmt.Sprintf(
+			"max cosignature age reduced from %s to %v", formatMaxAge(d.OldMaxCosignatureAge), d.NewMaxCosignatureAge))
+	}
+
+	d.OldQuorum, d.NewQuorum = oldPolicy.describeQuorum(), newPolicy.describeQuorum()
+	byKey := describeProcessor(func(kh crypto.Hash) string { return fmt.Sprintf("%x", kh) })
+	d.QuorumChanged = oldPolicy.ProcessQuorum(byKey).(string) != newPolicy.ProcessQuorum(byKey).(string)
+
+	// Since quorums are monotone, it's sufficient to check the
+	// minimal witness sets satisfying the old quorum.
+	if d.QuorumChanged {
+		a := oldPolicy.Analyze()
+		if a.TooManySets {
+			d.Incompatible = append(d.Incompatible, "old quorum too complex to compare")
+		}
+		for _, set := range a.MinimalSets {
+			if !newPolicy.satisfiedBy(set) {
+				names := make([]string, len(set))
+				for i, kh := range set {
+					names[i] = oldPolicy.witnessNames[kh]
+				}
+				slices.Sort(names)
+				d.Incompatible = append(d.Incompatible, fmt.Sprintf(
+					"cosignatures from {%s} satisfy the old quorum, but not the new",
+					strings.Join(names, ", ")))
+			}
+		}
+	}
+	return &d
+}
+
+func formatMaxAge(age time.Duration) string {
+	if age == 0 {
+		return "unlimited"
+	}
+	return age.String()
+}
+
+func formatEntity(name string, e *Entity) string {
+	s := fmt.Sprintf("%x", e.PublicKey)
+	if len(name) > 0 {
+		s = name + " " + s
+	}
+	if len(e.URL) > 0 {
+		s += " " + e.URL
+	}
+	return s
+}
+
+// Formats the differences, one per line, followed by a summary of
+// compatibility.
+func (d *PolicyDiff) String() string {
+	var b strings.Builder
+	for _, e := range d.LogsAdded {
+		fmt.Fprintf(&b, "log added: %s\n", formatEntity("", &e))
+	}
+	for _, e := range d.LogsRemoved {
+		fmt.Fprintf(&b, "log removed: %s\n", formatEntity("", &e))
+	}
+	for _, c := range d.LogsChanged {
+		fmt.Fprintf(&b, "log url changed: %x %q -> %q\n", c.Old.PublicKey, c.Old.URL, c.New.URL)
+	}
+	for _, e := range d.WitnessesAdded {
+		fmt.Fprintf(&b, "witness added: %s\n", formatEntity(e.Name, &e.Entity))
+	}
+	for _, e := range d.WitnessesRemoved {
+		fmt.Fprintf(&b, "witness removed: %s\n", formatEntity(e.Name, &e.Entity))
+	}
+	for _, c := range d.WitnessesChanged {
+		if c.OldName != c.NewName {
+			fmt.Fprintf(&b, "witness renamed: %x %s -> %s\n", c.Old.PublicKey, c.OldName, c.NewName)
+		}
+		if c.Old.URL != c.New.URL {
+			fmt.Fprintf(&b, "witness url changed: %s %q -> %q\n", c.NewName, c.Old.URL, c.New.URL)
+		}
+		if !c.OldValidity.Equal(&c.NewValidity) {
+			fmt.Fprintf(&b, "witness validity changed: %s %v -> %v\n", c.NewName, c.OldValidity, c.NewValidity)
+		}
+	}
+	if d.OldMaxCosignatureAge != d.NewMaxCosignatureAge {
+		fmt.Fprintf(&b, "max cosignature age changed: %s -> %s\n",
+			formatMaxAge(d.OldMaxCosignatureAge), formatMaxAge(d.NewMaxCosignatureAge))
+	}
+	if d.QuorumChanged {
+		fmt.Fprintf(&b, "quorum changed:\n  old: %s\n  new: %s\n", d.OldQuorum, d.NewQuorum)
+	}
+	if d.Compatible() {
+		fmt.Fprintf(&b, "All proofs valid under the old policy are valid under the new policy.\n")
+	} else {
+		fmt.Fprintf(&b, "Proofs valid under the old policy may be invalid under the new policy:\n")
+		for _, reason := range d.Incompatible {
+			fmt.Fprintf(&b, "  %s\n", reason)
+		}
+	}
+	return b.String()
+}
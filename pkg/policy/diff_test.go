@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+)
+
+func TestDiff(t *testing.T) {
+	log1 := crypto.PublicKey{1}
+	log2 := crypto.PublicKey{2}
+	w1 := crypto.PublicKey{3}
+	w2 := crypto.PublicKey{4}
+	w3 := crypto.PublicKey{5}
+
+	parse := func(config string) *Policy {
+		p, err := ParseConfig(strings.NewReader(config))
+		if err != nil {
+			t.Fatalf("parsing policy failed: %v", err)
+		}
+		return p
+	}
+	base := parse(fmt.Sprintf(`
+log %x https://log1.example.org
+witness a %x
+witness b %x https://b.example.org
+group g 1 a b
+quorum g
+`, log1, w1, w2))
+
+	for _, table := range []struct {
+		desc       string
+		config     string
+		empty      bool
+		compatible bool
+		lines      []string
+	}{
+		{"reordered", fmt.Sprintf(`
+witness b %x https://b.example.org
+witness a %x
+group g any b a
+quorum g
+log %x https://log1.example.org
+`, w2, w1, log1), true, true, nil},
+		{"renamed", fmt.Sprintf(`
+log %x https://log1.example.org
+witness x %x
+witness b %x https://b.example.org
+group h 1 x b
+quorum h
+`, log1, w1, w2), false, true, []string{fmt.Sprintf("witness renamed: %x a -> x", w1)}},
+		{"log added, url changed", fmt.Sprintf(`
+log %x https://new.example.org
+log %x
+witness a %x
+witness b %x
+group g 1 a b
+quorum g
+`, log1, log2, w1, w2), false, true, []string{
+			fmt.Sprintf("log added: %x", log2),
+			fmt.Sprintf(`log url changed: %x "https://log1.example.org" -> "https://new.example.org"`, log1),
+			`witness url changed: b "https://b.example.org" -> ""`,
+		}},
+		{"log removed", fmt.Sprintf(`
+log %x
+witness a %x
+witness b %x https://b.example.org
+group g 1 a b
+quorum g
+`, log2, w1, w2), false, false, []string{
+			fmt.Sprintf("log removed: %x https://log1.example.org", log1),
+			fmt.Sprintf("  log %x removed", log1),
+		}},
+		{"quorum weakened", fmt.Sprintf(`
+log %x https://log1.example.org
+witness a %x
+witness b %x https://b.example.org
+witness c %x
+group g 1 a b c
+quorum g
+`, log1, w1, w2, w3), false, true, []string{
+			"witness added: c " + fmt.Sprintf("%x", w3),
+			"  old: 1 of {a, b}",
+			"  new: 1 of {a, b, c}",
+		}},
+		{"quorum strengthened", fmt.Sprintf(`
+log %x https://log1.example.org
+witness a %x
+witness b %x https://b.example.org
+group g 2 a b
+quorum g
+`, log1, w1, w2), false, false, []string{
+			"  cosignatures from {a} satisfy the old quorum, but not the new",
+			"  cosignatures from {b} satisfy the old quorum, but not the new",
+		}},
+		{"witness removed", fmt.Sprintf(`
+log %x https://log1.example.org
+witness a %x
+quorum a
+`, log1, w1), false, false, []string{
+			fmt.Sprintf("witness removed: b %x https://b.example.org", w2),
+			"  new: a",
+			"  cosignatures from {b} satisfy the old quorum, but not the new",
+		}},
+		{"validity restricted", fmt.Sprintf(`
+log %x https://log1.example.org
+witness a %x not-after=2025-06-01
+witness b %x https://b.example.org
+group g 1 a b
+quorum g
+`, log1, w1, w2), false, false, []string{
+			"witness validity changed: a [unlimited, unlimited) -> [unlimited, 2025-06-01T00:00:00Z)",
+			"  validity of witness a reduced from [unlimited, unlimited) to [unlimited, 2025-06-01T00:00:00Z)",
+		}},
+		{"max age added", fmt.Sprintf(`
+log %x https://log1.example.org
+witness a %x
+witness b %x https://b.example.org
+group g 1 a b
+quorum g
+max-cosignature-age 24h
+`, log1, w1, w2), false, false, []string{
+			"max cosignature age changed: unlimited -> 24h0m0s",
+			"  max cosignature age reduced from unlimited to 24h0m0s",
+		}},
+	} {
+		d := Diff(base, parse(table.config))
+		if d.Empty() != table.empty {
+			t.Errorf("%s: unexpected Empty() result %v", table.desc, d.Empty())
+		}
+		if d.Compatible() != table.compatible {
+			t.Errorf("%s: unexpected Compatible() result %v: %v", table.desc, d.Compatible(), d.Incompatible)
+		}
+		s := d.String()
+		for _, line := range table.lines {
+			if !strings.Contains(s, line+"\n") {
+				t.Errorf("%s: missing line %q in diff:\n%s", table.desc, line, s)
+			}
+		}
+	}
+}
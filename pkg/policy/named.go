@@ -20,7 +20,7 @@ import (
 // they should not be confused with /etc/sigsum/policy/ files.
 const (
 	builtinPolicyFilenameSuffix   = ".builtin-policy"
-	installedPolicyFilenameSuffix = ".sigsum-policy"
+	InstalledPolicyFilenameSuffix = ".sigsum-policy"
 	defaultPolicyDirectory        = "/etc/sigsum/policy"
 	policyDirectoryEnvVariable    = "SIGSUM_POLICY_DIR"
 )
@@ -45,9 +45,11 @@ func openByName(name string) (io.ReadCloser, error) {
 	// If there is a file for this policy in the policy directory
 	// then that should be used. If no such file is found, then a
 	// builtin policy should be used.
-	f, err1 := openFromPolicyDir(name)
+	f, fileName, err1 := openFromPolicyDir(name)
 	if err1 == nil {
-		return f, nil
+		// Not falling back to a builtin policy if
+		// verification of the installed policy fails.
+		return readInstalled(name, f, fileName)
 	}
 	f, err2 := openBuiltinByName(name)
 	if err2 == nil {
@@ -76,16 +78,18 @@ func ReadByName(name string) ([]byte, error) {
 	return io.ReadAll(f)
 }
 
-func openFromPolicyDir(name string) (io.ReadCloser, error) {
+// Returns the opened file and its name.
+func openFromPolicyDir(name string) (io.ReadCloser, string, error) {
 	if err := checkName(name); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	directory := os.Getenv(policyDirectoryEnvVariable)
 	if len(directory) == 0 {
 		directory = defaultPolicyDirectory
 	}
-	filePath := directory + "/" + name + installedPolicyFilenameSuffix
-	return os.Open(filePath)
+	filePath := directory + "/" + name + InstalledPolicyFilenameSuffix
+	f, err := os.Open(filePath)
+	return f, filePath, err
 }
 
 func listFromPolicyDir() []string {
@@ -99,7 +103,7 @@ func listFromPolicyDir() []string {
 	}
 	names := make([]string, 0, len(entries))
 	for _, e := range entries {
-		name, found := strings.CutSuffix(e.Name(), installedPolicyFilenameSuffix)
+		name, found := strings.CutSuffix(e.Name(), InstalledPolicyFilenameSuffix)
 		if !found || checkName(name) != nil {
 			continue
 		}
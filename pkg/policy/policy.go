@@ -3,6 +3,7 @@ package policy
 import (
 	"fmt"
 	"math/rand"
+	"time"
 
 	"sigsum.org/sigsum-go/pkg/crypto"
 	"sigsum.org/sigsum-go/pkg/types"
@@ -25,9 +26,13 @@ type tree interface {
 }
 
 type Policy struct {
-	logs      map[crypto.Hash]Entity
-	witnesses map[crypto.Hash]Entity
-	quorum    tree
+	logs         map[crypto.Hash]Entity
+	witnesses    map[crypto.Hash]Entity
+	witnessNames map[crypto.Hash]string
+	// Witnesses without any entry have unrestricted validity.
+	witnessValidity   map[crypto.Hash]Validity
+	maxCosignatureAge time.Duration
+	quorum            tree
 }
 
 // Performs a depth-first traversal of the quorum tree.
@@ -72,8 +77,27 @@ func (_ quorumProcessor) ProcessGroup(k int, members []any) any {
 	return c >= k
 }
 
+// Verifies the log's signature on the tree head, and that the
+// cosignatures satisfy the quorum. Cosignature timestamps are checked
+// only against witness key validity periods, not against the current
+// time, so that the result doesn't change as time passes.
 func (p *Policy) VerifyCosignedTreeHead(logKeyHash *crypto.Hash,
 	cth *types.CosignedTreeHead) error {
+	return p.verifyCosignedTreeHead(logKeyHash, cth, nil)
+}
+
+// Like VerifyCosignedTreeHead, but also rejects cosignatures with
+// timestamps in the future, or, if the policy specifies a max
+// cosignature age, older than that, relative to the reference time
+// now. Intended for online verifiers that want to reject stale tree
+// heads.
+func (p *Policy) VerifyCosignedTreeHeadAt(logKeyHash *crypto.Hash,
+	cth *types.CosignedTreeHead, now time.Time) error {
+	return p.verifyCosignedTreeHead(logKeyHash, cth, &now)
+}
+
+func (p *Policy) verifyCosignedTreeHead(logKeyHash *crypto.Hash,
+	cth *types.CosignedTreeHead, now *time.Time) error {
 	log, ok := p.logs[*logKeyHash]
 	if !ok {
 		return fmt.Errorf("unknown log")
@@ -83,17 +107,24 @@ func (p *Policy) VerifyCosignedTreeHead(logKeyHash *crypto.Hash,
 	}
 	origin := types.SigsumCheckpointOrigin(&log.PublicKey)
 	processor := newQuorumProcessor()
-	failed := 0
+	failed, rejected := 0, 0
 	for keyHash, cs := range cth.Cosignatures {
 		if witness, ok := p.witnesses[keyHash]; ok {
-			if cs.Verify(&witness.PublicKey, origin, &cth.TreeHead) {
+			switch p.checkCosignature(keyHash, &witness, origin, &cth.TreeHead, &cs, now) {
+			case CosignatureVerified:
 				processor.addVerifiedWitness(keyHash)
-			} else {
+			case CosignatureFailed:
 				failed++
+			default:
+				rejected++
 			}
 		}
 	}
 	if !p.ProcessQuorum(processor).(bool) {
+		if rejected > 0 {
+			return fmt.Errorf("not enough cosignatures, total: %d, verified: %d, failed to verify: %d, rejected by timestamp: %d",
+				len(cth.Cosignatures), processor.count(), failed, rejected)
+		}
 		return fmt.Errorf("not enough cosignatures, total: %d, verified: %d, failed to verify: %d",
 			len(cth.Cosignatures), processor.count(), failed)
 	}
@@ -128,6 +159,13 @@ func (p *Policy) GetWitnesses() []Entity {
 	return entitiesAll(p.witnesses)
 }
 
+// Returns the name of the witness with the given key hash, as used
+// when the policy was defined, or false if there's no such witness.
+func (p *Policy) WitnessName(keyHash *crypto.Hash) (string, bool) {
+	name, ok := p.witnessNames[*keyHash]
+	return name, ok
+}
+
 // Returns all logs with url specified, in randomized order.
 func (p *Policy) GetLogsWithUrl() []Entity {
 	return entitiesWithURL(p.logs)
@@ -105,15 +105,17 @@ func TestWitnessPolicy(t *testing.T) {
 		w           []int // Indices of witnesses to include
 		invalidate  int   // Signature to invalidate (-1 if none)
 		expectValid bool
+		// Expected result from CountCosignatures.
+		valid, missing int
 	}{
-		{"no cosignature", nil, -1, false},
-		{"only one cosignature", []int{0}, -1, false},
-		{"only two cosignatures", []int{0, 1, 4}, -1, false},
-		{"three cosignature", []int{0, 1, 2}, -1, true},
-		{"other three cosignature", []int{1, 2, 3}, -1, true},
-		{"all cosignatures", []int{0, 1, 2, 3, 4}, -1, true},
-		{"all cosignatures, one invalid", []int{0, 1, 2, 3, 4}, 2, true},
-		{"three cosignatures, but one invalid", []int{0, 2, 3, 4}, 2, false},
+		{"no cosignature", nil, -1, false, 0, 3},
+		{"only one cosignature", []int{0}, -1, false, 1, 2},
+		{"only two cosignatures", []int{0, 1, 4}, -1, false, 2, 1},
+		{"three cosignature", []int{0, 1, 2}, -1, true, 3, 0},
+		{"other three cosignature", []int{1, 2, 3}, -1, true, 3, 0},
+		{"all cosignatures", []int{0, 1, 2, 3, 4}, -1, true, 4, 0},
+		{"all cosignatures, one invalid", []int{0, 1, 2, 3, 4}, 2, true, 3, 0},
+		{"three cosignatures, but one invalid", []int{0, 2, 3, 4}, 2, false, 2, 1},
 	} {
 		present := make(map[crypto.Hash]types.Cosignature)
 		for _, i := range s.w {
@@ -131,6 +133,14 @@ func TestWitnessPolicy(t *testing.T) {
 		if !s.expectValid && err == nil {
 			t.Errorf("%s: Expected error, but got none", s.desc)
 		}
+		valid, missing, err := p.CountCosignatures(&td.logHash,
+			&types.CosignedTreeHead{SignedTreeHead: td.sth, Cosignatures: present})
+		if err != nil {
+			t.Errorf("%s: CountCosignatures failed: %v", s.desc, err)
+		} else if valid != s.valid || missing != s.missing {
+			t.Errorf("%s: Unexpected count, got %d valid, %d missing, expected %d valid, %d missing",
+				s.desc, valid, missing, s.valid, s.missing)
+		}
 	}
 }
 
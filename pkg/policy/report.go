@@ -0,0 +1,228 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+type CosignatureStatus int
+
+const (
+	// No cosignature from the witness.
+	CosignatureMissing CosignatureStatus = iota
+	CosignatureVerified
+	// Cosignature present, but not valid.
+	CosignatureFailed
+	// Valid cosignature, but its timestamp is outside of the
+	// witness key's validity period.
+	CosignatureKeyNotValid
+	// Valid cosignature, but older than the policy's max
+	// cosignature age.
+	CosignatureStale
+	// Valid cosignature, but timestamped in the future.
+	CosignatureFuture
+)
+
+func (s CosignatureStatus) String() string {
+	switch s {
+	case CosignatureMissing:
+		return "missing"
+	case CosignatureVerified:
+		return "verified"
+	case CosignatureFailed:
+		return "invalid"
+	case CosignatureKeyNotValid:
+		return "timestamp outside witness key validity"
+	case CosignatureStale:
+		return "too old"
+	case CosignatureFuture:
+		return "timestamp in the future"
+	default:
+		return fmt.Sprintf("unknown status %d", s)
+	}
+}
+
+// A QuorumReport node is either a witness, or a group with a
+// threshold and members, mirroring the policy's quorum tree.
+type QuorumReport struct {
+	// Non-nil for witness nodes.
+	Witness *Entity
+	Status  CosignatureStatus
+
+	// For group nodes.
+	Threshold int
+	Members   []*QuorumReport
+
+	Satisfied bool
+	// Minimum number of additional valid cosignatures needed to
+	// satisfy this node, zero if satisfied.
+	Missing int
+}
+
+// CosignatureReport explains how the cosignatures on a tree head
+// relate to the policy's quorum.
+type CosignatureReport struct {
+	Quorum *QuorumReport
+	// Number of valid and invalid cosignatures from the policy's
+	// witnesses.
+	Verified int
+	Failed   int
+	// Number of valid cosignatures rejected because of their
+	// timestamps.
+	Rejected int
+	// Key hashes of cosignatures from witnesses not in the policy,
+	// which are ignored.
+	Unknown []crypto.Hash
+}
+
+// This processor builds a QuorumReport, using *QuorumReport values
+// everywhere the Processor interface uses any.
+type reportProcessor struct {
+	witnesses map[crypto.Hash]Entity
+	status    map[crypto.Hash]CosignatureStatus
+}
+
+func (rp reportProcessor) ProcessWitness(kh crypto.Hash) any {
+	witness := rp.witnesses[kh]
+	r := QuorumReport{Witness: &witness, Status: rp.status[kh]}
+	r.Satisfied = r.Status == CosignatureVerified
+	if !r.Satisfied {
+		r.Missing = 1
+	}
+	return &r
+}
+
+func (_ reportProcessor) ProcessGroup(k int, members []any) any {
+	r := QuorumReport{Threshold: k}
+	missing := make([]int, len(members))
+	for i, m := range members {
+		r.Members = append(r.Members, m.(*QuorumReport))
+		missing[i] = r.Members[i].Missing
+	}
+	// Cheapest way to satisfy the group is to satisfy the k
+	// members needing the fewest additional cosignatures.
+	slices.Sort(missing)
+	for _, m := range missing[:k] {
+		r.Missing += m
+	}
+	r.Satisfied = r.Missing == 0
+	return &r
+}
+
+func (p *Policy) cosignatureReport(log *Entity, cth *types.CosignedTreeHead, now *time.Time) *CosignatureReport {
+	origin := types.SigsumCheckpointOrigin(&log.PublicKey)
+	processor := reportProcessor{
+		witnesses: p.witnesses,
+		status:    make(map[crypto.Hash]CosignatureStatus),
+	}
+	var report CosignatureReport
+	for keyHash, cs := range cth.Cosignatures {
+		witness, ok := p.witnesses[keyHash]
+		if !ok {
+			report.Unknown = append(report.Unknown, keyHash)
+			continue
+		}
+		status := p.checkCosignature(keyHash, &witness, origin, &cth.TreeHead, &cs, now)
+		processor.status[keyHash] = status
+		switch status {
+		case CosignatureVerified:
+			report.Verified++
+		case CosignatureFailed:
+			report.Failed++
+		default:
+			report.Rejected++
+		}
+	}
+	slices.SortFunc(report.Unknown, compareHashes)
+	report.Quorum = p.ProcessQuorum(processor).(*QuorumReport)
+	return &report
+}
+
+// ExplainCosignedTreeHead reports, for each witness and group in the
+// policy's quorum, whether or not it is satisfied by the tree head's
+// cosignatures. It is intended for diagnostics when
+// VerifyCosignedTreeHead fails. Returns an error if the log is
+// unknown or its signature is invalid.
+func (p *Policy) ExplainCosignedTreeHead(logKeyHash *crypto.Hash,
+	cth *types.CosignedTreeHead) (*CosignatureReport, error) {
+	return p.explainCosignedTreeHead(logKeyHash, cth, nil)
+}
+
+// Like ExplainCosignedTreeHead, but corresponding to
+// VerifyCosignedTreeHeadAt.
+func (p *Policy) ExplainCosignedTreeHeadAt(logKeyHash *crypto.Hash,
+	cth *types.CosignedTreeHead, now time.Time) (*CosignatureReport, error) {
+	return p.explainCosignedTreeHead(logKeyHash, cth, &now)
+}
+
+func (p *Policy) explainCosignedTreeHead(logKeyHash *crypto.Hash,
+	cth *types.CosignedTreeHead, now *time.Time) (*CosignatureReport, error) {
+	log, ok := p.logs[*logKeyHash]
+	if !ok {
+		return nil, fmt.Errorf("unknown log")
+	}
+	if !cth.Verify(&log.PublicKey) {
+		return nil, fmt.Errorf("invalid log signature")
+	}
+	return p.cosignatureReport(&log, cth, now), nil
+}
+
+// Returns the number of valid cosignatures on the tree head, from
+// the policy's witnesses, and the minimum number of additional
+// cosignatures needed to satisfy the quorum (zero if the quorum is
+// satisfied). The log's signature is not verified.
+func (p *Policy) CountCosignatures(logKeyHash *crypto.Hash,
+	cth *types.CosignedTreeHead) (int, int, error) {
+	log, ok := p.logs[*logKeyHash]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown log")
+	}
+	report := p.cosignatureReport(&log, cth, nil)
+	return report.Verified, report.Quorum.Missing, nil
+}
+
+// Formats the report as an indented tree, one line per witness and
+// group.
+func (r *CosignatureReport) String() string {
+	var b strings.Builder
+	if r.Quorum.Satisfied {
+		fmt.Fprintf(&b, "quorum satisfied")
+	} else {
+		fmt.Fprintf(&b, "quorum not satisfied, %d more cosignatures needed", r.Quorum.Missing)
+	}
+	fmt.Fprintf(&b, " (verified: %d, invalid: %d, ", r.Verified, r.Failed)
+	if r.Rejected > 0 {
+		fmt.Fprintf(&b, "rejected: %d, ", r.Rejected)
+	}
+	fmt.Fprintf(&b, "unknown: %d)\n", len(r.Unknown))
+	r.Quorum.format(&b, "")
+	for _, kh := range r.Unknown {
+		fmt.Fprintf(&b, "unknown witness %x: ignored\n", kh)
+	}
+	return b.String()
+}
+
+func (r *QuorumReport) format(b *strings.Builder, indent string) {
+	if r.Witness != nil {
+		fmt.Fprintf(b, "%switness %x", indent, crypto.HashBytes(r.Witness.PublicKey[:]))
+		if len(r.Witness.URL) > 0 {
+			fmt.Fprintf(b, " (%s)", r.Witness.URL)
+		}
+		fmt.Fprintf(b, ": %s\n", r.Status)
+		return
+	}
+	fmt.Fprintf(b, "%sgroup %d of %d: ", indent, r.Threshold, len(r.Members))
+	if r.Satisfied {
+		fmt.Fprintf(b, "satisfied\n")
+	} else {
+		fmt.Fprintf(b, "not satisfied, %d more needed\n", r.Missing)
+	}
+	for _, m := range r.Members {
+		m.format(b, indent+"  ")
+	}
+}
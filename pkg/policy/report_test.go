@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestExplainCosignedTreeHead(t *testing.T) {
+	td := newTestData(t, 6)
+
+	// Quorum requires both of: 2 of w0, w1, w2, and 1 of w3, w4.
+	// Witness 5 is not in the policy.
+	settings := []Setting{AddLog(&Entity{PublicKey: td.logPub})}
+	for i := 0; i < 5; i++ {
+		settings = append(settings, AddWitness(fmt.Sprintf("w%d", i), &Entity{PublicKey: td.witnessKeys[i]}))
+	}
+	settings = append(settings,
+		AddGroup("a", 2, []string{"w0", "w1", "w2"}),
+		AddGroup("b", 1, []string{"w3", "w4"}),
+		AddGroup("q", 2, []string{"a", "b"}),
+		SetQuorum("q"))
+	p, err := NewPolicy(settings...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cosignatures := map[crypto.Hash]types.Cosignature{
+		td.witnessHashes[0]: td.cosignatures[0],
+		td.witnessHashes[1]: td.cosignatures[1],
+		td.witnessHashes[5]: td.cosignatures[5],
+	}
+	// Invalidate w1's cosignature.
+	invalid := cosignatures[td.witnessHashes[1]]
+	invalid.Signature[0] ^= 1
+	cosignatures[td.witnessHashes[1]] = invalid
+
+	cth := types.CosignedTreeHead{SignedTreeHead: td.sth, Cosignatures: cosignatures}
+	report, err := p.ExplainCosignedTreeHead(&td.logHash, &cth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Verified != 1 || report.Failed != 1 || len(report.Unknown) != 1 || report.Unknown[0] != td.witnessHashes[5] {
+		t.Errorf("unexpected counts: verified %d, failed %d, unknown %v", report.Verified, report.Failed, report.Unknown)
+	}
+	q := report.Quorum
+	if q.Satisfied || q.Missing != 2 || q.Threshold != 2 || len(q.Members) != 2 {
+		t.Fatalf("unexpected quorum report: %#v", q)
+	}
+	a, b := q.Members[0], q.Members[1]
+	if a.Satisfied || a.Missing != 1 || b.Satisfied || b.Missing != 1 {
+		t.Errorf("unexpected group reports: %#v, %#v", a, b)
+	}
+	for i, want := range []CosignatureStatus{CosignatureVerified, CosignatureFailed, CosignatureMissing} {
+		m := a.Members[i]
+		if m.Witness == nil || m.Witness.PublicKey != td.witnessKeys[i] || m.Status != want {
+			t.Errorf("unexpected report for witness %d: %#v", i, m)
+		}
+	}
+	if got := report.String(); !strings.Contains(got, "2 more cosignatures needed") ||
+		!strings.Contains(got, fmt.Sprintf("\n    witness %x: invalid\n", td.witnessHashes[1])) {
+		t.Errorf("unexpected report string:\n%s", got)
+	}
+
+	// Satisfied quorum.
+	cosignatures[td.witnessHashes[1]] = td.cosignatures[1]
+	cosignatures[td.witnessHashes[4]] = td.cosignatures[4]
+	if report, err := p.ExplainCosignedTreeHead(&td.logHash, &cth); err != nil {
+		t.Fatal(err)
+	} else if !report.Quorum.Satisfied || report.Quorum.Missing != 0 {
+		t.Errorf("unexpected quorum report: %#v", report.Quorum)
+	}
+
+	// Invalid log signature.
+	cth.Signature[0] ^= 1
+	if _, err := p.ExplainCosignedTreeHead(&td.logHash, &cth); err == nil {
+		t.Errorf("explain with invalid log signature succeeded")
+	}
+}
@@ -0,0 +1,289 @@
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"sigsum.org/sigsum-go/pkg/ascii"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/key"
+)
+
+const (
+	// Suffix appended to the name of an installed policy file, to
+	// get the name of its signature file.
+	SignatureFilenameSuffix = ".sig"
+
+	// File with trusted policy signing keys. If it exists,
+	// installed policies must be signed by one of these keys.
+	defaultSigningKeysFile   = "/etc/sigsum/policy-signing-keys"
+	signingKeysEnvVariable   = "SIGSUM_POLICY_SIGNING_KEYS"
+	policySignatureNamespace = "sigsum.org/v1/policy-signature"
+
+	// File with minimum accepted versions of signed installed
+	// policies, to reject rollback to older versions.
+	defaultMinVersionsFile = "/etc/sigsum/policy-min-versions"
+	minVersionsEnvVariable = "SIGSUM_POLICY_MIN_VERSIONS"
+)
+
+// PolicySignature is a detached signature on a named policy file.
+// The signature covers the policy name and version, so that a signed
+// policy can't be installed under a different name, and so that old
+// versions can be rejected, see CheckVersion. The ASCII format is
+//
+//	name=<policy name>
+//	version=<decimal version number>
+//	checksum=<hex sha256 of policy file>
+//	signature=<key hash> <signature>
+//
+// with one or more signature lines.
+type PolicySignature struct {
+	Name    string
+	Version uint64
+	// Hash of the signed policy file.
+	Checksum   crypto.Hash
+	Signatures map[crypto.Hash]crypto.Signature
+}
+
+// The signed message is the namespace, a NUL byte, and then
+//
+//	name=<policy name>
+//	version=<version>
+//	checksum=<hex sha256 of policy file>
+func (s *PolicySignature) message() []byte {
+	return crypto.AttachNamespace(policySignatureNamespace,
+		[]byte(fmt.Sprintf("name=%s\nversion=%d\nchecksum=%x\n", s.Name, s.Version, s.Checksum)))
+}
+
+// Adds a signature on the policy file contents, replacing any
+// previous signature by the same key. Fails if there are existing
+// signatures on different contents.
+func (s *PolicySignature) Sign(signer crypto.Signer, contents []byte) error {
+	if err := checkName(s.Name); err != nil {
+		return err
+	}
+	checksum := crypto.HashBytes(contents)
+	if len(s.Signatures) > 0 && s.Checksum != checksum {
+		return fmt.Errorf("existing signatures on policy %q are for a different policy file", s.Name)
+	}
+	s.Checksum = checksum
+	signature, err := signer.Sign(s.message())
+	if err != nil {
+		return err
+	}
+	if s.Signatures == nil {
+		s.Signatures = make(map[crypto.Hash]crypto.Signature)
+	}
+	pub := signer.Public()
+	s.Signatures[crypto.HashBytes(pub[:])] = signature
+	return nil
+}
+
+// Verifies that name matches, and that there's at least one valid
+// signature on the policy file contents by a trusted key. Signatures
+// by other keys are ignored.
+func (s *PolicySignature) Verify(name string, contents []byte, trustedKeys map[crypto.Hash]crypto.PublicKey) error {
+	if s.Name != name {
+		return fmt.Errorf("policy signature is for name %q, not %q", s.Name, name)
+	}
+	if crypto.HashBytes(contents) != s.Checksum {
+		return fmt.Errorf("policy signature is for different contents of policy %q", name)
+	}
+	msg := s.message()
+	for keyHash, signature := range s.Signatures {
+		if pub, ok := trustedKeys[keyHash]; ok && crypto.Verify(&pub, msg, &signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no valid signature by a trusted key on policy %q", name)
+}
+
+// Checks that the version is at least the minimum version for the
+// policy's name, if any, see MinVersions. Must be used together with
+// Verify, to reject rollback to an older signed policy.
+func (s *PolicySignature) CheckVersion(minVersions map[string]uint64) error {
+	if min, ok := minVersions[s.Name]; ok && s.Version < min {
+		return fmt.Errorf("policy %q has version %d, older than the minimum version %d", s.Name, s.Version, min)
+	}
+	return nil
+}
+
+func (s *PolicySignature) ToASCII(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "name=%s\n", s.Name); err != nil {
+		return err
+	}
+	if err := ascii.WriteInt(w, "version", s.Version); err != nil {
+		return err
+	}
+	if err := ascii.WriteHash(w, "checksum", &s.Checksum); err != nil {
+		return err
+	}
+	keyHashes := make([]crypto.Hash, 0, len(s.Signatures))
+	for keyHash := range s.Signatures {
+		keyHashes = append(keyHashes, keyHash)
+	}
+	slices.SortFunc(keyHashes, compareHashes)
+	for _, keyHash := range keyHashes {
+		signature := s.Signatures[keyHash]
+		if err := ascii.WriteLine(w, "signature", keyHash[:], signature[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PolicySignature) FromASCII(r io.Reader) error {
+	p := ascii.NewParser(r)
+	v, err := p.GetValues("name", 1)
+	if err != nil {
+		return err
+	}
+	if err := checkName(v[0]); err != nil {
+		return err
+	}
+	s.Name = v[0]
+	if s.Version, err = p.GetInt("version"); err != nil {
+		return err
+	}
+	if s.Checksum, err = p.GetHash("checksum"); err != nil {
+		return err
+	}
+	s.Signatures = make(map[crypto.Hash]crypto.Signature)
+	for {
+		v, err := p.GetValues("signature", 2)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		keyHash, err := crypto.HashFromHex(v[0])
+		if err != nil {
+			return err
+		}
+		signature, err := crypto.SignatureFromHex(v[1])
+		if err != nil {
+			return err
+		}
+		if _, ok := s.Signatures[keyHash]; ok {
+			return fmt.Errorf("duplicate signature by key %x", keyHash)
+		}
+		s.Signatures[keyHash] = signature
+	}
+	if len(s.Signatures) == 0 {
+		return fmt.Errorf("no signatures")
+	}
+	return nil
+}
+
+func ReadPolicySignatureFile(fileName string) (PolicySignature, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return PolicySignature{}, err
+	}
+	defer f.Close()
+	var s PolicySignature
+	if err := s.FromASCII(f); err != nil {
+		return PolicySignature{}, fmt.Errorf("invalid policy signature file %q: %v", fileName, err)
+	}
+	return s, nil
+}
+
+// Returns the configured trusted policy signing keys, or nil if
+// there are none, i.e., if signatures on installed policies are not
+// required.
+func TrustedSigningKeys() (map[crypto.Hash]crypto.PublicKey, error) {
+	fileName := os.Getenv(signingKeysEnvVariable)
+	if len(fileName) == 0 {
+		if _, err := os.Stat(defaultSigningKeysFile); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		fileName = defaultSigningKeysFile
+	}
+	keys, err := key.ReadPublicKeysFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("reading trusted policy signing keys failed: %v", err)
+	}
+	return keys, nil
+}
+
+// Returns the configured minimum versions of signed policies, by
+// policy name, or nil if there are none. The file has one line per
+// policy, with the name and the minimum version separated by
+// whitespace. Empty lines and lines starting with "#" are ignored.
+func MinVersions() (map[string]uint64, error) {
+	fileName := os.Getenv(minVersionsEnvVariable)
+	if len(fileName) == 0 {
+		if _, err := os.Stat(defaultMinVersionsFile); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		fileName = defaultMinVersionsFile
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("reading minimum policy versions failed: %v", err)
+	}
+	defer f.Close()
+	minVersions := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected policy name and version", fileName, lineno)
+		}
+		if err := checkName(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, lineno, err)
+		}
+		version, err := ascii.IntFromDecimal(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid version: %v", fileName, lineno, err)
+		}
+		minVersions[fields[0]] = version
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading minimum policy versions failed: %v", err)
+	}
+	return minVersions, nil
+}
+
+// Reads the contents of an installed policy file, and, if trusted
+// signing keys are configured, verifies its signature file, and that
+// the version isn't older than the configured minimum version.
+func readInstalled(name string, f io.ReadCloser, fileName string) (io.ReadCloser, error) {
+	defer f.Close()
+	contents, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	trustedKeys, err := TrustedSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	if trustedKeys != nil {
+		s, err := ReadPolicySignatureFile(fileName + SignatureFilenameSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("policy %q: %v", name, err)
+		}
+		if err := s.Verify(name, contents, trustedKeys); err != nil {
+			return nil, err
+		}
+		minVersions, err := MinVersions()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.CheckVersion(minVersions); err != nil {
+			return nil, err
+		}
+	}
+	return io.NopCloser(bytes.NewReader(contents)), nil
+}
@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigsum.org/sigsum-go/internal/ssh"
+	"sigsum.org/sigsum-go/pkg/crypto"
+)
+
+func TestPolicySignature(t *testing.T) {
+	signer := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	pub := signer.Public()
+	otherSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	trusted := map[crypto.Hash]crypto.PublicKey{crypto.HashBytes(pub[:]): pub}
+	contents := []byte("quorum none\n")
+
+	s := PolicySignature{Name: "example", Version: 3}
+	if err := s.Sign(otherSigner, contents); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("example", contents, trusted); err == nil {
+		t.Errorf("signature by untrusted key accepted")
+	}
+	if err := s.Sign(signer, contents); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.ToASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var parsed PolicySignature
+	if err := parsed.FromASCII(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Name != "example" || parsed.Version != 3 || len(parsed.Signatures) != 2 {
+		t.Errorf("unexpected parsed signature: %v", parsed)
+	}
+	if err := parsed.Verify("example", contents, trusted); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := parsed.Verify("other", contents, trusted); err == nil {
+		t.Errorf("signature accepted for wrong name")
+	}
+	if err := parsed.Verify("example", []byte("quorum none\n# modified\n"), trusted); err == nil {
+		t.Errorf("signature accepted for modified policy")
+	}
+	if err := parsed.Sign(signer, []byte("quorum none\n# modified\n")); err == nil {
+		t.Errorf("signing different contents with existing signatures succeeded")
+	}
+	if err := parsed.CheckVersion(map[string]uint64{"example": 3, "other": 4}); err != nil {
+		t.Errorf("version rejected: %v", err)
+	}
+	if err := parsed.CheckVersion(map[string]uint64{"example": 4}); err == nil {
+		t.Errorf("version older than minimum accepted")
+	}
+	parsed.Version = 4
+	if err := parsed.Verify("example", contents, trusted); err == nil {
+		t.Errorf("signature accepted for wrong version")
+	}
+}
+
+func TestSignedNamedPolicy(t *testing.T) {
+	dir := t.TempDir()
+	signer := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	pub := signer.Public()
+	keysFile := filepath.Join(dir, "trusted.pub")
+	if err := os.WriteFile(keysFile, []byte(ssh.FormatPublicEd25519(&pub)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policyFile := filepath.Join(dir, "example"+InstalledPolicyFilenameSuffix)
+	contents := []byte("quorum none\n")
+	if err := os.WriteFile(policyFile, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(policyDirectoryEnvVariable, dir)
+
+	// No signature required, unless there's a signing keys file
+	// installed in the default location.
+	t.Setenv(signingKeysEnvVariable, "")
+	if _, err := os.Stat(defaultSigningKeysFile); os.IsNotExist(err) {
+		if _, err := ByName("example"); err != nil {
+			t.Errorf("reading unsigned policy failed: %v", err)
+		}
+	}
+
+	t.Setenv(signingKeysEnvVariable, keysFile)
+	if _, err := ByName("example"); err == nil {
+		t.Errorf("reading policy without signature file succeeded")
+	}
+
+	writeSignature := func(s *PolicySignature) {
+		var buf bytes.Buffer
+		if err := s.ToASCII(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(policyFile+SignatureFilenameSuffix, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Signed for a different name.
+	s := PolicySignature{Name: "other", Version: 1}
+	if err := s.Sign(signer, contents); err != nil {
+		t.Fatal(err)
+	}
+	writeSignature(&s)
+	if _, err := ByName("example"); err == nil {
+		t.Errorf("reading policy with signature for other name succeeded")
+	}
+
+	s = PolicySignature{Name: "example", Version: 1}
+	if err := s.Sign(signer, contents); err != nil {
+		t.Fatal(err)
+	}
+	writeSignature(&s)
+	if _, err := ByName("example"); err != nil {
+		t.Errorf("reading signed policy failed: %v", err)
+	}
+	// Rollback to an older version.
+	minVersionsFile := filepath.Join(dir, "min-versions")
+	if err := os.WriteFile(minVersionsFile, []byte("# Comment\nexample 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(minVersionsEnvVariable, minVersionsFile)
+	if _, err := ByName("example"); err == nil {
+		t.Errorf("reading policy older than minimum version succeeded")
+	}
+	s = PolicySignature{Name: "example", Version: 2}
+	if err := s.Sign(signer, contents); err != nil {
+		t.Fatal(err)
+	}
+	writeSignature(&s)
+	if data, err := ReadByName("example"); err != nil || !bytes.Equal(data, contents) {
+		t.Errorf("ReadByName of signed policy failed: %v", err)
+	}
+	// Builtin policies need no signature.
+	if _, err := ByName("sigsum-test1-2025"); err != nil {
+		t.Errorf("reading builtin policy failed: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+// Cosignature timestamps up to this far ahead of the reference time
+// are accepted, to tolerate witnesses with clocks running slightly
+// fast.
+const maxClockSkew = 10 * time.Minute
+
+// Validity is the period during which a witness key is used. A
+// cosignature is accepted only if its timestamp t satisfies
+// NotBefore <= t < NotAfter. A zero time means that there's no limit
+// in that direction. This is intended for witness key rollover: the
+// old and the new key can be listed as separate witnesses, with
+// adjacent validity periods.
+type Validity struct {
+	NotBefore, NotAfter time.Time
+}
+
+func (v *Validity) IsZero() bool {
+	return v.NotBefore.IsZero() && v.NotAfter.IsZero()
+}
+
+func (v *Validity) Contains(t time.Time) bool {
+	return (v.NotBefore.IsZero() || !t.Before(v.NotBefore)) &&
+		(v.NotAfter.IsZero() || t.Before(v.NotAfter))
+}
+
+func (v *Validity) Equal(other *Validity) bool {
+	return v.NotBefore.Equal(other.NotBefore) && v.NotAfter.Equal(other.NotAfter)
+}
+
+// Reports whether every time in the other validity period is also in
+// this one.
+func (v *Validity) covers(other *Validity) bool {
+	return (v.NotBefore.IsZero() || (!other.NotBefore.IsZero() && !other.NotBefore.Before(v.NotBefore))) &&
+		(v.NotAfter.IsZero() || (!other.NotAfter.IsZero() && !other.NotAfter.After(v.NotAfter)))
+}
+
+func (v Validity) String() string {
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return "unlimited"
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("[%s, %s)", format(v.NotBefore), format(v.NotAfter))
+}
+
+// Returns the validity period of the witness with the given key
+// hash; the zero Validity if unrestricted.
+func (p *Policy) WitnessValidity(keyHash *crypto.Hash) Validity {
+	return p.witnessValidity[*keyHash]
+}
+
+// Returns the policy's maximum cosignature age, or zero if there's
+// no limit.
+func (p *Policy) MaxCosignatureAge() time.Duration {
+	return p.maxCosignatureAge
+}
+
+// Converts a cosignature timestamp, clamping absurdly large values
+// to avoid overflow in time computations.
+func timestampTime(timestamp uint64) time.Time {
+	return time.Unix(int64(min(timestamp, 1<<62)), 0)
+}
+
+// Checks a single cosignature from one of the policy's witnesses. If
+// now is non-nil, the cosignature's timestamp is also checked against
+// that reference time.
+func (p *Policy) checkCosignature(keyHash crypto.Hash, witness *Entity, origin string,
+	th *types.TreeHead, cs *types.Cosignature, now *time.Time) CosignatureStatus {
+	if !cs.Verify(&witness.PublicKey, origin, th) {
+		return CosignatureFailed
+	}
+	t := timestampTime(cs.Timestamp)
+	if validity := p.witnessValidity[keyHash]; !validity.Contains(t) {
+		return CosignatureKeyNotValid
+	}
+	if now != nil {
+		if t.After(now.Add(maxClockSkew)) {
+			return CosignatureFuture
+		}
+		if p.maxCosignatureAge > 0 && now.Sub(t) > p.maxCosignatureAge {
+			return CosignatureStale
+		}
+	}
+	return CosignatureVerified
+}
@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestValidity(t *testing.T) {
+	rollover := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, table := range []struct {
+		validity Validity
+		t        time.Time
+		contains bool
+	}{
+		{Validity{}, rollover, true},
+		{Validity{NotBefore: rollover}, rollover, true},
+		{Validity{NotBefore: rollover}, rollover.Add(-time.Second), false},
+		{Validity{NotAfter: rollover}, rollover, false},
+		{Validity{NotAfter: rollover}, rollover.Add(-time.Second), true},
+	} {
+		if got := table.validity.Contains(table.t); got != table.contains {
+			t.Errorf("%v.Contains(%v): got %v, expected %v", table.validity, table.t, got, table.contains)
+		}
+	}
+}
+
+func TestVerifyCosignedTreeHeadAt(t *testing.T) {
+	rollover := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	th := types.TreeHead{Size: 3}
+	logPub, logSigner, err := crypto.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sth, err := th.Sign(logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logHash := crypto.HashBytes(logPub[:])
+	origin := types.SigsumCheckpointOrigin(&logPub)
+
+	oldPub, oldSigner, err := crypto.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPub, newSigner, err := crypto.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPolicy(AddLog(&Entity{PublicKey: logPub}),
+		AddWitness("old", &Entity{PublicKey: oldPub}),
+		AddWitness("new", &Entity{PublicKey: newPub}),
+		SetWitnessValidity("old", Validity{NotAfter: rollover}),
+		SetWitnessValidity("new", Validity{NotBefore: rollover}),
+		AddGroup("g", 1, []string{"old", "new"}),
+		SetQuorum("g"),
+		SetMaxCosignatureAge(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosigned := func(signer crypto.Signer, timestamp time.Time) *types.CosignedTreeHead {
+		cs, err := th.Cosign(signer, origin, uint64(timestamp.Unix()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := signer.Public()
+		keyHash := crypto.HashBytes(pub[:])
+		return &types.CosignedTreeHead{
+			SignedTreeHead: sth,
+			Cosignatures:   map[crypto.Hash]types.Cosignature{keyHash: cs},
+		}
+	}
+	for _, table := range []struct {
+		desc    string
+		cth     *types.CosignedTreeHead
+		now     time.Time
+		valid   bool // Without reference time.
+		validAt bool
+		status  CosignatureStatus // At reference time.
+		keyHash crypto.Hash
+	}{
+		{"old key, before rollover", cosigned(oldSigner, rollover.Add(-time.Hour)), rollover, true, true, CosignatureVerified, crypto.HashBytes(oldPub[:])},
+		{"old key, after rollover", cosigned(oldSigner, rollover.Add(time.Hour)), rollover.Add(time.Hour), false, false, CosignatureKeyNotValid, crypto.HashBytes(oldPub[:])},
+		{"new key, before rollover", cosigned(newSigner, rollover.Add(-time.Hour)), rollover, false, false, CosignatureKeyNotValid, crypto.HashBytes(newPub[:])},
+		{"new key, stale", cosigned(newSigner, rollover), rollover.Add(25 * time.Hour), true, false, CosignatureStale, crypto.HashBytes(newPub[:])},
+		{"new key, future", cosigned(newSigner, rollover.Add(time.Hour)), rollover, true, false, CosignatureFuture, crypto.HashBytes(newPub[:])},
+		{"new key, small skew", cosigned(newSigner, rollover.Add(time.Minute)), rollover, true, true, CosignatureVerified, crypto.HashBytes(newPub[:])},
+	} {
+		if err := p.VerifyCosignedTreeHead(&logHash, table.cth); (err == nil) != table.valid {
+			t.Errorf("%s: unexpected result without reference time: %v", table.desc, err)
+		}
+		err := p.VerifyCosignedTreeHeadAt(&logHash, table.cth, table.now)
+		if (err == nil) != table.validAt {
+			t.Errorf("%s: unexpected result at reference time: %v", table.desc, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "rejected by timestamp: 1") {
+			t.Errorf("%s: unexpected error message: %v", table.desc, err)
+		}
+		report, err := p.ExplainCosignedTreeHeadAt(&logHash, table.cth, table.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var status CosignatureStatus
+		for _, m := range report.Quorum.Members {
+			if crypto.HashBytes(m.Witness.PublicKey[:]) == table.keyHash {
+				status = m.Status
+			}
+		}
+		if status != table.status {
+			t.Errorf("%s: unexpected status %v, expected %v", table.desc, status, table.status)
+		}
+	}
+}
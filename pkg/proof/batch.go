@@ -0,0 +1,208 @@
+package proof
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+// Suffix of proof files, as written by sigsum-submit.
+const ProofSuffix = ".proof"
+
+// A Verifier verifies many proofs under the same policy. Each
+// distinct cosigned tree head is verified only once, which is useful
+// when many proofs share the same tree head, e.g., for artifacts
+// submitted together.
+type Verifier struct {
+	submitKeys map[crypto.Hash]crypto.PublicKey
+	policy     *policy.Policy
+	// If non-nil, reference time for checking cosignature
+	// timestamps.
+	now *time.Time
+	// Result of verifying cosigned tree heads, indexed by
+	// cosignedTreeHeadID.
+	treeHeads map[crypto.Hash]error
+}
+
+func NewVerifier(submitKeys map[crypto.Hash]crypto.PublicKey, policy *policy.Policy) *Verifier {
+	return &Verifier{
+		submitKeys: submitKeys,
+		policy:     policy,
+		treeHeads:  make(map[crypto.Hash]error),
+	}
+}
+
+// Like NewVerifier, but cosigned tree heads are verified using
+// VerifyCosignedTreeHeadAt, with the given reference time.
+func NewVerifierAt(submitKeys map[crypto.Hash]crypto.PublicKey, policy *policy.Policy, now time.Time) *Verifier {
+	v := NewVerifier(submitKeys, policy)
+	v.now = &now
+	return v
+}
+
+// Equivalent to sp.Verify(msg, submitKeys, policy), or sp.VerifyAt
+// if the verifier was created using NewVerifierAt.
+func (v *Verifier) Verify(msg *crypto.Hash, sp *SigsumProof) error {
+	return sp.verify(msg, v.submitKeys, func() error {
+		id := cosignedTreeHeadID(&sp.LogKeyHash, &sp.TreeHead)
+		err, ok := v.treeHeads[id]
+		if !ok {
+			if v.now != nil {
+				err = v.policy.VerifyCosignedTreeHeadAt(&sp.LogKeyHash, &sp.TreeHead, *v.now)
+			} else {
+				err = v.policy.VerifyCosignedTreeHead(&sp.LogKeyHash, &sp.TreeHead)
+			}
+			v.treeHeads[id] = err
+		}
+		return err
+	})
+}
+
+// Identifies a cosigned tree head, including the set of
+// cosignatures, independent of the order of the cosignatures.
+func cosignedTreeHeadID(logKeyHash *crypto.Hash, cth *types.CosignedTreeHead) crypto.Hash {
+	var buf bytes.Buffer
+	buf.Write(logKeyHash[:])
+	// Can't fail when writing to a buffer.
+	cth.SignedTreeHead.ToASCII(&buf)
+	keys := make([]crypto.Hash, 0, len(cth.Cosignatures))
+	for keyHash := range cth.Cosignatures {
+		keys = append(keys, keyHash)
+	}
+	slices.SortFunc(keys, func(a, b crypto.Hash) int { return bytes.Compare(a[:], b[:]) })
+	for _, keyHash := range keys {
+		cs := cth.Cosignatures[keyHash]
+		cs.ToASCII(&buf, &keyHash)
+	}
+	return crypto.HashBytes(buf.Bytes())
+}
+
+// A file, and the corresponding proof file.
+type BatchItem struct {
+	File      string
+	ProofFile string
+}
+
+// Returns an item for each file in the directory that has a
+// corresponding proof file, with the same name plus ProofSuffix.
+// Subdirectories are not examined. Fails if there are no such files.
+func BatchItemsFromDirectory(dir string) ([]BatchItem, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var items []BatchItem
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasSuffix(name, ProofSuffix) {
+			continue
+		}
+		proofFile := filepath.Join(dir, name+ProofSuffix)
+		if _, err := os.Stat(proofFile); err != nil {
+			continue
+		}
+		items = append(items, BatchItem{File: filepath.Join(dir, name), ProofFile: proofFile})
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no files with proofs in directory %q", dir)
+	}
+	return items, nil
+}
+
+// Reads a manifest file, listing one file per line, optionally
+// followed by white space and the name of the proof file. If the
+// proof file is omitted, it is the file name plus ProofSuffix.
+// Relative names are relative to the directory of the manifest.
+// Empty lines and lines starting with "#" are ignored.
+func BatchItemsFromManifest(manifest string) ([]BatchItem, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir := filepath.Dir(manifest)
+	resolve := func(name string) string {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	var items []BatchItem
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch len(fields) {
+		case 1:
+			items = append(items, BatchItem{File: resolve(fields[0]), ProofFile: resolve(fields[0] + ProofSuffix)})
+		case 2:
+			items = append(items, BatchItem{File: resolve(fields[0]), ProofFile: resolve(fields[1])})
+		default:
+			return nil, fmt.Errorf("%s:%d: invalid manifest line", manifest, lineno)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Result of verifying one item; Err is nil on success.
+type BatchResult struct {
+	BatchItem
+	Err error
+}
+
+// Verifies the proof for each item, with the message being the hash
+// of the file, as for sigsum-submit without --raw-hash. Returns one
+// result per item, in the same order.
+func VerifyBatch(items []BatchItem, submitKeys map[crypto.Hash]crypto.PublicKey, policy *policy.Policy) []BatchResult {
+	return NewVerifier(submitKeys, policy).VerifyBatch(items)
+}
+
+// Like the VerifyBatch function, but using the verifier's keys,
+// policy and reference time.
+func (v *Verifier) VerifyBatch(items []BatchItem) []BatchResult {
+	results := make([]BatchResult, len(items))
+	for i, item := range items {
+		results[i] = BatchResult{BatchItem: item, Err: v.verifyItem(&item)}
+	}
+	return results
+}
+
+func (v *Verifier) verifyItem(item *BatchItem) error {
+	msg, err := hashFile(item.File)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(item.ProofFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var sp SigsumProof
+	if err := sp.FromASCII(f); err != nil {
+		return fmt.Errorf("invalid proof %q: %v", item.ProofFile, err)
+	}
+	return v.Verify(&msg, &sp)
+}
+
+func hashFile(name string) (crypto.Hash, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	defer f.Close()
+	return crypto.HashFile(f)
+}
@@ -0,0 +1,158 @@
+package proof
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/merkle"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+// Writes count files to dir, logs them, and writes proof files
+// sharing the same cosigned tree head.
+func writeFilesAndProofs(t *testing.T, dir string, logSigner, witnessSigner, submitSigner crypto.Signer, count int) {
+	logKey := logSigner.Public()
+	submitKey := submitSigner.Public()
+	tree := merkle.NewTree()
+	var leaves []types.Leaf
+	for i := 0; i < count; i++ {
+		data := []byte(fmt.Sprintf("artifact %d", i))
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d", i)), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		msg := crypto.HashBytes(data)
+		signature, err := types.SignLeafMessage(submitSigner, msg[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf := types.Leaf{Checksum: crypto.HashBytes(msg[:]), Signature: signature, KeyHash: crypto.HashBytes(submitKey[:])}
+		leafHash := leaf.ToHash()
+		tree.AddLeafHash(&leafHash)
+		leaves = append(leaves, leaf)
+	}
+	th := types.TreeHead{Size: tree.Size(), RootHash: tree.GetRootHash()}
+	sth, err := th.Sign(logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := th.Cosign(witnessSigner, types.SigsumCheckpointOrigin(&logKey), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessKey := witnessSigner.Public()
+	cth := types.CosignedTreeHead{
+		SignedTreeHead: sth,
+		Cosignatures:   map[crypto.Hash]types.Cosignature{crypto.HashBytes(witnessKey[:]): cs},
+	}
+	for i, leaf := range leaves {
+		path, err := tree.ProveInclusion(uint64(i), tree.Size())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sp := SigsumProof{
+			LogKeyHash: crypto.HashBytes(logKey[:]),
+			Leaf:       NewShortLeaf(&leaf),
+			TreeHead:   cth,
+			Inclusion:  types.InclusionProof{LeafIndex: uint64(i), Path: path},
+		}
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("file%d", i)+ProofSuffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sp.ToASCII(f); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	witnessSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	submitSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{3})
+
+	dir := t.TempDir()
+	writeFilesAndProofs(t, dir, logSigner, witnessSigner, submitSigner, 4)
+	// File without proof is ignored, modified file fails.
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("no proof"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file2"), []byte("modified"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := BatchItemsFromDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(items), 4; got != want {
+		t.Fatalf("unexpected number of items, got %d, want %d", got, want)
+	}
+
+	submitKey := submitSigner.Public()
+	submitKeys := map[crypto.Hash]crypto.PublicKey{crypto.HashBytes(submitKey[:]): submitKey}
+	p, err := policy.NewKofNPolicy([]crypto.PublicKey{logSigner.Public()}, []crypto.PublicKey{witnessSigner.Public()}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range VerifyBatch(items, submitKeys, p) {
+		if wantFail := filepath.Base(r.File) == "file2"; wantFail != (r.Err != nil) {
+			t.Errorf("unexpected result for %q: %v", r.File, r.Err)
+		}
+	}
+
+	// Tree head is verified only once.
+	v := NewVerifier(submitKeys, p)
+	for _, item := range items {
+		v.verifyItem(&item)
+	}
+	if got := len(v.treeHeads); got != 1 {
+		t.Errorf("unexpected number of verified tree heads: %d", got)
+	}
+
+	// Wrong witness, all fail.
+	p, err = policy.NewKofNPolicy([]crypto.PublicKey{logSigner.Public()}, []crypto.PublicKey{submitKey}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range VerifyBatch(items, submitKeys, p) {
+		if r.Err == nil {
+			t.Errorf("unexpected success for %q", r.File)
+		}
+	}
+}
+
+func TestBatchItemsFromManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest")
+	if err := os.WriteFile(manifest, []byte("# Comment\n\nfoo\nbar proofs/bar.proof\n/abs/baz\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	items, err := BatchItemsFromManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BatchItem{
+		{filepath.Join(dir, "foo"), filepath.Join(dir, "foo.proof")},
+		{filepath.Join(dir, "bar"), filepath.Join(dir, "proofs/bar.proof")},
+		{"/abs/baz", "/abs/baz.proof"},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("unexpected items: %v", items)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("unexpected item %d, got %v, want %v", i, items[i], want[i])
+		}
+	}
+	if err := os.WriteFile(manifest, []byte("a b c\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BatchItemsFromManifest(manifest); err == nil {
+		t.Errorf("invalid manifest not rejected")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"time"
 
 	"sigsum.org/sigsum-go/pkg/ascii"
 	"sigsum.org/sigsum-go/pkg/crypto"
@@ -166,6 +167,23 @@ func (sp *SigsumProof) ToASCII(w io.Writer) error {
 }
 
 func (sp *SigsumProof) Verify(msg *crypto.Hash, submitKeys map[crypto.Hash]crypto.PublicKey, policy *policy.Policy) error {
+	return sp.verify(msg, submitKeys, func() error {
+		return policy.VerifyCosignedTreeHead(&sp.LogKeyHash, &sp.TreeHead)
+	})
+}
+
+// Like Verify, but the cosigned tree head is verified using
+// VerifyCosignedTreeHeadAt, rejecting stale cosignatures relative to
+// the reference time now.
+func (sp *SigsumProof) VerifyAt(msg *crypto.Hash, submitKeys map[crypto.Hash]crypto.PublicKey, policy *policy.Policy, now time.Time) error {
+	return sp.verify(msg, submitKeys, func() error {
+		return policy.VerifyCosignedTreeHeadAt(&sp.LogKeyHash, &sp.TreeHead, now)
+	})
+}
+
+// The verifyTreeHead function is called to verify the cosigned tree
+// head.
+func (sp *SigsumProof) verify(msg *crypto.Hash, submitKeys map[crypto.Hash]crypto.PublicKey, verifyTreeHead func() error) error {
 	checksum := crypto.HashBytes(msg[:])
 	leaf := sp.Leaf.ToLeaf(&checksum)
 	submitKey, ok := submitKeys[sp.Leaf.KeyHash]
@@ -175,7 +193,7 @@ func (sp *SigsumProof) Verify(msg *crypto.Hash, submitKeys map[crypto.Hash]crypt
 	if !leaf.Verify(&submitKey) {
 		return fmt.Errorf("leaf signature not valid")
 	}
-	if err := policy.VerifyCosignedTreeHead(&sp.LogKeyHash, &sp.TreeHead); err != nil {
+	if err := verifyTreeHead(); err != nil {
 		return err
 	}
 	leafHash := leaf.ToHash()
@@ -7,6 +7,7 @@ import (
 
 	"sigsum.org/sigsum-go/pkg/ascii"
 	"sigsum.org/sigsum-go/pkg/checkpoint"
+	"sigsum.org/sigsum-go/pkg/crypto"
 	"sigsum.org/sigsum-go/pkg/types"
 )
 
@@ -16,48 +17,58 @@ type AddCheckpoint struct {
 	Checkpoint checkpoint.Checkpoint
 }
 
-func (req *AddCheckpoint) FromASCII(r io.Reader) error {
-	p := ascii.NewLineReader(r)
-
+// Parses the old size line and the consistency proof, preceding the
+// checkpoint.
+func parseOldSizeAndProof(p *ascii.LineReader, proof *types.ConsistencyProof) (uint64, error) {
 	s, err := p.GetLine()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	s, found := strings.CutPrefix(s, "old ")
 	if !found {
-		return fmt.Errorf("invalid add-checkpoint request, invalid old line: %q", s)
+		return 0, fmt.Errorf("invalid add-checkpoint request, invalid old line: %q", s)
 	}
-	req.OldSize, err = ascii.IntFromDecimal(s)
+	oldSize, err := ascii.IntFromDecimal(s)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Parse proof lines.
-	if emptyLine, err := req.Proof.ParseBase64(&p); err != nil {
-		return err
+	if emptyLine, err := proof.ParseBase64(p); err != nil {
+		return 0, err
 	} else if !emptyLine {
-		return fmt.Errorf("invalid add-checkpoint request: %v", err)
-	}
-
-	if err := req.Checkpoint.Parse(&p); err != nil {
-		return err
+		return 0, fmt.Errorf("invalid add-checkpoint request: %v", err)
 	}
+	return oldSize, nil
+}
 
-	if req.OldSize > req.Checkpoint.TreeHead.Size {
-		return fmt.Errorf("invalid request, old_size(%d) > size(%d)",
-			req.OldSize, req.Checkpoint.TreeHead.Size)
+func checkOldSizeAndProof(oldSize, size uint64, proof *types.ConsistencyProof) error {
+	if oldSize > size {
+		return fmt.Errorf("invalid request, old_size(%d) > size(%d)", oldSize, size)
 	}
 	// Check for empty/non-empty consistency proof.
-	if req.OldSize == req.Checkpoint.TreeHead.Size || req.OldSize == 0 {
-		if len(req.Proof.Path) > 0 {
+	if oldSize == size || oldSize == 0 {
+		if len(proof.Path) > 0 {
 			return fmt.Errorf("invalid add-checkpoint request, expected empty consistency proof")
 		}
-	} else if len(req.Proof.Path) == 0 {
+	} else if len(proof.Path) == 0 {
 		return fmt.Errorf("invalid add-checkpoint request, consistency proof missing")
 	}
 	return nil
 }
 
+func (req *AddCheckpoint) FromASCII(r io.Reader) error {
+	p := ascii.NewLineReader(r)
+	var err error
+	if req.OldSize, err = parseOldSizeAndProof(&p, &req.Proof); err != nil {
+		return err
+	}
+	if err := req.Checkpoint.Parse(&p); err != nil {
+		return err
+	}
+	return checkOldSizeAndProof(req.OldSize, req.Checkpoint.TreeHead.Size, &req.Proof)
+}
+
 func (req *AddCheckpoint) ToASCII(w io.Writer) error {
 	if _, err := fmt.Fprintf(w, "old %d\n", req.OldSize); err != nil {
 		return err
@@ -70,3 +81,57 @@ func (req *AddCheckpoint) ToASCII(w io.Writer) error {
 	}
 	return req.Checkpoint.ToASCII(w)
 }
+
+// Like AddCheckpoint, but for any log conforming to the checkpoint
+// spec, see checkpoint.GenericCheckpoint.
+type AddGenericCheckpoint struct {
+	OldSize    uint64
+	Proof      types.ConsistencyProof
+	Checkpoint checkpoint.GenericCheckpoint
+}
+
+func (req *AddGenericCheckpoint) FromASCII(r io.Reader) error {
+	p := ascii.NewLineReader(r)
+	var err error
+	if req.OldSize, err = parseOldSizeAndProof(&p, &req.Proof); err != nil {
+		return err
+	}
+	if err := req.Checkpoint.Parse(&p); err != nil {
+		return err
+	}
+	return checkOldSizeAndProof(req.OldSize, req.Checkpoint.TreeHead.Size, &req.Proof)
+}
+
+func (req *AddGenericCheckpoint) ToASCII(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "old %d\n", req.OldSize); err != nil {
+		return err
+	}
+	if err := req.Proof.ToBase64(w); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "\n"); err != nil {
+		return err
+	}
+	return req.Checkpoint.ToASCII(w)
+}
+
+// Request for the cosigned checkpoint of a given size, from a
+// witness' history. The log is identified by the hash of its
+// checkpoint origin.
+type CosignedCheckpoint struct {
+	Size       uint64
+	OriginHash crypto.Hash
+}
+
+// ToURL encodes request parameters at the end of a slash-terminated URL
+func (req *CosignedCheckpoint) ToURL(url string) string {
+	return url + fmt.Sprintf("%d/%x", req.Size, req.OriginHash[:])
+}
+
+func (req *CosignedCheckpoint) FromURLArgs(size, originHash string) (err error) {
+	if req.Size, err = ascii.IntFromDecimal(size); err != nil {
+		return err
+	}
+	req.OriginHash, err = crypto.HashFromHex(originHash)
+	return err
+}
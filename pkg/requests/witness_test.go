@@ -3,6 +3,7 @@ package requests
 import (
 	"bytes"
 	"reflect"
+	"strings"
 	"testing"
 
 	"sigsum.org/sigsum-go/pkg/checkpoint"
@@ -57,3 +58,62 @@ func TestAddCheckpointFromASCII(t *testing.T) {
 		t.Errorf("unexpected FromASCII, got: %#v, want: %#v", got, want)
 	}
 }
+
+func TestAddGenericCheckpointASCII(t *testing.T) {
+	// Same request, with an extension line and an additional
+	// signature line, using a different key name.
+	ascii := testAddCheckpointASCII[:strings.Index(testAddCheckpointASCII, "\n\n—")+1] +
+		"extension line\n\n" +
+		"— example.org/log DEADBEEFAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n" +
+		"— other.example.org AAAAAAAA\n"
+	var req AddGenericCheckpoint
+	if err := req.FromASCII(bytes.NewBufferString(ascii)); err != nil {
+		t.Fatalf("FromASCII failed: %v", err)
+	}
+	if got, want := req.Checkpoint.Extensions, []string{"extension line"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected extension lines: %q", got)
+	}
+	if got := len(req.Checkpoint.Signatures); got != 2 {
+		t.Errorf("unexpected number of signatures: %d", got)
+	}
+	if req.OldSize != testAddCheckpoint.OldSize || !reflect.DeepEqual(req.Proof, testAddCheckpoint.Proof) ||
+		req.Checkpoint.TreeHead != testAddCheckpoint.Checkpoint.TreeHead {
+		t.Errorf("unexpected FromASCII, got: %#v", req)
+	}
+	buf := bytes.Buffer{}
+	if err := req.ToASCII(&buf); err != nil {
+		t.Fatalf("ToASCII failed: %v", err)
+	}
+	if got := buf.String(); got != ascii {
+		t.Errorf("unexpected ToASCII\n got: %q\nwant: %q", got, ascii)
+	}
+
+	// Same as AddCheckpoint, for a Sigsum checkpoint.
+	if err := req.FromASCII(bytes.NewBufferString(testAddCheckpointASCII)); err != nil {
+		t.Fatalf("FromASCII failed: %v", err)
+	}
+	if got, want := req.Checkpoint, testAddCheckpoint.Checkpoint.Generic(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected FromASCII, got: %#v, want: %#v", got, want)
+	}
+}
+
+func TestCosignedCheckpointURL(t *testing.T) {
+	url := types.EndpointGetCosignedCheckpoint.Path("https://witness.example.org")
+	req := CosignedCheckpoint{Size: 5, OriginHash: crypto.Hash{1}}
+	want := url + "5/0100000000000000000000000000000000000000000000000000000000000000"
+	if got := req.ToURL(url); got != want {
+		t.Errorf("got url %s but wanted %s", got, want)
+	}
+	var parsed CosignedCheckpoint
+	if err := parsed.FromURLArgs("5", "0100000000000000000000000000000000000000000000000000000000000000"); err != nil {
+		t.Fatal(err)
+	}
+	if parsed != req {
+		t.Errorf("unexpected FromURLArgs, got: %v, want: %v", parsed, req)
+	}
+	for _, args := range [][2]string{{"x", want[len(want)-64:]}, {"5", "01"}} {
+		if err := parsed.FromURLArgs(args[0], args[1]); err == nil {
+			t.Errorf("invalid args %q accepted", args)
+		}
+	}
+}
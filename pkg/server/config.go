@@ -5,7 +5,8 @@ import (
 )
 
 const (
-	defaultTimeout = 30 * time.Second
+	defaultTimeout            = 30 * time.Second
+	defaultMaxRequestBodySize = 64 * 1024
 )
 
 type Metrics interface {
@@ -22,6 +23,19 @@ type Config struct {
 	Prefix  string
 	Timeout time.Duration
 	Metrics Metrics
+	// Requests with a larger body are rejected. If zero,
+	// defaultMaxRequestBodySize is used.
+	MaxRequestBodySize int64
+	// Optional limits on add-checkpoint requests, applied by
+	// NewWitness and NewGenericWitness, per client IP address and
+	// per checkpoint origin. The origin limit is checked before
+	// any cryptographic verification, and applies only to origins
+	// for which KnownOrigin returns true, since the origin in a
+	// request is unauthenticated. Without KnownOrigin, no origin
+	// limit is applied.
+	ClientRateLimit RateLimit
+	OriginRateLimit RateLimit
+	KnownOrigin     func(origin string) bool
 }
 
 func (c *Config) withDefaults() Config {
@@ -29,6 +43,9 @@ func (c *Config) withDefaults() Config {
 	if config.Timeout == 0 {
 		config.Timeout = defaultTimeout
 	}
+	if config.MaxRequestBodySize == 0 {
+		config.MaxRequestBodySize = defaultMaxRequestBodySize
+	}
 	if config.Metrics == nil {
 		config.Metrics = noMetrics{}
 	}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+
+	"sigsum.org/sigsum-go/pkg/api"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func NewGossip(config *Config, gossip api.Gossip) http.Handler {
+	server := newServer(config)
+	server.register(http.MethodGet, types.EndpointGetGossipTreeHead, "", handlerBadRequest)
+	server.register(http.MethodGet, types.EndpointGetGossipTreeHead, "{key_hash}",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logKeyHash, err := crypto.HashFromHex(r.PathValue("key_hash"))
+			if err != nil {
+				reportError(w, r.URL, api.ErrBadRequest.WithError(err))
+				return
+			}
+			sth, err := gossip.GetGossipTreeHead(r.Context(), logKeyHash)
+			if err != nil {
+				reportError(w, r.URL, err)
+				return
+			}
+			if err := sth.ToASCII(w); err != nil {
+				logError(r.URL, err)
+			}
+		}))
+	return server
+}
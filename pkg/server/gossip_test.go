@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"sigsum.org/sigsum-go/pkg/api"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/mocks/mockapi"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestGetGossipTreeHead(t *testing.T) {
+	sth := types.SignedTreeHead{
+		TreeHead: types.TreeHead{
+			Size:     3,
+			RootHash: crypto.Hash{1},
+		},
+		Signature: crypto.Signature{2},
+	}
+	logKeyHash := crypto.Hash{3}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	gossip := mockapi.NewMockGossip(ctrl)
+
+	config := Config{Prefix: "foo", Timeout: 5 * time.Minute}
+	server := NewGossip(&config, gossip)
+
+	gossip.EXPECT().GetGossipTreeHead(gomock.Any(), logKeyHash).Return(sth, nil)
+	gossip.EXPECT().GetGossipTreeHead(gomock.Any(), crypto.Hash{4}).Return(types.SignedTreeHead{}, api.ErrNotFound)
+
+	result, body := queryServer(t, server, http.MethodGet, fmt.Sprintf("/foo/get-gossip-tree-head/%x", logKeyHash), "")
+	if got, want := result.StatusCode, 200; got != want {
+		t.Errorf("Unexpected status code, got %d, want %d", got, want)
+		return
+	}
+	if got, want := body, writeFuncToString(t, sth.ToASCII); got != want {
+		t.Errorf("Unexpected tree head, got %v, want %v", got, want)
+	}
+
+	for _, table := range []struct {
+		url    string
+		status int
+	}{
+		{fmt.Sprintf("/foo/get-gossip-tree-head/%x", crypto.Hash{4}), 404},
+		{"/foo/get-gossip-tree-head/", 400},
+		{"/foo/get-gossip-tree-head/123", 400},
+	} {
+		result, _ := queryServer(t, server, http.MethodGet, table.url, "")
+		if got, want := result.StatusCode, table.status; got != want {
+			t.Errorf("Unexpected status code for %q, got %d, want %d", table.url, got, want)
+		}
+	}
+}
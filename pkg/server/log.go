@@ -125,7 +125,7 @@ func NewLog(config *Config, log api.Log) http.Handler {
 			var req requests.Leaf
 			var submitHeader *token.SubmitHeader
 			if err := req.FromASCII(r.Body); err != nil {
-				reportError(w, r.URL, api.ErrBadRequest.WithError(err))
+				reportError(w, r.URL, requestBodyError(err))
 				return
 			}
 			if headerValue := r.Header.Get("Sigsum-Token"); len(headerValue) > 0 {
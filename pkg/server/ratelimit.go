@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// A RateLimit allows bursts of up to Burst requests, refilled at a
+// rate of Rate requests per second (a token bucket). The zero value
+// means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l RateLimit) IsZero() bool {
+	return l.Rate == 0 && l.Burst == 0
+}
+
+// Maximum number of keys a limiter tracks. When reached, keys with
+// full buckets are discarded, since they're equivalent to keys not
+// seen before. If that's not enough, the least recently used keys are
+// evicted, down to limiterEvictSize, so that the cost of cleanup is
+// amortized over many new keys.
+const (
+	limiterCleanupSize = 10000
+	limiterEvictSize   = limiterCleanupSize * 3 / 4
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Rate limiter with one token bucket per key, e.g., per client or
+// per checkpoint origin.
+type limiter struct {
+	limit RateLimit
+	// For tests.
+	now     func() time.Time
+	m       sync.Mutex
+	buckets map[string]*bucket
+}
+
+// Returns nil if limit is the zero value. A nil limiter allows all
+// requests.
+func newLimiter(limit RateLimit) *limiter {
+	if limit.IsZero() {
+		return nil
+	}
+	return &limiter{limit: limit, now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Returns the number of tokens in the bucket at the given time.
+func (l *limiter) tokens(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.limit.Rate
+	if burst := float64(l.limit.Burst); tokens > burst {
+		return burst
+	}
+	return tokens
+}
+
+// Consumes a token for the given key, and returns true, if any is
+// available.
+func (l *limiter) Allow(key string) bool {
+	if l == nil {
+		return true
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= limiterCleanupSize {
+			l.cleanup(now)
+		}
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens, b.last = l.tokens(b, now), now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Must be called with lock held.
+func (l *limiter) cleanup(now time.Time) {
+	for key, b := range l.buckets {
+		if l.tokens(b, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	if len(l.buckets) <= limiterEvictSize {
+		return
+	}
+	keys := make([]string, 0, len(l.buckets))
+	for key := range l.buckets {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return l.buckets[a].last.Compare(l.buckets[b].last)
+	})
+	for _, key := range keys[:len(keys)-limiterEvictSize] {
+		delete(l.buckets, key)
+	}
+}
+
+// Identifies the client by the IP address of the remote end of the
+// connection. Any X-Forwarded-For header is ignored, since it can't
+// be trusted without knowing about the proxy configuration.
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
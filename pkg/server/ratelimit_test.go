@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newLimiter(RateLimit{Rate: 0.5, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	for i, want := range []bool{true, true, false} {
+		if got := l.Allow("a"); got != want {
+			t.Errorf("request %d: got %v, want %v", i, got, want)
+		}
+	}
+	// Independent buckets.
+	if !l.Allow("b") {
+		t.Errorf("request for other key denied")
+	}
+	// One token refilled after 2s.
+	now = now.Add(2 * time.Second)
+	if !l.Allow("a") {
+		t.Errorf("request denied after refill")
+	}
+	if l.Allow("a") {
+		t.Errorf("request allowed with empty bucket")
+	}
+	// Never more than burst tokens.
+	now = now.Add(time.Hour)
+	for i, want := range []bool{true, true, false} {
+		if got := l.Allow("a"); got != want {
+			t.Errorf("request %d after long pause: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestLimiterCleanup(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newLimiter(RateLimit{Rate: 1, Burst: 1})
+	l.now = func() time.Time { return now }
+	for i := 0; i < limiterCleanupSize; i++ {
+		l.Allow(fmt.Sprintf("%d", i))
+	}
+	if got := len(l.buckets); got != limiterCleanupSize {
+		t.Fatalf("unexpected number of buckets: %d", got)
+	}
+	now = now.Add(time.Second)
+	l.Allow("new")
+	if got := len(l.buckets); got != 1 {
+		t.Errorf("full buckets not discarded, %d buckets left", got)
+	}
+}
+
+func TestLimiterEvict(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newLimiter(RateLimit{Rate: 1e-6, Burst: 1})
+	l.now = func() time.Time { return now }
+	// No bucket is refilled, so the least recently used are
+	// evicted.
+	for i := 0; i < 3*limiterCleanupSize; i++ {
+		l.Allow(fmt.Sprintf("%d", i))
+		now = now.Add(time.Millisecond)
+		if got := len(l.buckets); got > limiterCleanupSize {
+			t.Fatalf("too many buckets: %d", got)
+		}
+	}
+	if l.Allow(fmt.Sprintf("%d", 3*limiterCleanupSize-1)) {
+		t.Errorf("recently used bucket was evicted")
+	}
+	if !l.Allow("0") {
+		t.Errorf("least recently used bucket was not evicted")
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	l := newLimiter(RateLimit{})
+	if l != nil {
+		t.Fatalf("expected nil limiter for zero limit")
+	}
+	if !l.Allow("a") {
+		t.Errorf("nil limiter denied request")
+	}
+}
@@ -10,6 +10,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/url"
 	"time"
@@ -69,6 +70,9 @@ func (h *handlerWithMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), s.config.Timeout)
 	defer cancel()
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxRequestBodySize)
+	}
 	s.mux.ServeHTTP(w, r.WithContext(ctx))
 }
 
@@ -90,6 +94,15 @@ func reportError(w http.ResponseWriter, url *url.URL, err error) {
 	http.Error(w, err.Error(), statusCode)
 }
 
+// Returns the error to report when parsing the request body fails.
+func requestBodyError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return api.NewError(http.StatusRequestEntityTooLarge, err)
+	}
+	return api.ErrBadRequest.WithError(err)
+}
+
 func logError(url *url.URL, err error) {
 	log.Debug("%q: request failed: %v", url.Path, err)
 }
@@ -12,36 +12,111 @@ import (
 
 func NewWitness(config *Config, witness api.Witness) http.Handler {
 	server := newServer(config)
+	clientLimiter := newLimiter(server.config.ClientRateLimit)
+	originLimiter := newLimiter(server.config.OriginRateLimit)
 	server.register(http.MethodPost, types.EndpointAddCheckpoint, "",
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !clientLimiter.Allow(clientKey(r)) {
+				reportError(w, r.URL, api.ErrTooManyRequests)
+				return
+			}
 			var req requests.AddCheckpoint
 			if err := req.FromASCII(r.Body); err != nil {
-				reportError(w, r.URL, api.ErrBadRequest.WithError(err))
+				reportError(w, r.URL, requestBodyError(err))
+				return
+			}
+			if !allowOrigin(&server.config, originLimiter, req.Checkpoint.Origin) {
+				reportError(w, r.URL, api.ErrTooManyRequests)
 				return
 			}
-
 			signatures, err := witness.AddCheckpoint(r.Context(), req)
+			writeCosignatures(w, r, signatures, err)
+		}))
+
+	return server
+}
+
+// Like NewWitness, but accepting checkpoints that don't satisfy the
+// restrictions on Sigsum checkpoints, see
+// checkpoint.GenericCheckpoint.
+func NewGenericWitness(config *Config, witness api.GenericWitness) http.Handler {
+	server := newServer(config)
+	clientLimiter := newLimiter(server.config.ClientRateLimit)
+	originLimiter := newLimiter(server.config.OriginRateLimit)
+	server.register(http.MethodPost, types.EndpointAddCheckpoint, "",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !clientLimiter.Allow(clientKey(r)) {
+				reportError(w, r.URL, api.ErrTooManyRequests)
+				return
+			}
+			var req requests.AddGenericCheckpoint
+			if err := req.FromASCII(r.Body); err != nil {
+				reportError(w, r.URL, requestBodyError(err))
+				return
+			}
+			if !allowOrigin(&server.config, originLimiter, req.Checkpoint.Origin) {
+				reportError(w, r.URL, api.ErrTooManyRequests)
+				return
+			}
+			signatures, err := witness.AddGenericCheckpoint(r.Context(), req)
+			writeCosignatures(w, r, signatures, err)
+		}))
+
+	return server
+}
+
+// Serves the read-only get-cosigned-checkpoint endpoint, for auditing
+// a witness' history. Intended to be combined with the handler
+// returned by NewWitness or NewGenericWitness, using the same prefix.
+func NewWitnessHistory(config *Config, history api.WitnessHistory) http.Handler {
+	server := newServer(config)
+	server.register(http.MethodGet, types.EndpointGetCosignedCheckpoint, "", handlerBadRequest)
+	server.register(http.MethodGet, types.EndpointGetCosignedCheckpoint, "{size}/{origin_hash}",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req requests.CosignedCheckpoint
+			if err := req.FromURLArgs(r.PathValue("size"), r.PathValue("origin_hash")); err != nil {
+				reportError(w, r.URL, api.ErrBadRequest.WithError(err))
+				return
+			}
+			cp, err := history.GetCosignedCheckpoint(r.Context(), req)
 			if err != nil {
-				if oldSize, ok := api.ErrorConflictOldSize(err); ok {
-					w.Header().Set("content-type", checkpoint.ContentTypeTlogSize)
-					w.WriteHeader(http.StatusConflict)
-
-					if _, err := fmt.Fprintf(w, "%d\n", oldSize); err != nil {
-						logError(r.URL, err)
-					}
-					return
-				}
 				reportError(w, r.URL, err)
 				return
 			}
-
-			for _, signature := range signatures {
-				if err := signature.ToASCII(w); err != nil {
-					logError(r.URL, err)
-					return
-				}
+			if err := cp.ToASCII(w); err != nil {
+				logError(r.URL, err)
 			}
 		}))
-
 	return server
 }
+
+// Writes the response to an add-checkpoint request.
+func writeCosignatures(w http.ResponseWriter, r *http.Request, signatures []checkpoint.CosignatureLine, err error) {
+	if err != nil {
+		if oldSize, ok := api.ErrorConflictOldSize(err); ok {
+			w.Header().Set("content-type", checkpoint.ContentTypeTlogSize)
+			w.WriteHeader(http.StatusConflict)
+
+			if _, err := fmt.Fprintf(w, "%d\n", oldSize); err != nil {
+				logError(r.URL, err)
+			}
+			return
+		}
+		reportError(w, r.URL, err)
+		return
+	}
+
+	for _, signature := range signatures {
+		if err := signature.ToASCII(w); err != nil {
+			logError(r.URL, err)
+			return
+		}
+	}
+}
+
+// Applies the origin rate limit, to known origins only. Charging
+// unknown origins would let any client create buckets at will, and
+// the witness rejects them without cryptographic work anyway.
+func allowOrigin(config *Config, l *limiter, origin string) bool {
+	return config.KnownOrigin == nil || !config.KnownOrigin(origin) || l.Allow(origin)
+}
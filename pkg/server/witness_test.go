@@ -2,8 +2,10 @@ package server
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -98,3 +100,108 @@ func TestAddCheckpoint(t *testing.T) {
 		}(req)
 	}
 }
+
+func TestGetCosignedCheckpoint(t *testing.T) {
+	cp := checkpoint.GenericCheckpoint{
+		Origin:   "example.org/log",
+		TreeHead: types.TreeHead{Size: 5, RootHash: crypto.Hash{1}},
+		Signatures: []checkpoint.NoteSignature{
+			checkpoint.NoteSignature{KeyName: "example.org/log", KeyId: checkpoint.KeyId{2}, Signature: make([]byte, crypto.SignatureSize)},
+			checkpoint.NoteSignature{KeyName: "example.org/witness", KeyId: checkpoint.KeyId{3}, Signature: make([]byte, 8+crypto.SignatureSize)},
+		},
+	}
+	originHash := crypto.HashBytes([]byte(cp.Origin))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	history := mockapi.NewMockWitnessHistory(ctrl)
+
+	config := Config{Prefix: "foo", Timeout: 5 * time.Minute}
+	server := NewWitnessHistory(&config, history)
+
+	history.EXPECT().GetCosignedCheckpoint(gomock.Any(), requests.CosignedCheckpoint{Size: 5, OriginHash: originHash}).Return(cp, nil)
+	history.EXPECT().GetCosignedCheckpoint(gomock.Any(), requests.CosignedCheckpoint{Size: 4, OriginHash: originHash}).Return(
+		checkpoint.GenericCheckpoint{}, api.ErrNotFound)
+
+	result, body := queryServer(t, server, http.MethodGet, fmt.Sprintf("/foo/get-cosigned-checkpoint/5/%x", originHash), "")
+	if got, want := result.StatusCode, 200; got != want {
+		t.Fatalf("Unexpected status code, got %d, want %d", got, want)
+	}
+	if got, want := body, writeFuncToString(t, cp.ToASCII); got != want {
+		t.Errorf("Unexpected checkpoint, got %q, want %q", got, want)
+	}
+
+	for _, table := range []struct {
+		url    string
+		status int
+	}{
+		{fmt.Sprintf("/foo/get-cosigned-checkpoint/4/%x", originHash), 404},
+		{"/foo/get-cosigned-checkpoint/", 400},
+		{"/foo/get-cosigned-checkpoint/5/123", 400},
+		{fmt.Sprintf("/foo/get-cosigned-checkpoint/x/%x", originHash), 400},
+	} {
+		result, _ := queryServer(t, server, http.MethodGet, table.url, "")
+		if got, want := result.StatusCode, table.status; got != want {
+			t.Errorf("Unexpected status code for %q, got %d, want %d", table.url, got, want)
+		}
+	}
+}
+
+func TestAddCheckpointLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	witness := mockapi.NewMockWitness(ctrl)
+
+	config := Config{
+		Prefix:             "foo",
+		Timeout:            5 * time.Minute,
+		MaxRequestBodySize: 1000,
+		// Low rates, so that no tokens are refilled during the test.
+		ClientRateLimit: RateLimit{Rate: 1e-6, Burst: 2},
+		OriginRateLimit: RateLimit{Rate: 1e-6, Burst: 2},
+		KnownOrigin:     func(origin string) bool { return strings.HasPrefix(origin, "example.org/log") },
+	}
+	server := NewWitness(&config, witness)
+	witness.EXPECT().AddCheckpoint(gomock.Any(), gomock.Any()).Return(nil, nil).Times(6)
+
+	post := func(client, origin string) int {
+		req := requests.AddCheckpoint{
+			Checkpoint: checkpoint.Checkpoint{
+				Origin: origin,
+				SignedTreeHead: types.SignedTreeHead{
+					TreeHead: types.TreeHead{Size: 5, RootHash: crypto.Hash{4, 5, 6}},
+				},
+			},
+		}
+		result, _ := queryServerHook(t, server, http.MethodPost, "/foo/add-checkpoint", writeFuncToString(t, req.ToASCII),
+			func(r *http.Request) *http.Request {
+				r.RemoteAddr = client + ":4711"
+				return r
+			})
+		return result.StatusCode
+	}
+	for i, table := range []struct {
+		client string
+		origin string
+		status int
+	}{
+		{"192.0.2.1", "example.org/log1", 200},
+		{"192.0.2.2", "example.org/log1", 200},
+		{"192.0.2.3", "example.org/log1", 429}, // Origin limit.
+		{"192.0.2.1", "example.org/log2", 200},
+		{"192.0.2.1", "example.org/log3", 429}, // Client limit.
+		// Unknown origins are not limited, and don't use up
+		// any bucket.
+		{"192.0.2.5", "example.org/bogus", 200},
+		{"192.0.2.6", "example.org/bogus", 200},
+		{"192.0.2.7", "example.org/bogus", 200},
+	} {
+		if got := post(table.client, table.origin); got != table.status {
+			t.Errorf("Unexpected status code for request %d, got %d, want %d", i, got, table.status)
+		}
+	}
+
+	if got, want := post("192.0.2.4", "example.org/"+strings.Repeat("x", 1000)), http.StatusRequestEntityTooLarge; got != want {
+		t.Errorf("Unexpected status code for large request, got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package submit
+
+import (
+	"context"
+	"fmt"
+
+	"sigsum.org/sigsum-go/pkg/log"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/proof"
+	"sigsum.org/sigsum-go/pkg/requests"
+)
+
+type EventType int
+
+const (
+	// An add-leaf request is being submitted to a log.
+	EventSubmitted EventType = iota
+	// The log responded with HTTP status 2XX to the add-leaf request.
+	EventAccepted
+	// A request to a log failed, or a log's response was not
+	// acceptable. The request is retried, or a different log is
+	// tried; the error is not permanent.
+	EventLogError
+	// The leaf has been sequenced by the log, i.e., the log
+	// responded 200 OK to the add-leaf request.
+	EventSequenced
+	// The log's latest tree head doesn't yet satisfy the policy's
+	// quorum. Reported when the number of cosignatures changes.
+	EventWaitingForCosignatures
+	// A proof of logging has been collected.
+	EventProofReady
+	// Giving up on the leaf request.
+	EventFailed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSubmitted:
+		return "submitted"
+	case EventAccepted:
+		return "accepted"
+	case EventLogError:
+		return "log-error"
+	case EventSequenced:
+		return "sequenced"
+	case EventWaitingForCosignatures:
+		return "waiting-for-cosignatures"
+	case EventProofReady:
+		return "proof-ready"
+	case EventFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown event type %d", t)
+	}
+}
+
+// Event reports progress for one of the leaf requests.
+type Event struct {
+	Type EventType
+	// Index of the leaf request.
+	Index int
+	// The log the event refers to, nil for EventFailed when all
+	// logs failed.
+	Log *policy.Entity
+	// For EventWaitingForCosignatures, the number of valid
+	// cosignatures on the log's latest tree head, and the minimum
+	// number of additional cosignatures needed to satisfy the
+	// quorum.
+	Cosignatures        int
+	MissingCosignatures int
+	// For EventProofReady.
+	Proof *proof.SigsumProof
+	// For EventLogError and EventFailed.
+	Err error
+}
+
+func (e *Event) String() string {
+	s := fmt.Sprintf("checksum#%d: %s", e.Index+1, e.Type)
+	if e.Log != nil {
+		s += fmt.Sprintf(", log %s", e.Log.URL)
+	}
+	if e.Type == EventWaitingForCosignatures {
+		s += fmt.Sprintf(", %d cosignatures, %d more needed", e.Cosignatures, e.MissingCosignatures)
+	}
+	if e.Err != nil {
+		s += fmt.Sprintf(": %v", e.Err)
+	}
+	return s
+}
+
+// Function to receive events, may be nil. Must be safe for
+// concurrent use.
+type reporter func(Event)
+
+func (r reporter) report(e Event) {
+	if r != nil {
+		r(e)
+	}
+}
+
+// Tracks the progress of collecting a proof for a pending submission,
+// to report events only when something changes.
+type progress struct {
+	reporter
+	submission   *pendingSubmission
+	sequenced    bool
+	cosignatures int
+	missing      int
+}
+
+func newProgress(r reporter, submission *pendingSubmission) *progress {
+	return &progress{reporter: r, submission: submission, cosignatures: -1, missing: -1}
+}
+
+func (p *progress) event(t EventType) Event {
+	return Event{Type: t, Index: p.submission.index, Log: &p.submission.log.entity}
+}
+
+func (p *progress) logError(err error) {
+	e := p.event(EventLogError)
+	e.Err = err
+	p.report(e)
+}
+
+func (p *progress) setSequenced() {
+	if !p.sequenced {
+		p.sequenced = true
+		p.report(p.event(EventSequenced))
+	}
+}
+
+func (p *progress) setCosignatures(report *policy.CosignatureReport) {
+	if report.Verified != p.cosignatures || report.Quorum.Missing != p.missing {
+		p.cosignatures, p.missing = report.Verified, report.Quorum.Missing
+		log.Info("Waiting for cosignatures on tree head from log %s: %s",
+			p.submission.log.entity.URL, report)
+		e := p.event(EventWaitingForCosignatures)
+		e.Cosignatures, e.MissingCosignatures = report.Verified, report.Quorum.Missing
+		p.report(e)
+	}
+}
+
+// Submission is a handle for an asynchronous submission, see
+// SubmitLeafRequestsAsync.
+type Submission struct {
+	events chan Event
+	proofs [][]proof.SigsumProof
+	err    error
+}
+
+// SubmitLeafRequestsAsync is like SubmitLeafRequestsRedundant, but
+// returns immediately. Progress is reported as events on the
+// returned submission's Events channel; the application must either
+// receive all events, call Wait, or cancel ctx, or the submission is
+// blocked. After ctx is cancelled, events that are not received are
+// dropped. Events for different requests are interleaved, but events
+// for a particular request and log are delivered in order.
+func SubmitLeafRequestsAsync(ctx context.Context, config *Config, reqs []requests.Leaf) *Submission {
+	s := Submission{events: make(chan Event)}
+	go func() {
+		defer close(s.events)
+		s.proofs, s.err = submitLeafRequests(ctx, config, reqs, func(e Event) {
+			select {
+			case s.events <- e:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return &s
+}
+
+// Events returns the channel of progress events. The channel is
+// closed when the submission is done.
+func (s *Submission) Events() <-chan Event {
+	return s.events
+}
+
+// Wait discards any remaining events, waits for the submission to
+// complete, and returns the first collected proof for each request,
+// in the same order as the requests.
+func (s *Submission) Wait() ([]proof.SigsumProof, error) {
+	all, err := s.WaitRedundant()
+	if err != nil {
+		return nil, err
+	}
+	return firstProofs(all), nil
+}
+
+// WaitRedundant is like Wait, but returns all collected proofs for
+// each request, see SubmitLeafRequestsRedundant.
+func (s *Submission) WaitRedundant() ([][]proof.SigsumProof, error) {
+	for range s.events {
+	}
+	return s.proofs, s.err
+}
@@ -0,0 +1,145 @@
+package submit
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/memlog"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/server"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestSubmitLeafRequestsAsync(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	logPub := logSigner.Public()
+	submitSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	submitPub := submitSigner.Public()
+	submitKeys := map[crypto.Hash]crypto.PublicKey{crypto.HashBytes(submitPub[:]): submitPub}
+
+	log, err := memlog.New(memlog.Config{Signer: logSigner, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server.NewLog(&server.Config{}, log))
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go log.Run(ctx)
+
+	msg := crypto.Hash{1}
+	signature, err := types.SignLeafMessage(submitSigner, msg[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := []requests.Leaf{requests.Leaf{Message: msg, Signature: signature, PublicKey: submitPub}}
+
+	// Collect the types of the events, skipping log errors.
+	eventTypes := func(s *Submission) []EventType {
+		var types []EventType
+		for e := range s.Events() {
+			if e.Index != 0 {
+				t.Errorf("unexpected index for event: %v", &e)
+			}
+			if e.Type != EventLogError {
+				types = append(types, e.Type)
+			}
+		}
+		return types
+	}
+
+	t.Run("success", func(t *testing.T) {
+		p, err := policy.NewPolicy(policy.AddLog(&policy.Entity{PublicKey: logPub, URL: httpServer.URL}), policy.SetQuorum("none"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := SubmitLeafRequestsAsync(context.Background(), &Config{Policy: p, PollDelay: 10 * time.Millisecond}, reqs)
+		if got, want := eventTypes(s), []EventType{EventSubmitted, EventAccepted, EventSequenced, EventProofReady}; !slices.Equal(got, want) {
+			t.Errorf("unexpected events, got %v, want %v", got, want)
+		}
+		proofs, err := s.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := proofs[0].Verify(&msg, submitKeys, p); err != nil {
+			t.Errorf("proof not valid: %v", err)
+		}
+	})
+	t.Run("no cosignatures", func(t *testing.T) {
+		witnessPub := crypto.PublicKey{3}
+		p, err := policy.NewPolicy(
+			policy.AddLog(&policy.Entity{PublicKey: logPub, URL: httpServer.URL}),
+			policy.AddWitness("w", &policy.Entity{PublicKey: witnessPub}),
+			policy.SetQuorum("w"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := SubmitLeafRequestsAsync(context.Background(), &Config{
+			Policy:    p,
+			PollDelay: 10 * time.Millisecond,
+			Timeout:   500 * time.Millisecond,
+		}, reqs)
+		var waiting []Event
+		var failed bool
+		for e := range s.Events() {
+			switch e.Type {
+			case EventWaitingForCosignatures:
+				waiting = append(waiting, e)
+			case EventFailed:
+				failed = true
+			}
+		}
+		// Tree head never gets any cosignatures, so only a
+		// single event is expected.
+		if len(waiting) != 1 || waiting[0].Cosignatures != 0 || waiting[0].MissingCosignatures != 1 {
+			t.Errorf("unexpected waiting events: %v", waiting)
+		}
+		if !failed {
+			t.Errorf("no failed event")
+		}
+		if _, err := s.Wait(); err == nil {
+			t.Errorf("submission without cosignatures succeeded")
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		witnessPub := crypto.PublicKey{3}
+		p, err := policy.NewPolicy(
+			policy.AddLog(&policy.Entity{PublicKey: logPub, URL: httpServer.URL}),
+			policy.AddWitness("w", &policy.Entity{PublicKey: witnessPub}),
+			policy.SetQuorum("w"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sctx, cancel := context.WithCancel(context.Background())
+		s := SubmitLeafRequestsAsync(sctx, &Config{
+			Policy:    p,
+			PollDelay: 10 * time.Millisecond,
+			Timeout:   time.Minute,
+		}, reqs)
+		for e := range s.Events() {
+			if e.Type == EventWaitingForCosignatures {
+				break
+			}
+		}
+		// Stop receiving events; after cancel, the submission
+		// must complete without any receiver.
+		cancel()
+		time.Sleep(200 * time.Millisecond)
+		var count int
+		for range s.Events() {
+			count++
+		}
+		if count > 0 {
+			t.Errorf("got %d events after cancel, expected them to be dropped", count)
+		}
+		if _, err := s.Wait(); err == nil {
+			t.Errorf("cancelled submission succeeded")
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package submit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/dchest/safefile"
+
+	"sigsum.org/sigsum-go/pkg/ascii"
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/log"
+	"sigsum.org/sigsum-go/pkg/requests"
+)
+
+// A journal entry records a leaf request that has been accepted by a
+// log, but for which no proof has been stored yet. The journal file
+// consists of entries terminated by empty lines, each of the form
+//
+//	log=<log key hash>
+//	leaf_hash=<leaf hash>
+//	message=<message>
+//	signature=<signature>
+//	public_key=<submitter public key>
+type journalEntry struct {
+	logKeyHash crypto.Hash
+	leafHash   crypto.Hash
+	request    requests.Leaf
+}
+
+func (e *journalEntry) toASCII(w io.Writer) error {
+	if err := ascii.WriteHash(w, "log", &e.logKeyHash); err != nil {
+		return err
+	}
+	if err := ascii.WriteHash(w, "leaf_hash", &e.leafHash); err != nil {
+		return err
+	}
+	return e.request.ToASCII(w)
+}
+
+func (e *journalEntry) parse(p *ascii.Parser) error {
+	var err error
+	if e.logKeyHash, err = p.GetHash("log"); err != nil {
+		return err
+	}
+	if e.leafHash, err = p.GetHash("leaf_hash"); err != nil {
+		return err
+	}
+	if e.request.Message, err = p.GetHash("message"); err != nil {
+		return err
+	}
+	if e.request.Signature, err = p.GetSignature("signature"); err != nil {
+		return err
+	}
+	if e.request.PublicKey, err = p.GetPublicKey("public_key"); err != nil {
+		return err
+	}
+	leaf, err := e.request.Verify()
+	if err != nil {
+		return fmt.Errorf("invalid leaf request: %v", err)
+	}
+	if leaf.ToHash() != e.leafHash {
+		return fmt.Errorf("leaf hash doesn't match leaf request")
+	}
+	return nil
+}
+
+func newJournalEntry(s *pendingSubmission) journalEntry {
+	return journalEntry{
+		logKeyHash: crypto.HashBytes(s.log.entity.PublicKey[:]),
+		leafHash:   s.leafHash,
+		request:    s.request,
+	}
+}
+
+// Returns no entries if the file doesn't exist. An incomplete entry
+// at the end of the file, left by an interrupted append, is ignored.
+// Duplicate entries are returned only once.
+func readJournal(name string) ([]journalEntry, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	type key struct{ logKeyHash, leafHash crypto.Hash }
+	seen := make(map[key]bool)
+	var entries []journalEntry
+	for len(data) > 0 {
+		// Each entry is terminated by an empty line.
+		end := bytes.Index(data, []byte("\n\n"))
+		if end < 0 {
+			log.Warning("Ignoring incomplete entry at end of journal %q", name)
+			break
+		}
+		p := ascii.NewParser(bytes.NewReader(data[:end+1]))
+		data = data[end+2:]
+		var e journalEntry
+		if err := e.parse(&p); err != nil {
+			return nil, fmt.Errorf("invalid journal %q: %v", name, err)
+		}
+		if err := p.GetEOF(); err != nil {
+			return nil, fmt.Errorf("invalid journal %q: %v", name, err)
+		}
+		if k := (key{e.logKeyHash, e.leafHash}); !seen[k] {
+			seen[k] = true
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func writeEntries(w io.Writer, entries []journalEntry) error {
+	for _, e := range entries {
+		if err := e.toASCII(w); err != nil {
+			return err
+		}
+		// Empty line as terminator.
+		if _, err := fmt.Fprint(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Atomically replaces the journal. Removes the file if there are no
+// entries.
+func writeJournal(name string, entries []journalEntry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	f, err := safefile.Create(name, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := writeEntries(f, entries); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// Appends entries to the journal, and syncs the file, so that they
+// survive a crash.
+func appendJournal(name string, entries []journalEntry) error {
+	var buf bytes.Buffer
+	if err := writeEntries(&buf, entries); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// A journal file, with additions serialized, so that it can be
+// appended to as each submission is accepted. A nil *journal means
+// that no journal is kept.
+type journal struct {
+	m    sync.Mutex
+	name string
+	// Set when any incomplete entry at the end of the file has
+	// been dropped, which must be done before appending.
+	compacted bool
+}
+
+// Returns nil if name is empty.
+func newJournal(name string) *journal {
+	if len(name) == 0 {
+		return nil
+	}
+	return &journal{name: name}
+}
+
+// Appends the submissions to the journal.
+func (j *journal) add(submissions ...pendingSubmission) error {
+	if j == nil {
+		return nil
+	}
+	j.m.Lock()
+	defer j.m.Unlock()
+	if !j.compacted {
+		entries, err := readJournal(j.name)
+		if err != nil {
+			return err
+		}
+		if err := writeJournal(j.name, entries); err != nil {
+			return err
+		}
+		j.compacted = true
+	}
+	var entries []journalEntry
+	for i := range submissions {
+		entries = append(entries, newJournalEntry(&submissions[i]))
+	}
+	return appendJournal(j.name, entries)
+}
+
+// ReadJournal returns the leaf requests recorded in the journal file,
+// see Config.Journal, without duplicates. Returns no requests if the
+// file doesn't exist.
+func ReadJournal(name string) ([]requests.Leaf, error) {
+	entries, err := readJournal(name)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[requests.Leaf]bool)
+	var reqs []requests.Leaf
+	for _, e := range entries {
+		if !seen[e.request] {
+			seen[e.request] = true
+			reqs = append(reqs, e.request)
+		}
+	}
+	return reqs, nil
+}
+
+// RemoveFromJournal removes all entries for the given leaf requests
+// from the journal file, see Config.Journal. It should be called only
+// when the proofs for the requests have been safely stored. The file
+// is removed when no entries remain.
+func RemoveFromJournal(name string, reqs []requests.Leaf) error {
+	entries, err := readJournal(name)
+	if err != nil {
+		return err
+	}
+	done := make(map[requests.Leaf]bool)
+	for _, req := range reqs {
+		done[req] = true
+	}
+	var keep []journalEntry
+	for _, e := range entries {
+		if !done[e.request] {
+			keep = append(keep, e)
+		}
+	}
+	return writeJournal(name, keep)
+}
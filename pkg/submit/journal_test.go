@@ -0,0 +1,165 @@
+package submit
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/memlog"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/server"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestResumeLeafRequests(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	logPub := logSigner.Public()
+	submitSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	submitPub := submitSigner.Public()
+	submitKeys := map[crypto.Hash]crypto.PublicKey{crypto.HashBytes(submitPub[:]): submitPub}
+
+	log, err := memlog.New(memlog.Config{Signer: logSigner, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server.NewLog(&server.Config{}, log))
+	defer httpServer.Close()
+
+	p, err := policy.NewPolicy(policy.AddLog(&policy.Entity{PublicKey: logPub, URL: httpServer.URL}), policy.SetQuorum("none"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{
+		Policy:    p,
+		PollDelay: 10 * time.Millisecond,
+		Journal:   filepath.Join(t.TempDir(), "journal"),
+	}
+
+	var msgs []crypto.Hash
+	var reqs []requests.Leaf
+	for i := 0; i < 3; i++ {
+		msg := crypto.Hash{byte(i)}
+		signature, err := types.SignLeafMessage(submitSigner, msg[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+		reqs = append(reqs, requests.Leaf{Message: msg, Signature: signature, PublicKey: submitPub})
+	}
+
+	// No journal.
+	if _, err := ResumeLeafRequests(context.Background(), &config, reqs[:1]); err == nil {
+		t.Fatalf("resume without journal entries succeeded")
+	}
+
+	// Submit, as if interrupted before collecting proofs.
+	logs, err := logClientsFromConfig(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := newJournal(config.Journal)
+	submissions, err := submitLeaves(context.Background(), time.Minute, 1, 1, logs, reqs, j, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := readJournal(config.Journal); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 3 {
+		t.Fatalf("unexpected number of journal entries: %d", len(entries))
+	}
+	// Adding the same submissions again has no effect.
+	if err := j.add(submissions[:2]...); err != nil {
+		t.Fatal(err)
+	}
+	journaled, err := ReadJournal(config.Journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(journaled, reqs) {
+		t.Fatalf("unexpected journal requests: %v", journaled)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go log.Run(ctx)
+
+	// Resume from the journal alone.
+	proofs, err := ResumeLeafRequests(context.Background(), &config, journaled)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if len(proofs) != len(reqs) {
+		t.Fatalf("unexpected number of proofs %d", len(proofs))
+	}
+	for i, pr := range proofs {
+		if err := pr.Verify(&msgs[i], submitKeys, p); err != nil {
+			t.Errorf("proof %d not valid: %v", i, err)
+		}
+	}
+	// Entries are kept until the application removes them.
+	if err := RemoveFromJournal(config.Journal, reqs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if journaled, err := ReadJournal(config.Journal); err != nil || !slices.Equal(journaled, reqs[1:]) {
+		t.Fatalf("unexpected journal requests after remove: %v, err %v", journaled, err)
+	}
+	if err := RemoveFromJournal(config.Journal, reqs[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(config.Journal); err == nil {
+		t.Errorf("empty journal not removed")
+	}
+}
+
+func TestJournalIncompleteEntry(t *testing.T) {
+	submitSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	submitPub := submitSigner.Public()
+	name := filepath.Join(t.TempDir(), "journal")
+
+	var submissions []pendingSubmission
+	for i := 0; i < 2; i++ {
+		msg := crypto.Hash{byte(i)}
+		signature, err := types.SignLeafMessage(submitSigner, msg[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := requests.Leaf{Message: msg, Signature: signature, PublicKey: submitPub}
+		leaf, err := req.Verify()
+		if err != nil {
+			t.Fatal(err)
+		}
+		submissions = append(submissions, pendingSubmission{
+			log:      &logClient{entity: policy.Entity{PublicKey: crypto.PublicKey{1}}},
+			request:  req,
+			leafHash: leaf.ToHash(),
+		})
+	}
+	if err := newJournal(name).add(submissions[0]); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate an interrupted append.
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("log=0102")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if entries, err := readJournal(name); err != nil || len(entries) != 1 {
+		t.Fatalf("reading journal with incomplete entry failed: %d entries, err %v", len(entries), err)
+	}
+	// The incomplete entry is dropped before appending.
+	if err := newJournal(name).add(submissions[1]); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := readJournal(name); err != nil || len(entries) != 2 {
+		t.Fatalf("reading journal failed: %d entries, err %v", len(entries), err)
+	}
+}
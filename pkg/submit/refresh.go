@@ -0,0 +1,87 @@
+package submit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/proof"
+	"sigsum.org/sigsum-go/pkg/requests"
+)
+
+// RefreshProof creates a new proof for the same leaf as an existing
+// proof, using the log's latest cosigned tree head, e.g., when the
+// old tree head no longer satisfies config.Policy since a witness
+// has been retired. The leaf is not signed again. The message is
+// needed, since the proof doesn't include the leaf checksum.
+//
+// The old proof's log signature and inclusion proof must be valid,
+// but its cosignatures are not checked. The new tree head must
+// satisfy the policy, and be consistent with the old tree head.
+func RefreshProof(ctx context.Context, config *Config, msg *crypto.Hash, old *proof.SigsumProof) (proof.SigsumProof, error) {
+	logs, err := logClientsFromConfig(config)
+	if err != nil {
+		return proof.SigsumProof{}, err
+	}
+	lc := findLog(logs, &old.LogKeyHash)
+	if lc == nil {
+		return proof.SigsumProof{}, fmt.Errorf("log %x not in policy, or has no url", old.LogKeyHash)
+	}
+	sctx, cancel := context.WithTimeout(ctx, config.getGlobalTimeout())
+	defer cancel()
+	return refreshProof(sctx, config, lc, msg, old)
+}
+
+func refreshProof(ctx context.Context, config *Config, lc *logClient, msg *crypto.Hash, old *proof.SigsumProof) (proof.SigsumProof, error) {
+	checksum := crypto.HashBytes(msg[:])
+	leaf := old.Leaf.ToLeaf(&checksum)
+	leafHash := leaf.ToHash()
+	oldTh := &old.TreeHead.TreeHead
+	if !old.TreeHead.Verify(&lc.entity.PublicKey) {
+		return proof.SigsumProof{}, fmt.Errorf("invalid log signature on old tree head")
+	}
+	if err := old.Inclusion.Verify(&leafHash, oldTh); err != nil {
+		return proof.SigsumProof{}, fmt.Errorf("old inclusion proof not valid: %v", err)
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, config.getRequestTimeout())
+	defer cancel()
+	pr := proof.SigsumProof{LogKeyHash: old.LogKeyHash, Leaf: old.Leaf}
+	var err error
+	if pr.TreeHead, err = lc.client.GetTreeHead(rctx); err != nil {
+		return proof.SigsumProof{}, fmt.Errorf("getting latest tree head failed: %v", err)
+	}
+	if err := config.Policy.VerifyCosignedTreeHeadAt(&pr.LogKeyHash, &pr.TreeHead, time.Now()); err != nil {
+		return proof.SigsumProof{}, fmt.Errorf("verifying latest tree head failed: %v", err)
+	}
+	newTh := &pr.TreeHead.TreeHead
+	if newTh.Size < oldTh.Size {
+		return proof.SigsumProof{}, fmt.Errorf("latest tree head size %d is smaller than old tree head size %d",
+			newTh.Size, oldTh.Size)
+	}
+	consistency, err := lc.client.GetConsistencyProof(rctx, requests.ConsistencyProof{
+		OldSize: oldTh.Size,
+		NewSize: newTh.Size,
+	})
+	if err != nil {
+		return proof.SigsumProof{}, fmt.Errorf("getting consistency proof failed: %v", err)
+	}
+	if err := consistency.Verify(oldTh, newTh); err != nil {
+		return proof.SigsumProof{}, fmt.Errorf("latest tree head not consistent with old tree head: %v", err)
+	}
+	if newTh.Size == oldTh.Size {
+		pr.Inclusion = old.Inclusion
+		return pr, nil
+	}
+	if pr.Inclusion, err = lc.client.GetInclusionProof(rctx, requests.InclusionProof{
+		Size:     newTh.Size,
+		LeafHash: leafHash,
+	}); err != nil {
+		return proof.SigsumProof{}, fmt.Errorf("getting inclusion proof failed: %v", err)
+	}
+	if err := pr.Inclusion.Verify(&leafHash, newTh); err != nil {
+		return proof.SigsumProof{}, fmt.Errorf("inclusion proof invalid: %v", err)
+	}
+	return pr, nil
+}
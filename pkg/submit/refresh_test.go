@@ -0,0 +1,105 @@
+package submit
+
+import (
+	"context"
+	"testing"
+
+	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/memlog"
+	"sigsum.org/sigsum-go/pkg/policy"
+	"sigsum.org/sigsum-go/pkg/proof"
+	"sigsum.org/sigsum-go/pkg/requests"
+	"sigsum.org/sigsum-go/pkg/types"
+)
+
+func TestRefreshProof(t *testing.T) {
+	logSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	logPub := logSigner.Public()
+	submitSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{2})
+	submitPub := submitSigner.Public()
+	submitKeys := map[crypto.Hash]crypto.PublicKey{crypto.HashBytes(submitPub[:]): submitPub}
+
+	log, err := memlog.New(memlog.Config{Signer: logSigner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	addLeaves := func(first, count int) {
+		for i := first; i < first+count; i++ {
+			msg := crypto.Hash{byte(i)}
+			signature, err := types.SignLeafMessage(submitSigner, msg[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := log.AddLeaf(ctx, requests.Leaf{Message: msg, Signature: signature, PublicKey: submitPub}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := log.Sequence(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addLeaves(0, 3)
+
+	// Old proof for the second leaf.
+	msg := crypto.Hash{1}
+	signature, err := types.SignLeafMessage(submitSigner, msg[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := types.Leaf{Checksum: crypto.HashBytes(msg[:]), Signature: signature, KeyHash: crypto.HashBytes(submitPub[:])}
+	old := proof.SigsumProof{LogKeyHash: crypto.HashBytes(logPub[:]), Leaf: proof.NewShortLeaf(&leaf)}
+	if old.TreeHead, err = log.GetTreeHead(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if old.Inclusion, err = log.GetInclusionProof(ctx, requests.InclusionProof{Size: old.TreeHead.Size, LeafHash: leaf.ToHash()}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := policy.NewKofNPolicy([]crypto.PublicKey{logPub}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{Policy: p}
+	lc := logClient{client: log, entity: policy.Entity{PublicKey: logPub, URL: "http://example.org"}}
+
+	// Same tree head.
+	pr, err := refreshProof(ctx, &config, &lc, &msg, &old)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if pr.TreeHead.Size != old.TreeHead.Size {
+		t.Errorf("unexpected tree size %d", pr.TreeHead.Size)
+	}
+
+	addLeaves(3, 5)
+	pr, err = refreshProof(ctx, &config, &lc, &msg, &old)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if got, want := pr.TreeHead.Size, uint64(8); got != want {
+		t.Errorf("unexpected tree size, got %d, want %d", got, want)
+	}
+	if err := pr.Verify(&msg, submitKeys, p); err != nil {
+		t.Errorf("refreshed proof not valid: %v", err)
+	}
+
+	// Wrong message.
+	if _, err := refreshProof(ctx, &config, &lc, &crypto.Hash{2}, &old); err == nil {
+		t.Errorf("refresh with wrong message succeeded")
+	}
+	// Old tree head not signed by the log.
+	bad := old
+	bad.TreeHead.Signature[0] ^= 1
+	if _, err := refreshProof(ctx, &config, &lc, &msg, &bad); err == nil {
+		t.Errorf("refresh with invalid old tree head succeeded")
+	}
+	// Log's tree head doesn't satisfy policy.
+	witnessPub := crypto.PublicKey{3}
+	if config.Policy, err = policy.NewKofNPolicy([]crypto.PublicKey{logPub}, []crypto.PublicKey{witnessPub}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refreshProof(ctx, &config, &lc, &msg, &old); err == nil {
+		t.Errorf("refresh without required cosignatures succeeded")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"sigsum.org/sigsum-go/pkg/api"
@@ -23,6 +25,7 @@ const (
 	DefaultTimeout = 10 * time.Minute
 
 	defaultPollDelay      = 2 * time.Second
+	defaultParallel       = 8
 	defaultRequestTimeout = 30 * time.Second
 	defaultUserAgent      = "sigsum-go submit"
 )
@@ -53,6 +56,24 @@ type Config struct {
 	// HTTPClient specifies the HTTP client to use when making requests to the
 	// log.  If nil, a default client is created.
 	HTTPClient *http.Client
+
+	// Parallel is the maximum number of leaves that are submitted, or
+	// proofs that are collected, concurrently.  Zero implies a default
+	// limit is used.
+	Parallel int
+
+	// Redundancy is the number of logs each leaf is submitted to, and
+	// proofs collected from, see SubmitLeafRequestsRedundant.  Zero
+	// implies one log.
+	Redundancy int
+
+	// Journal, if set, is a file where each leaf request is
+	// recorded as soon as a log accepts it, so that proof
+	// collection can be resumed using ReadJournal and
+	// ResumeLeafRequests, e.g., after a restart. Entries are not
+	// removed automatically; the application should call
+	// RemoveFromJournal when it has stored the proofs.
+	Journal string
 }
 
 func (c *Config) getPollDelay() time.Duration {
@@ -76,6 +97,20 @@ func (c *Config) getRequestTimeout() time.Duration {
 	return c.RequestTimeout
 }
 
+func (c *Config) getParallel() int {
+	if c.Parallel <= 0 {
+		return defaultParallel
+	}
+	return c.Parallel
+}
+
+func (c *Config) getRedundancy() int {
+	if c.Redundancy <= 0 {
+		return 1
+	}
+	return c.Redundancy
+}
+
 func (c *Config) getUserAgent() string {
 	if len(c.UserAgent) == 0 {
 		return defaultUserAgent
@@ -120,20 +155,141 @@ func SubmitMessage(ctx context.Context, config *Config, signer crypto.Signer, me
 // sufficient amounts of witnessing (based on config.Policy).  The collected
 // proofs of logging are returned in the same order as the input requests.
 func SubmitLeafRequests(ctx context.Context, config *Config, reqs []requests.Leaf) ([]proof.SigsumProof, error) {
+	all, err := SubmitLeafRequestsRedundant(ctx, config, reqs)
+	if err != nil {
+		return nil, err
+	}
+	return firstProofs(all), nil
+}
+
+// SubmitLeafRequestsRedundant is like SubmitLeafRequests, but returns
+// all collected proofs for each request, one per log. Each request is
+// submitted to config.Redundancy logs, or as many as accept it, but
+// at least one. Failing to collect a proof from one of the logs is
+// not an error, as long as at least one proof is collected for each
+// request.
+func SubmitLeafRequestsRedundant(ctx context.Context, config *Config, reqs []requests.Leaf) ([][]proof.SigsumProof, error) {
+	return submitLeafRequests(ctx, config, reqs, nil)
+}
+
+func submitLeafRequests(ctx context.Context, config *Config, reqs []requests.Leaf, r reporter) ([][]proof.SigsumProof, error) {
+	logs, err := logClientsFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	sctx, cancel := context.WithTimeout(ctx, config.getGlobalTimeout())
+	defer cancel()
+	j := newJournal(config.Journal)
+	submissions, err := submitLeaves(sctx, config.getRequestTimeout(), config.getParallel(), config.getRedundancy(), logs, reqs, j, r)
+	if err != nil {
+		return nil, err
+	}
+	return collectAll(sctx, config, submissions, len(reqs), r)
+}
+
+func firstProofs(all [][]proof.SigsumProof) []proof.SigsumProof {
+	proofs := make([]proof.SigsumProof, len(all))
+	for i, p := range all {
+		proofs[i] = p[0]
+	}
+	return proofs
+}
+
+// ResumeLeafRequests collects proofs for leaf requests recorded in
+// config.Journal by an earlier call to SubmitLeafRequests, which was
+// interrupted before all proofs were collected, see ReadJournal. The
+// requests are not submitted again, and the journal isn't modified.
+// It is an error if a request is not found in the journal. Proofs are
+// returned in the same order as the input requests.
+func ResumeLeafRequests(ctx context.Context, config *Config, reqs []requests.Leaf) ([]proof.SigsumProof, error) {
+	if len(config.Journal) == 0 {
+		return nil, fmt.Errorf("no journal file configured")
+	}
+	entries, err := readJournal(config.Journal)
+	if err != nil {
+		return nil, err
+	}
 	logs, err := logClientsFromConfig(config)
 	if err != nil {
 		return nil, err
 	}
+	// Index of request, by leaf request.
+	indices := make(map[requests.Leaf]int)
+	for i, req := range reqs {
+		indices[req] = i
+	}
+	found := make([]bool, len(reqs))
+	var submissions []pendingSubmission
+	for _, e := range entries {
+		i, ok := indices[e.request]
+		if !ok {
+			continue
+		}
+		found[i] = true
+		lc := findLog(logs, &e.logKeyHash)
+		if lc == nil {
+			log.Warning("Log %x for checksum#%d not in policy, or has no url", e.logKeyHash, i+1)
+			continue
+		}
+		// Already verified when reading the journal.
+		leaf, err := e.request.Verify()
+		if err != nil {
+			return nil, fmt.Errorf("verifying leaf request failed: %v", err)
+		}
+		submissions = append(submissions, pendingSubmission{
+			index:     i,
+			log:       lc,
+			request:   e.request,
+			leafHash:  e.leafHash,
+			shortLeaf: proof.NewShortLeaf(&leaf),
+		})
+	}
+	for i := range reqs {
+		if !found[i] {
+			return nil, fmt.Errorf("checksum#%d not found in journal %q", i+1, config.Journal)
+		}
+	}
 	sctx, cancel := context.WithTimeout(ctx, config.getGlobalTimeout())
 	defer cancel()
-	submissions, err := submitLeaves(sctx, config.getRequestTimeout(), logs, reqs)
+	all, err := collectAll(sctx, config, submissions, len(reqs), nil)
+	if err != nil {
+		return nil, err
+	}
+	return firstProofs(all), nil
+}
+
+// Collects proofs for the submissions, for requests 0 <= index < n.
+// Fails if there is no proof for some request.
+func collectAll(ctx context.Context, config *Config, submissions []pendingSubmission, n int, r reporter) ([][]proof.SigsumProof, error) {
+	proofs, err := collectProofs(ctx, config.getRequestTimeout(), config.sleep, config.getParallel(), config.Policy, submissions, r)
 	if err != nil {
 		return nil, err
 	}
-	return collectProofs(sctx, config.getRequestTimeout(), config.sleep, config.Policy, submissions)
+	all := make([][]proof.SigsumProof, n)
+	for i, submission := range submissions {
+		if proofs[i] != nil {
+			all[submission.index] = append(all[submission.index], *proofs[i])
+		}
+	}
+	for i, p := range all {
+		if len(p) == 0 {
+			return nil, fmt.Errorf("no log available for checksum#%d", i+1)
+		}
+	}
+	return all, nil
+}
+
+func findLog(logs []logClient, logKeyHash *crypto.Hash) *logClient {
+	for i := range logs {
+		if crypto.HashBytes(logs[i].entity.PublicKey[:]) == *logKeyHash {
+			return &logs[i]
+		}
+	}
+	return nil
 }
 
 type pendingSubmission struct {
+	index     int             // index of request
 	log       *logClient      // which log
 	request   requests.Leaf   // which request
 	leafHash  crypto.Hash     // expected leaf hash
@@ -170,47 +326,152 @@ func logClientsFromConfig(config *Config) ([]logClient, error) {
 	return logs, nil
 }
 
-// submitLeaves ensures we get HTTP status 2XX for each of the signed checksums.
-// Use collectProofs() to ensure these 2XX responses transition into 200 OK with
-// appropriate proofs of logging.
+// Keeps track of which logs have failed, so that a log that isn't
+// working is tried last, rather than delaying every request.
+type logOrder struct {
+	m        sync.Mutex
+	logs     []*logClient
+	failures map[*logClient]int
+}
+
+func newLogOrder(logs []logClient) *logOrder {
+	o := logOrder{failures: make(map[*logClient]int)}
+	for i := range logs {
+		o.logs = append(o.logs, &logs[i])
+	}
+	return &o
+}
+
+// Returns logs in order of increasing number of failures.
+func (o *logOrder) get() []*logClient {
+	o.m.Lock()
+	defer o.m.Unlock()
+	logs := slices.Clone(o.logs)
+	slices.SortStableFunc(logs, func(a, b *logClient) int { return o.failures[a] - o.failures[b] })
+	return logs
+}
+
+func (o *logOrder) failed(lc *logClient) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.failures[lc]++
+}
+
+// Calls f(ctx, i) for each i, 0 <= i < n, with at most parallel
+// concurrent calls. On the first error, the context passed to f is
+// cancelled, no new calls are started, and that error is returned.
+func forEachParallel(ctx context.Context, n, parallel int, f func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	var firstErr error
+	// Set if not all calls are started.
+	var ctxErr error
+	sem := make(chan struct{}, parallel)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			if err := f(ctx, i); err != nil {
+				m.Lock()
+				defer m.Unlock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctxErr
+}
+
+// submitLeaves ensures we get HTTP status 2XX for each of the signed checksums,
+// from up to redundancy logs each. Use collectProofs() to ensure these 2XX
+// responses transition into 200 OK with appropriate proofs of logging. The
+// returned submissions are ordered by request index.
 //
 // Note: by ensuring that some log says it will take each signed checksum and
 // then collecting the proofs, we don't wait as much for tree heads to rotate.
-func submitLeaves(ctx context.Context, timeout time.Duration, logs []logClient, reqs []requests.Leaf) ([]pendingSubmission, error) {
-	var submissions []pendingSubmission
+//
+// Each accepted submission is added to the journal j, if non-nil, before
+// submitting to the next log.
+func submitLeaves(ctx context.Context, timeout time.Duration, parallel, redundancy int, logs []logClient, reqs []requests.Leaf, j *journal, r reporter) ([]pendingSubmission, error) {
+	leaves := make([]types.Leaf, len(reqs))
 	for i, req := range reqs {
-		leaf, err := req.Verify()
-		if err != nil {
+		var err error
+		if leaves[i], err = req.Verify(); err != nil {
 			return nil, fmt.Errorf("verifying leaf request failed: %v", err)
 		}
-
-		for _, lc := range logs {
-			if err = ctx.Err(); err != nil {
-				return nil, err
+	}
+	if redundancy > len(logs) {
+		log.Warning("Only %d logs available, wanted redundancy %d", len(logs), redundancy)
+		redundancy = len(logs)
+	}
+	order := newLogOrder(logs)
+	perRequest := make([][]pendingSubmission, len(reqs))
+	err := forEachParallel(ctx, len(reqs), parallel, func(ctx context.Context, i int) error {
+		for _, lc := range order.get() {
+			if len(perRequest[i]) >= redundancy {
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				return err
 			}
-
 			log.Info("Attempting to submit checksum#%d to log: %s", i+1, lc.entity.URL)
-			if err = submitLeaf(ctx, timeout, lc, req); err != nil {
+			r.report(Event{Type: EventSubmitted, Index: i, Log: &lc.entity})
+			if err := submitLeaf(ctx, timeout, lc, reqs[i]); err != nil {
 				log.Error("Submitting to log %q failed: %v", lc.entity.URL, err)
+				r.report(Event{Type: EventLogError, Index: i, Log: &lc.entity, Err: err})
+				order.failed(lc)
 				continue
 			}
-
-			submissions = append(submissions, pendingSubmission{
-				log:       &lc,
-				request:   req,
-				leafHash:  leaf.ToHash(),
-				shortLeaf: proof.NewShortLeaf(&leaf),
-			})
-			break
+			r.report(Event{Type: EventAccepted, Index: i, Log: &lc.entity})
+			submission := pendingSubmission{
+				index:     i,
+				log:       lc,
+				request:   reqs[i],
+				leafHash:  leaves[i].ToHash(),
+				shortLeaf: proof.NewShortLeaf(&leaves[i]),
+			}
+			if err := j.add(submission); err != nil {
+				return fmt.Errorf("writing journal failed: %v", err)
+			}
+			perRequest[i] = append(perRequest[i], submission)
 		}
-		if err != nil {
-			return nil, fmt.Errorf("all logs failed, giving up")
+		switch n := len(perRequest[i]); {
+		case n == 0:
+			err := fmt.Errorf("all logs failed, giving up")
+			r.report(Event{Type: EventFailed, Index: i, Err: err})
+			return err
+		case n < redundancy:
+			log.Warning("Checksum#%d submitted to only %d logs", i+1, n)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var submissions []pendingSubmission
+	for _, s := range perRequest {
+		submissions = append(submissions, s...)
 	}
 	return submissions, nil
 }
 
-func submitLeaf(ctx context.Context, timeout time.Duration, lc logClient, req requests.Leaf) error {
+func submitLeaf(ctx context.Context, timeout time.Duration, lc *logClient, req requests.Leaf) error {
 	sctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	_, err := lc.client.AddLeaf(sctx, req, lc.header)
@@ -218,31 +479,111 @@ func submitLeaf(ctx context.Context, timeout time.Duration, lc logClient, req re
 }
 
 // collectProofs ensures the pending submissions transition from HTTP status 2XX
-// to HTTP status 200 OK in the respective logs. Proofs are then collected.
-func collectProofs(ctx context.Context, timeout time.Duration, sleep func(ctx context.Context) error, policy *policy.Policy, submissions []pendingSubmission) ([]proof.SigsumProof, error) {
-	var proofs []proof.SigsumProof
-	for i, submission := range submissions {
+// to HTTP status 200 OK in the respective logs. Proofs are then collected,
+// polling all submissions, with up to parallel requests to the logs in
+// progress concurrently, so that submissions to a lagging log don't
+// block submissions to other logs. The proofs are returned in
+// the same order as the submissions, with nil for submissions where collection
+// failed. Such failures are not fatal, as long as at least one proof is
+// collected for each request. Once that is the case, remaining submissions
+// are given at most one more timeout period, so that a lagging log doesn't
+// delay the result until the global timeout.
+func collectProofs(ctx context.Context, timeout time.Duration, sleep func(ctx context.Context) error, parallel int, policy *policy.Policy, submissions []pendingSubmission, r reporter) ([]*proof.SigsumProof, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	proofs := make([]*proof.SigsumProof, len(submissions))
+	var m sync.Mutex
+	// Number of collected proofs, and first error, per request.
+	collected := make(map[int]int)
+	errs := make(map[int]error)
+	for _, s := range submissions {
+		collected[s.index] = 0
+	}
+	needed := len(collected)
+	var grace *time.Timer
+
+	// Limits concurrent requests, but not polling.
+	sem := make(chan struct{}, parallel)
+	attempt := func(submission *pendingSubmission, p *progress) (*proof.SigsumProof, error) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-sem }()
+		log.Info("Attempting to retrieve proof for checksum#%d from log: %s", submission.index+1, submission.log.entity.URL)
+		return collectProof(ctx, timeout, policy, *submission, p)
+	}
+	collectOne := func(i int) {
+		submission := &submissions[i]
+		p := newProgress(r, submission)
+		failed := func(err error) {
+			log.Warning("Collecting proof for checksum#%d from log %s failed: %v",
+				submission.index+1, submission.log.entity.URL, err)
+			e := p.event(EventFailed)
+			e.Err = err
+			p.report(e)
+
+			m.Lock()
+			defer m.Unlock()
+			if errs[submission.index] == nil {
+				errs[submission.index] = err
+			}
+		}
 		for {
-			log.Info("Attempting to retrieve proof for checksum#%d", i+1)
-			pr, err := collectProof(ctx, timeout, policy, submission)
+			pr, err := attempt(submission, p)
 			if err != nil {
-				return nil, err
+				failed(err)
+				return
 			}
 			if pr != nil {
-				proofs = append(proofs, *pr)
-				break
+				m.Lock()
+				proofs[i] = pr
+				collected[submission.index]++
+				if collected[submission.index] == 1 {
+					needed--
+					if needed == 0 {
+						grace = time.AfterFunc(timeout, cancel)
+					}
+				}
+				m.Unlock()
+
+				e := p.event(EventProofReady)
+				e.Proof = pr
+				p.report(e)
+				return
 			}
-			if errInner := sleep(ctx); errInner != nil {
-				return nil, errInner
+			if err := sleep(ctx); err != nil {
+				failed(err)
+				return
 			}
 		}
 	}
+	var wg sync.WaitGroup
+	for i := range submissions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			collectOne(i)
+		}(i)
+	}
+	wg.Wait()
+
+	if grace != nil {
+		grace.Stop()
+	}
+	for index, count := range collected {
+		if count == 0 {
+			return nil, fmt.Errorf("collecting proof for checksum#%d failed: %v", index+1, errs[index])
+		}
+	}
 	return proofs, nil
 }
 
 // collectProof returns (non-nil, nil) when a proof was collected successfully.
 // Returns an error if it seems unlikely that trying again will help.
-func collectProof(ctx context.Context, timeout time.Duration, policy *policy.Policy, submission pendingSubmission) (*proof.SigsumProof, error) {
+func collectProof(ctx context.Context, timeout time.Duration, policy *policy.Policy, submission pendingSubmission, p *progress) (*proof.SigsumProof, error) {
 	sctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	pr := proof.SigsumProof{
@@ -252,23 +593,33 @@ func collectProof(ctx context.Context, timeout time.Duration, policy *policy.Pol
 	persisted, err := submission.log.client.AddLeaf(sctx, submission.request, submission.log.header)
 	if err != nil {
 		log.Debug("Checking that checksum was accepted: %v", err)
+		p.logError(err)
 		return nil, nil // continue trying
 	}
 	if !persisted {
 		log.Debug("Checking that checksum was sequenced: not yet")
 		return nil, nil // continue trying
 	}
+	p.setSequenced()
 	if pr.TreeHead, err = submission.log.client.GetTreeHead(sctx); err != nil {
 		log.Debug("Getting latest tree head: %v", err)
+		p.logError(err)
 		return nil, nil // continue trying
 	}
-	if err := policy.VerifyCosignedTreeHead(&pr.LogKeyHash, &pr.TreeHead); err != nil {
+	now := time.Now()
+	if err := policy.VerifyCosignedTreeHeadAt(&pr.LogKeyHash, &pr.TreeHead, now); err != nil {
 		log.Info("Verifying latest tree head: %v", err)
+		if report, rerr := policy.ExplainCosignedTreeHeadAt(&pr.LogKeyHash, &pr.TreeHead, now); rerr == nil && !report.Quorum.Satisfied {
+			p.setCosignatures(report)
+		} else {
+			p.logError(err)
+		}
 		return nil, nil // continue trying
 	}
 	req := requests.InclusionProof{Size: pr.TreeHead.Size, LeafHash: submission.leafHash}
 	if pr.Inclusion, err = submission.log.client.GetInclusionProof(sctx, req); err != nil {
 		log.Debug("Getting inclusion proof: %v", err)
+		p.logError(err)
 		return nil, nil // continue trying
 	}
 	if err = pr.Inclusion.Verify(&submission.leafHash, &pr.TreeHead.TreeHead); err != nil {
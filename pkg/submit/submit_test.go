@@ -4,15 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"sigsum.org/sigsum-go/pkg/api"
 	"sigsum.org/sigsum-go/pkg/crypto"
+	"sigsum.org/sigsum-go/pkg/memlog"
 	"sigsum.org/sigsum-go/pkg/merkle"
 	"sigsum.org/sigsum-go/pkg/mocks/mockapi"
 	"sigsum.org/sigsum-go/pkg/policy"
 	"sigsum.org/sigsum-go/pkg/requests"
+	token "sigsum.org/sigsum-go/pkg/submit-token"
 	"sigsum.org/sigsum-go/pkg/types"
 )
 
@@ -47,7 +51,7 @@ func TestSubmitSuccess(t *testing.T) {
 		msg, sth, inclusionProof, req := prepareResponse(t, submitSigner, logSigner, &tree, i)
 		client.EXPECT().AddLeaf(gomock.Any(), req, gomock.Any()).Return(false, nil)
 
-		submissions, err := submitLeaves(context.Background(), timeout, logs, []requests.Leaf{req})
+		submissions, err := submitLeaves(context.Background(), timeout, 1, 1, logs, []requests.Leaf{req}, nil, nil)
 		if err != nil {
 			t.Errorf("submit failed: %v", err)
 			return
@@ -61,7 +65,7 @@ func TestSubmitSuccess(t *testing.T) {
 		client.EXPECT().GetTreeHead(gomock.Any()).Return(types.CosignedTreeHead{SignedTreeHead: sth}, nil)
 		client.EXPECT().GetInclusionProof(gomock.Any(), gomock.Any()).Return(inclusionProof, nil)
 
-		proofs, err := collectProofs(context.Background(), timeout, nop, p, submissions)
+		proofs, err := collectProofs(context.Background(), timeout, nop, 1, p, submissions, nil)
 		if err != nil {
 			t.Errorf("collect failed: %v", err)
 			return
@@ -129,7 +133,7 @@ func TestSubmitWithFailure(t *testing.T) {
 	clientAlwaysFail.EXPECT().AddLeaf(gomock.Any(), req, gomock.Any()).Return(false, errors.New("mock error"))
 	client.EXPECT().AddLeaf(gomock.Any(), req, gomock.Any()).Return(false, errors.New("mock error"))
 
-	submissions, err := submitLeaves(context.Background(), timeout, logs, []requests.Leaf{req})
+	submissions, err := submitLeaves(context.Background(), timeout, 1, 1, logs, []requests.Leaf{req}, nil, nil)
 	if err == nil {
 		t.Errorf("submit succeeded but shouldn't have")
 		return
@@ -139,7 +143,7 @@ func TestSubmitWithFailure(t *testing.T) {
 	clientAlwaysFail.EXPECT().AddLeaf(gomock.Any(), req, gomock.Any()).Return(false, errors.New("mock error"))
 	client.EXPECT().AddLeaf(gomock.Any(), req, gomock.Any()).Return(false, nil)
 
-	submissions, err = submitLeaves(context.Background(), timeout, logs, []requests.Leaf{req})
+	submissions, err = submitLeaves(context.Background(), timeout, 1, 1, logs, []requests.Leaf{req}, nil, nil)
 	if err != nil {
 		t.Errorf("submit failed: %v", err)
 		return
@@ -163,7 +167,7 @@ func TestSubmitWithFailure(t *testing.T) {
 	client.EXPECT().GetTreeHead(gomock.Any()).Return(types.CosignedTreeHead{SignedTreeHead: sth}, nil)
 	client.EXPECT().GetInclusionProof(gomock.Any(), gomock.Any()).Return(inclusionProof, nil)
 
-	if _, err := collectProofs(context.Background(), timeout, nop, p, submissions); err != nil {
+	if _, err := collectProofs(context.Background(), timeout, nop, 1, p, submissions, nil); err != nil {
 		t.Errorf("collect failed but shouldn't have: %v", err)
 		return
 	}
@@ -173,7 +177,7 @@ func TestSubmitWithFailure(t *testing.T) {
 	client.EXPECT().GetTreeHead(gomock.Any()).Return(types.CosignedTreeHead{SignedTreeHead: sth}, nil)
 	client.EXPECT().GetInclusionProof(gomock.Any(), gomock.Any()).Return(inclusionProof, nil)
 
-	if _, err := collectProofs(context.Background(), timeout, nop, p, submissions); err == nil {
+	if _, err := collectProofs(context.Background(), timeout, nop, 1, p, submissions, nil); err == nil {
 		t.Errorf("collect succeeded but shouldn't have")
 		return
 	}
@@ -223,3 +227,167 @@ func prepareResponse(t *testing.T, submitSigner, logSigner crypto.Signer, tree *
 func nop(_ context.Context) error {
 	return nil
 }
+
+// A log that rejects all add-leaf requests.
+type failingLog struct {
+	api.Log
+	m        sync.Mutex
+	attempts int
+}
+
+func (l *failingLog) AddLeaf(_ context.Context, _ requests.Leaf, _ *token.SubmitHeader) (bool, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.attempts++
+	return false, errors.New("mock error")
+}
+
+func TestSubmitParallel(t *testing.T) {
+	submitSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+	submitPub := submitSigner.Public()
+	submitKeys := map[crypto.Hash]crypto.PublicKey{crypto.HashBytes(submitPub[:]): submitPub}
+
+	var logKeys []crypto.PublicKey
+	var memlogs []*memlog.Log
+	bad := failingLog{}
+	logs := []logClient{{client: &bad, entity: policy.Entity{URL: "http://bad.example.org"}}}
+	for i := 0; i < 2; i++ {
+		signer := crypto.NewEd25519Signer(&crypto.PrivateKey{byte(2 + i)})
+		l, err := memlog.New(memlog.Config{Signer: signer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		memlogs = append(memlogs, l)
+		logKeys = append(logKeys, signer.Public())
+		logs = append(logs, logClient{client: l, entity: policy.Entity{
+			PublicKey: signer.Public(), URL: fmt.Sprintf("http://log%d.example.org", i)}})
+	}
+	p, err := policy.NewKofNPolicy(logKeys, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sequence pending leaves on each poll.
+	sequence := func(ctx context.Context) error {
+		for _, l := range memlogs {
+			if err := l.Sequence(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var msgs []crypto.Hash
+	var reqs []requests.Leaf
+	for i := 0; i < 20; i++ {
+		msg := crypto.Hash{byte(i)}
+		signature, err := types.SignLeafMessage(submitSigner, msg[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+		reqs = append(reqs, requests.Leaf{Message: msg, Signature: signature, PublicKey: submitPub})
+	}
+	parallel := 4
+	submissions, err := submitLeaves(context.Background(), time.Minute, parallel, 2, logs, reqs, nil, nil)
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if got, want := len(submissions), 2*len(reqs); got != want {
+		t.Fatalf("unexpected number of submissions, got %d, want %d", got, want)
+	}
+	// Failing log is tried last, once it has failed.
+	if bad.attempts > parallel {
+		t.Errorf("too many attempts for failing log: %d", bad.attempts)
+	}
+	proofs, err := collectProofs(context.Background(), time.Minute, sequence, parallel, p, submissions, nil)
+	if err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+	seen := make(map[crypto.Hash]int)
+	for i, pr := range proofs {
+		index := submissions[i].index
+		if index != i/2 {
+			t.Errorf("unexpected order of submissions, index %d at position %d", index, i)
+		}
+		if err := pr.Verify(&msgs[index], submitKeys, p); err != nil {
+			t.Errorf("proof %d for request %d failed to verify: %v", i, index, err)
+		}
+		seen[pr.LogKeyHash]++
+	}
+	if len(seen) != 2 {
+		t.Errorf("proofs not from both logs: %v", seen)
+	}
+}
+
+func TestCollectLaggingLog(t *testing.T) {
+	submitSigner := crypto.NewEd25519Signer(&crypto.PrivateKey{1})
+
+	var logKeys []crypto.PublicKey
+	var memlogs []*memlog.Log
+	var logs []logClient
+	for i := 0; i < 2; i++ {
+		signer := crypto.NewEd25519Signer(&crypto.PrivateKey{byte(2 + i)})
+		l, err := memlog.New(memlog.Config{Signer: signer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		memlogs = append(memlogs, l)
+		logKeys = append(logKeys, signer.Public())
+		logs = append(logs, logClient{client: l, entity: policy.Entity{
+			PublicKey: signer.Public(), URL: fmt.Sprintf("http://log%d.example.org", i)}})
+	}
+	p, err := policy.NewKofNPolicy(logKeys, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the first log sequences leaves, the other lags.
+	sequence := func(ctx context.Context) error {
+		if err := memlogs[0].Sequence(ctx); err != nil {
+			return err
+		}
+		return sleepWithContext(ctx, time.Millisecond)
+	}
+	submit := func(start, end int) []pendingSubmission {
+		var reqs []requests.Leaf
+		for i := start; i < end; i++ {
+			msg := crypto.Hash{byte(i)}
+			signature, err := types.SignLeafMessage(submitSigner, msg[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			reqs = append(reqs, requests.Leaf{Message: msg, Signature: signature, PublicKey: submitSigner.Public()})
+		}
+		submissions, err := submitLeaves(context.Background(), time.Minute, 2, 2, logs, reqs, nil, nil)
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+		return submissions
+	}
+	submissions := submit(0, 5)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	proofs, err := collectProofs(ctx, 100*time.Millisecond, sequence, 4, p, submissions, nil)
+	if err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("collect waited until global timeout")
+	}
+	logKeyHash := crypto.HashBytes(logKeys[0][:])
+	for i, pr := range proofs {
+		if submissions[i].log == &logs[1] {
+			if pr != nil {
+				t.Errorf("unexpected proof from lagging log for request %d", submissions[i].index)
+			}
+		} else if pr == nil || pr.LogKeyHash != logKeyHash {
+			t.Errorf("missing proof for request %d", submissions[i].index)
+		}
+	}
+
+	// If no log sequences, collection fails.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := collectProofs(ctx, time.Minute, nop, 4, p, submit(5, 10), nil); err == nil {
+		t.Errorf("collect succeeded without any sequenced leaves")
+	}
+}
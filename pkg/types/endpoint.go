@@ -15,6 +15,11 @@ const (
 
 	// Witness api.
 	EndpointAddCheckpoint = Endpoint("add-checkpoint")
+	// For auditing a witness' history of cosigned checkpoints.
+	EndpointGetCosignedCheckpoint = Endpoint("get-cosigned-checkpoint/")
+
+	// For gossip of tree heads between monitors.
+	EndpointGetGossipTreeHead = Endpoint("get-gossip-tree-head/")
 )
 
 // Path adds endpoint name to a service prefix.  If prefix is empty, nothing is added.